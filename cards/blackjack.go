@@ -0,0 +1,671 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// bjRules are the table rules. defaultBlackjackRules is a common six deck game.
+type bjRules struct {
+	Decks int
+	// Reshuffle is when the shoe is shuffled, see reshufflePolicy.
+	Reshuffle reshufflePolicy
+	// Penetration is the part of the shoe dealt before the cut card comes out, e.g. 0.75.
+	Penetration float64
+	// HitSoft17 makes the dealer hit a soft 17 ( Ace + Six ) instead of standing.
+	HitSoft17 bool
+	// BlackjackPays is the payout of a natural blackjack, 1.5 for 3:2.
+	BlackjackPays float64
+	// DoubleAfterSplit allows doubling a hand that came out of a split.
+	DoubleAfterSplit bool
+	// MaxSplits is how many times a round can be split, 3 means up to 4 hands.
+	MaxSplits int
+	// Surrender allows giving up the first two cards for half the bet ( late surrender ).
+	Surrender bool
+}
+
+func defaultBlackjackRules() bjRules {
+	return bjRules{
+		Decks:            6,
+		Reshuffle:        reshuffleAtPenetration,
+		Penetration:      0.75,
+		HitSoft17:        false,
+		BlackjackPays:    1.5,
+		DoubleAfterSplit: true,
+		MaxSplits:        3,
+		Surrender:        true,
+	}
+}
+
+func (r bjRules) validate() error {
+	switch {
+	case r.BlackjackPays <= 0:
+		return fmt.Errorf("blackjack payout must be positive, got %v", r.BlackjackPays)
+	case r.MaxSplits < 0:
+		return fmt.Errorf("max splits can't be negative, got %d", r.MaxSplits)
+	}
+	return nil
+}
+
+// bjCardValue is the blackjack value of a card - face cards are 10 and the Ace counts as 11 here.
+func bjCardValue(c Card) int {
+	switch {
+	case c.Rank == Ace:
+		return 11
+	case c.Rank >= Ten:
+		return 10
+	}
+	return int(c.Rank)
+}
+
+// blackjackTotal adds up the cards. An Ace counts as 11 unless that busts the hand.
+// soft is true when an Ace is still counted as 11.
+func blackjackTotal(cards deck) (total int, soft bool) {
+	aces := 0
+	for _, card := range cards {
+		total += bjCardValue(card)
+		if card.Rank == Ace {
+			aces++
+		}
+	}
+	for total > 21 && aces > 0 {
+		total -= 10
+		aces--
+	}
+	return total, aces > 0
+}
+
+func isBlackjack(cards deck) bool {
+	total, _ := blackjackTotal(cards)
+	return len(cards) == 2 && total == 21
+}
+
+// bjAction is what the player can do with a hand.
+type bjAction int
+
+const (
+	bjHit bjAction = iota
+	bjStand
+	bjDouble
+	bjSplit
+	bjSurrender
+)
+
+var bjActionNames = []string{"hit", "stand", "double", "split", "surrender"}
+
+func (a bjAction) String() string {
+	if a < 0 || int(a) >= len(bjActionNames) {
+		return fmt.Sprintf("bjAction(%d)", int(a))
+	}
+	return bjActionNames[a]
+}
+
+// bjHand is one hand of the player - a round has more than one after a split.
+type bjHand struct {
+	Cards       deck
+	Bet         float64
+	Doubled     bool
+	Split       bool
+	Surrendered bool
+	// splitAces hands get one card only.
+	splitAces bool
+}
+
+func (h *bjHand) total() (int, bool) {
+	return blackjackTotal(h.Cards)
+}
+
+func (h *bjHand) busted() bool {
+	total, _ := h.total()
+	return total > 21
+}
+
+// bjView is what the player sees when it has to decide.
+type bjView struct {
+	Hand     deck
+	Total    int
+	Soft     bool
+	DealerUp Card
+	// HandIndex and Hands tell which hand is played after a split.
+	HandIndex int
+	Hands     int
+	Allowed   []bjAction
+	Bankroll  float64
+}
+
+func (v bjView) can(a bjAction) bool {
+	for _, allowed := range v.Allowed {
+		if allowed == a {
+			return true
+		}
+	}
+	return false
+}
+
+// bjPlayer makes the decisions for the player's hands.
+// The terminal player and the headless strategies both implement it, so the engine doesn't know who is playing.
+type bjPlayer interface {
+	insurance(v bjView) bool
+	decide(v bjView) bjAction
+}
+
+// ErrIllegalAction is returned when a player picks an action that isn't in bjView.Allowed.
+var ErrIllegalAction = errors.New("illegal action")
+
+// bjStats sums up many rounds.
+type bjStats struct {
+	Rounds     int
+	Hands      int
+	Wins       int
+	Losses     int
+	Pushes     int
+	Blackjacks int
+	Busts      int
+	Surrenders int
+	Doubles    int
+	Splits     int
+	Insurances int
+	// Wagered is the total of all the bets, including doubles and splits.
+	Wagered       float64
+	Net           float64
+	StartBankroll float64
+	Bankroll      float64
+	MaxBankroll   float64
+	MinBankroll   float64
+	// sumSquares is the sum of the squared round results, for the standard deviation.
+	sumSquares float64
+}
+
+// edge is the player's result per unit wagered - negative is the house edge.
+func (s bjStats) edge() float64 {
+	if s.Wagered == 0 {
+		return 0
+	}
+	return s.Net / s.Wagered
+}
+
+// stdDev is the standard deviation of the result of a round.
+func (s bjStats) stdDev() float64 {
+	if s.Rounds < 2 {
+		return 0
+	}
+	n := float64(s.Rounds)
+	mean := s.Net / n
+	return math.Sqrt(math.Max(s.sumSquares-n*mean*mean, 0) / (n - 1))
+}
+
+func (s bjStats) print(w io.Writer) {
+	fmt.Fprintf(w, "Rounds:      %d ( %d hands )\n", s.Rounds, s.Hands)
+	fmt.Fprintf(w, "Wins:        %d\n", s.Wins)
+	fmt.Fprintf(w, "Losses:      %d\n", s.Losses)
+	fmt.Fprintf(w, "Pushes:      %d\n", s.Pushes)
+	fmt.Fprintf(w, "Blackjacks:  %d\n", s.Blackjacks)
+	fmt.Fprintf(w, "Busts:       %d\n", s.Busts)
+	fmt.Fprintf(w, "Doubles:     %d  Splits: %d  Surrenders: %d  Insurances: %d\n", s.Doubles, s.Splits, s.Surrenders, s.Insurances)
+	fmt.Fprintf(w, "Wagered:     %.2f\n", s.Wagered)
+	fmt.Fprintf(w, "Net:         %+.2f ( %+.3f%% of wagered )\n", s.Net, 100*s.edge())
+	fmt.Fprintf(w, "Round stdev: %.3f\n", s.stdDev())
+	fmt.Fprintf(w, "Bankroll:    %.2f ( start %.2f, min %.2f, max %.2f )\n", s.Bankroll, s.StartBankroll, s.MinBankroll, s.MaxBankroll)
+}
+
+// blackjack is the game engine: one player against the dealer.
+type blackjack struct {
+	rules bjRules
+	shoe  *Shoe
+	stats bjStats
+	// out gets a description of every round, io.Discard for headless games.
+	out io.Writer
+}
+
+// newBlackjack starts a game. The seed decides every shuffle of the shoe.
+func newBlackjack(rules bjRules, bankroll float64, seed int64, out io.Writer) (*blackjack, error) {
+	if err := rules.validate(); err != nil {
+		return nil, err
+	}
+	if out == nil {
+		out = io.Discard
+	}
+	shoe, err := newShoe(rules.Decks, rules.Reshuffle, rules.Penetration, rand.NewSource(seed))
+	if err != nil {
+		return nil, err
+	}
+	g := &blackjack{
+		rules: rules,
+		shoe:  shoe,
+		out:   out,
+	}
+	g.stats.StartBankroll = bankroll
+	g.stats.Bankroll = bankroll
+	g.stats.MaxBankroll = bankroll
+	g.stats.MinBankroll = bankroll
+	return g, nil
+}
+
+func (g *blackjack) bankroll() float64 {
+	return g.stats.Bankroll
+}
+
+// playRound plays one round with the given bet and returns the player's net result.
+func (g *blackjack) playRound(p bjPlayer, bet float64) (float64, error) {
+	if bet <= 0 {
+		return 0, fmt.Errorf("bet must be positive, got %v", bet)
+	}
+	if bet > g.bankroll() {
+		return 0, fmt.Errorf("bet %.2f is more than the bankroll %.2f", bet, g.bankroll())
+	}
+
+	hands := []*bjHand{{Bet: bet}}
+	var dealerCards deck
+	// all the cards in play go to the discard pile when the round is over, however it ends
+	defer func() { g.endRound(hands, dealerCards) }()
+
+	// the cards go player, dealer, player, dealer - the second dealer card is the hole card
+	for range 2 {
+		if err := g.hit(&hands[0].Cards); err != nil {
+			return 0, err
+		}
+		if err := g.hit(&dealerCards); err != nil {
+			return 0, err
+		}
+	}
+
+	up := dealerCards[0]
+	fmt.Fprintf(g.out, "Dealer shows %v\n", up)
+	fmt.Fprintf(g.out, "Your hand: %v\n", describeBJHand(hands[0].Cards))
+
+	committed := bet
+	net := 0.0
+	g.stats.Wagered += bet
+
+	// insurance is a side bet of half the bet that the dealer has a blackjack, it pays 2:1
+	if up.Rank == Ace && g.bankroll()-committed >= bet/2 && p.insurance(g.view(hands, 0, up, committed)) {
+		insurance := bet / 2
+		committed += insurance
+		g.stats.Insurances++
+		if isBlackjack(dealerCards) {
+			fmt.Fprintln(g.out, "Insurance pays.")
+			net += 2 * insurance
+		} else {
+			fmt.Fprintln(g.out, "Insurance lost.")
+			net -= insurance
+		}
+	}
+
+	// the dealer peeks for a blackjack - the round ends straight away when either side has one
+	dealerBJ := isBlackjack(dealerCards)
+	playerBJ := isBlackjack(hands[0].Cards)
+	if dealerBJ || playerBJ {
+		fmt.Fprintf(g.out, "Dealer has %v\n", describeBJHand(dealerCards))
+		g.stats.Hands++
+		switch {
+		case dealerBJ && playerBJ:
+			fmt.Fprintln(g.out, "Both have blackjack - push.")
+			g.stats.Pushes++
+		case dealerBJ:
+			fmt.Fprintln(g.out, "Dealer has blackjack.")
+			g.stats.Losses++
+			net -= bet
+		default:
+			fmt.Fprintln(g.out, "Blackjack!")
+			g.stats.Blackjacks++
+			g.stats.Wins++
+			net += bet * g.rules.BlackjackPays
+		}
+		g.finishRound(net)
+		return net, nil
+	}
+
+	// play every hand - a split adds a new hand right after the one being played
+	for i := 0; i < len(hands); i++ {
+		var err error
+		hands, committed, err = g.playHand(p, hands, i, up, committed)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// the dealer only plays when there is a hand left to beat
+	live := false
+	for _, h := range hands {
+		if !h.busted() && !h.Surrendered {
+			live = true
+		}
+	}
+	fmt.Fprintf(g.out, "Dealer has %v\n", describeBJHand(dealerCards))
+	if live {
+		for g.dealerHits(dealerCards) {
+			if err := g.hit(&dealerCards); err != nil {
+				return 0, err
+			}
+			fmt.Fprintf(g.out, "Dealer draws: %v\n", describeBJHand(dealerCards))
+		}
+	}
+	dealerTotal, _ := blackjackTotal(dealerCards)
+
+	for i, h := range hands {
+		g.stats.Hands++
+		total, _ := h.total()
+		label := "Hand"
+		if len(hands) > 1 {
+			label = fmt.Sprintf("Hand %d", i+1)
+		}
+		switch {
+		case h.Surrendered:
+			fmt.Fprintf(g.out, "%s: surrendered.\n", label)
+			g.stats.Surrenders++
+			g.stats.Losses++
+			net -= h.Bet / 2
+		case total > 21:
+			fmt.Fprintf(g.out, "%s: bust.\n", label)
+			g.stats.Busts++
+			g.stats.Losses++
+			net -= h.Bet
+		case dealerTotal > 21 || total > dealerTotal:
+			fmt.Fprintf(g.out, "%s: win.\n", label)
+			g.stats.Wins++
+			net += h.Bet
+		case total < dealerTotal:
+			fmt.Fprintf(g.out, "%s: lose.\n", label)
+			g.stats.Losses++
+			net -= h.Bet
+		default:
+			fmt.Fprintf(g.out, "%s: push.\n", label)
+			g.stats.Pushes++
+		}
+	}
+	g.finishRound(net)
+	return net, nil
+}
+
+// playHand asks the player what to do with hands[i] until the hand is finished.
+func (g *blackjack) playHand(p bjPlayer, hands []*bjHand, i int, up Card, committed float64) ([]*bjHand, float64, error) {
+	h := hands[i]
+	// a hand that came out of a split has one card - it gets its second card now
+	if len(h.Cards) == 1 {
+		if err := g.hit(&h.Cards); err != nil {
+			return nil, 0, err
+		}
+		if len(hands) > 1 {
+			fmt.Fprintf(g.out, "Hand %d: %v\n", i+1, describeBJHand(h.Cards))
+		}
+	}
+	if h.splitAces {
+		return hands, committed, nil
+	}
+
+	for {
+		if total, _ := h.total(); total >= 21 {
+			return hands, committed, nil
+		}
+
+		v := g.view(hands, i, up, committed)
+		action := p.decide(v)
+		if !v.can(action) {
+			return nil, 0, fmt.Errorf("%w %v with %v", ErrIllegalAction, action, h.Cards)
+		}
+
+		switch action {
+		case bjStand:
+			return hands, committed, nil
+
+		case bjHit:
+			if err := g.hit(&h.Cards); err != nil {
+				return nil, 0, err
+			}
+			fmt.Fprintf(g.out, "Hit: %v\n", describeBJHand(h.Cards))
+
+		case bjDouble:
+			committed += h.Bet
+			g.stats.Wagered += h.Bet
+			g.stats.Doubles++
+			h.Bet *= 2
+			h.Doubled = true
+			if err := g.hit(&h.Cards); err != nil {
+				return nil, 0, err
+			}
+			fmt.Fprintf(g.out, "Double: %v\n", describeBJHand(h.Cards))
+			return hands, committed, nil
+
+		case bjSplit:
+			committed += h.Bet
+			g.stats.Wagered += h.Bet
+			g.stats.Splits++
+			aces := h.Cards[0].Rank == Ace
+			second := &bjHand{Cards: deck{h.Cards[1]}, Bet: h.Bet, Split: true, splitAces: aces}
+			h.Cards = deck{h.Cards[0]}
+			h.Split = true
+			h.splitAces = aces
+			// the new hand goes right after this one
+			hands = append(hands[:i+1], append([]*bjHand{second}, hands[i+1:]...)...)
+			if err := g.hit(&h.Cards); err != nil {
+				return nil, 0, err
+			}
+			fmt.Fprintf(g.out, "Split - hand %d: %v\n", i+1, describeBJHand(h.Cards))
+			if aces {
+				return hands, committed, nil
+			}
+
+		case bjSurrender:
+			h.Surrendered = true
+			return hands, committed, nil
+		}
+	}
+}
+
+// view builds what the player sees for hands[i] and the actions it may take.
+func (g *blackjack) view(hands []*bjHand, i int, up Card, committed float64) bjView {
+	h := hands[i]
+	total, soft := h.total()
+	v := bjView{
+		Hand:      append(deck{}, h.Cards...),
+		Total:     total,
+		Soft:      soft,
+		DealerUp:  up,
+		HandIndex: i,
+		Hands:     len(hands),
+		Allowed:   []bjAction{bjHit, bjStand},
+		Bankroll:  g.bankroll(),
+	}
+	free := g.bankroll() - committed
+	if len(h.Cards) != 2 {
+		return v
+	}
+	if (!h.Split || g.rules.DoubleAfterSplit) && free >= h.Bet {
+		v.Allowed = append(v.Allowed, bjDouble)
+	}
+	if bjCardValue(h.Cards[0]) == bjCardValue(h.Cards[1]) && len(hands) <= g.rules.MaxSplits && free >= h.Bet {
+		v.Allowed = append(v.Allowed, bjSplit)
+	}
+	if g.rules.Surrender && len(hands) == 1 && !h.Split {
+		v.Allowed = append(v.Allowed, bjSurrender)
+	}
+	return v
+}
+
+// hit draws a card from the shoe onto cards.
+func (g *blackjack) hit(cards *deck) error {
+	card, err := g.shoe.draw()
+	if err != nil {
+		return err
+	}
+	*cards = append(*cards, card)
+	return nil
+}
+
+// endRound discards every card in play and lets the shoe reshuffle if its policy says so.
+func (g *blackjack) endRound(hands []*bjHand, dealerCards deck) {
+	for _, h := range hands {
+		g.shoe.discard(h.Cards...)
+	}
+	g.shoe.discard(dealerCards...)
+	if g.shoe.endHand() && g.rules.Reshuffle == reshuffleAtPenetration {
+		fmt.Fprintln(g.out, "The cut card is out - shuffling the shoe.")
+	}
+}
+
+// dealerHits is the fixed dealer rule: hit below 17, and hit a soft 17 if the table says so.
+func (g *blackjack) dealerHits(cards deck) bool {
+	total, soft := blackjackTotal(cards)
+	return total < 17 || (total == 17 && soft && g.rules.HitSoft17)
+}
+
+func (g *blackjack) finishRound(net float64) {
+	s := &g.stats
+	s.Rounds++
+	s.Net += net
+	s.sumSquares += net * net
+	s.Bankroll += net
+	s.MaxBankroll = max(s.MaxBankroll, s.Bankroll)
+	s.MinBankroll = min(s.MinBankroll, s.Bankroll)
+	fmt.Fprintf(g.out, "Result: %+.2f, bankroll %.2f\n\n", net, s.Bankroll)
+}
+
+func describeBJHand(cards deck) string {
+	names := make([]string, len(cards))
+	for i, card := range cards {
+		names[i] = card.String()
+	}
+	total, soft := blackjackTotal(cards)
+	kind := ""
+	if soft && total < 21 {
+		kind = "soft "
+	}
+	return fmt.Sprintf("%s ( %s%d )", strings.Join(names, ", "), kind, total)
+}
+
+// simulateBlackjack plays rounds with a flat bet until the rounds are done or the bankroll can't cover the bet.
+func simulateBlackjack(rules bjRules, p bjPlayer, rounds int, bet, bankroll float64, seed int64) (bjStats, error) {
+	g, err := newBlackjack(rules, bankroll, seed, nil)
+	if err != nil {
+		return bjStats{}, err
+	}
+	for range rounds {
+		if g.bankroll() < bet {
+			break
+		}
+		if _, err := g.playRound(p, bet); err != nil {
+			return g.stats, err
+		}
+	}
+	return g.stats, nil
+}
+
+// basicStrategy is the standard multi deck basic strategy. It never takes insurance.
+type basicStrategy struct{}
+
+func (basicStrategy) insurance(bjView) bool { return false }
+
+func (basicStrategy) decide(v bjView) bjAction {
+	up := bjCardValue(v.DealerUp)
+	between := func(lo, hi int) bool { return up >= lo && up <= hi }
+	// orElse falls back when the preferred action isn't allowed, e.g. double after 3 cards
+	orElse := func(want, fallback bjAction) bjAction {
+		if v.can(want) {
+			return want
+		}
+		return fallback
+	}
+
+	if v.can(bjSplit) {
+		switch bjCardValue(v.Hand[0]) {
+		case 11, 8:
+			return bjSplit
+		case 9:
+			if between(2, 6) || up == 8 || up == 9 {
+				return bjSplit
+			}
+		case 7, 3, 2:
+			if between(2, 7) {
+				return bjSplit
+			}
+		case 6:
+			if between(2, 6) {
+				return bjSplit
+			}
+		case 4:
+			if between(5, 6) {
+				return bjSplit
+			}
+		}
+	}
+
+	if v.can(bjSurrender) && !v.Soft {
+		if (v.Total == 16 && up >= 9) || (v.Total == 15 && up == 10) {
+			return bjSurrender
+		}
+	}
+
+	if v.Soft {
+		switch {
+		case v.Total >= 19:
+			return bjStand
+		case v.Total == 18:
+			if between(3, 6) {
+				return orElse(bjDouble, bjStand)
+			}
+			if up <= 8 {
+				return bjStand
+			}
+			return bjHit
+		case v.Total == 17 && between(3, 6):
+			return orElse(bjDouble, bjHit)
+		case v.Total >= 15 && between(4, 6):
+			return orElse(bjDouble, bjHit)
+		case v.Total >= 13 && between(5, 6):
+			return orElse(bjDouble, bjHit)
+		}
+		return bjHit
+	}
+
+	switch {
+	case v.Total >= 17:
+		return bjStand
+	case v.Total >= 13:
+		if between(2, 6) {
+			return bjStand
+		}
+		return bjHit
+	case v.Total == 12:
+		if between(4, 6) {
+			return bjStand
+		}
+		return bjHit
+	case v.Total == 11:
+		if up <= 10 {
+			return orElse(bjDouble, bjHit)
+		}
+		return bjHit
+	case v.Total == 10:
+		if up <= 9 {
+			return orElse(bjDouble, bjHit)
+		}
+		return bjHit
+	case v.Total == 9:
+		if between(3, 6) {
+			return orElse(bjDouble, bjHit)
+		}
+	}
+	return bjHit
+}
+
+// mimicDealerStrategy plays like the dealer: hit below 17, never double or split.
+type mimicDealerStrategy struct{}
+
+func (mimicDealerStrategy) insurance(bjView) bool { return false }
+
+func (mimicDealerStrategy) decide(v bjView) bjAction {
+	if v.Total < 17 {
+		return bjHit
+	}
+	return bjStand
+}
+
+// bjStrategies are the strategies that can be picked by name for headless games.
+var bjStrategies = map[string]bjPlayer{
+	"basic":  basicStrategy{},
+	"dealer": mimicDealerStrategy{},
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// terminalPlayer is a bjPlayer that asks a person at the terminal for every decision.
+type terminalPlayer struct {
+	in  *bufio.Scanner
+	out io.Writer
+	// quit is set when the input runs out, the hand in play is then stood.
+	quit bool
+}
+
+func newTerminalPlayer(in io.Reader, out io.Writer) *terminalPlayer {
+	return &terminalPlayer{in: bufio.NewScanner(in), out: out}
+}
+
+// ask prints the question and returns the next line of input in lower case.
+func (t *terminalPlayer) ask(question string) (string, bool) {
+	fmt.Fprint(t.out, question)
+	if !t.in.Scan() {
+		t.quit = true
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSpace(t.in.Text())), true
+}
+
+func (t *terminalPlayer) insurance(bjView) bool {
+	answer, _ := t.ask("Dealer shows an Ace. Insurance? [y/N] ")
+	return answer == "y" || answer == "yes"
+}
+
+func (t *terminalPlayer) decide(v bjView) bjAction {
+	keys := map[string]bjAction{"h": bjHit, "s": bjStand, "d": bjDouble, "p": bjSplit, "r": bjSurrender}
+	var choices []string
+	for _, a := range v.Allowed {
+		choices = append(choices, fmt.Sprintf("(%s)%s", a.String()[:1], a.String()[1:]))
+	}
+	// split is picked with p - the s is taken by stand
+	prompt := strings.Replace(strings.Join(choices, " "), "(s)plit", "s(p)lit", 1)
+	prompt = strings.Replace(prompt, "(s)urrender", "su(r)render", 1)
+
+	for {
+		answer, ok := t.ask(fmt.Sprintf("%d%s: %s? ", v.Total, softLabel(v.Soft), prompt))
+		if !ok {
+			return bjStand
+		}
+		if action, found := keys[answer]; found && v.can(action) {
+			return action
+		}
+		fmt.Fprintln(t.out, "Not an option, try again.")
+	}
+}
+
+func softLabel(soft bool) string {
+	if soft {
+		return " soft"
+	}
+	return ""
+}
+
+// playBlackjackTerminal is the interactive game: the player picks a bet every round until
+// they quit or the bankroll is gone.
+func playBlackjackTerminal(in io.Reader, out io.Writer, rules bjRules, bankroll, bet float64, seed int64) error {
+	player := newTerminalPlayer(in, out)
+	g, err := newBlackjack(rules, bankroll, seed, out)
+	if err != nil {
+		return err
+	}
+
+	for g.bankroll() > 0 {
+		answer, ok := player.ask(fmt.Sprintf("Bankroll %.2f. Bet [%.2f] or (q)uit: ", g.bankroll(), bet))
+		if !ok || answer == "q" || answer == "quit" {
+			break
+		}
+		if answer != "" {
+			b, err := strconv.ParseFloat(answer, 64)
+			if err != nil || b <= 0 {
+				fmt.Fprintln(out, "That's not a bet.")
+				continue
+			}
+			bet = b
+		}
+		if bet > g.bankroll() {
+			fmt.Fprintln(out, "You can't cover that bet.")
+			continue
+		}
+		if _, err := g.playRound(player, bet); err != nil {
+			return err
+		}
+		if player.quit {
+			break
+		}
+	}
+
+	fmt.Fprintln(out)
+	g.stats.print(out)
+	return nil
+}
+
+// runBlackjack is the "cards blackjack" command: an interactive game, or a headless one with -rounds.
+func runBlackjack(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "blackjack")
+	rules := defaultBlackjackRules()
+	fs.IntVar(&rules.Decks, "decks", rules.Decks, "number of decks in the shoe")
+	fs.Float64Var(&rules.Penetration, "penetration", rules.Penetration, "part of the shoe dealt before reshuffling")
+	reshuffle := fs.String("reshuffle", rules.Reshuffle.String(), "when to shuffle the shoe: "+strings.Join(reshufflePolicyNames, ", "))
+	fs.BoolVar(&rules.HitSoft17, "h17", rules.HitSoft17, "dealer hits soft 17")
+	fs.BoolVar(&rules.Surrender, "surrender", rules.Surrender, "allow late surrender")
+	fs.BoolVar(&rules.DoubleAfterSplit, "das", rules.DoubleAfterSplit, "allow double after split")
+	fs.IntVar(&rules.MaxSplits, "max-splits", rules.MaxSplits, "how many times a hand can be split")
+	bankroll := fs.Float64("bankroll", 1000, "starting bankroll")
+	bet := fs.Float64("bet", 10, "bet per round")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "seed for the shoe")
+	rounds := fs.Int("rounds", 0, "play this many rounds headless instead of interactive")
+	strategy := fs.String("strategy", "basic", "strategy for headless play: "+strings.Join(bjStrategyNames(), ", "))
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	policy, err := parseReshufflePolicy(*reshuffle)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+	rules.Reshuffle = policy
+
+	if *bet <= 0 {
+		return usagef("bet must be positive")
+	}
+	if *rounds <= 0 {
+		return playBlackjackTerminal(env.stdin, env.stdout, rules, *bankroll, *bet, *seed)
+	}
+
+	p, ok := bjStrategies[*strategy]
+	if !ok {
+		return usagef("unknown strategy %q", *strategy)
+	}
+	stats, err := simulateBlackjack(rules, p, *rounds, *bet, *bankroll, *seed)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stdout, "Strategy %q, seed %d\n", *strategy, *seed)
+	stats.print(env.stdout)
+	return nil
+}
+
+func bjStrategyNames() []string {
+	names := make([]string, 0, len(bjStrategies))
+	for name := range bjStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBlackjackTotal(t *testing.T) {
+	tests := []struct {
+		cards string
+		total int
+		soft  bool
+	}{
+		{"As Kd", 21, true},
+		{"As 6d", 17, true},
+		{"As 6d Kh", 17, false},
+		{"As Ad", 12, true},
+		{"As Ad 9h", 21, true},
+		{"Ts 6d", 16, false},
+		{"Ts 6d 7h", 23, false},
+		{"As Ad Ah Ac", 14, true},
+	}
+	for _, tt := range tests {
+		total, soft := blackjackTotal(cardsFromCodes(t, tt.cards))
+		if total != tt.total || soft != tt.soft {
+			t.Errorf("%s: expected %v soft %v, got %v soft %v", tt.cards, tt.total, tt.soft, total, soft)
+		}
+	}
+	if !isBlackjack(cardsFromCodes(t, "Js Ah")) || isBlackjack(cardsFromCodes(t, "7s 7h 7d")) {
+		t.Errorf("Expected only two cards of 21 to be a blackjack")
+	}
+}
+
+func TestDealerHitsSoft17(t *testing.T) {
+	soft17 := cardsFromCodes(t, "As 6d")
+	stand := &blackjack{rules: defaultBlackjackRules()}
+	if stand.dealerHits(soft17) {
+		t.Errorf("Expected the dealer to stand on soft 17")
+	}
+	rules := defaultBlackjackRules()
+	rules.HitSoft17 = true
+	hit := &blackjack{rules: rules}
+	if !hit.dealerHits(soft17) || hit.dealerHits(cardsFromCodes(t, "Ts 7d")) {
+		t.Errorf("Expected the dealer to hit soft 17 only")
+	}
+}
+
+func TestBasicStrategy(t *testing.T) {
+	tests := []struct {
+		hand, up string
+		want     bjAction
+	}{
+		{"8s 8d", "Th", bjSplit},
+		{"As Ad", "6h", bjSplit},
+		{"Ts Kd", "6h", bjStand},
+		{"5s 5d", "6h", bjDouble},
+		{"6s 5d", "Th", bjDouble},
+		{"Ts 6d", "Th", bjSurrender},
+		{"Ts 6d", "7h", bjHit},
+		{"Ts 3d", "4h", bjStand},
+		{"As 7d", "9h", bjHit},
+		{"As 7d", "7h", bjStand},
+		{"As 7d", "5h", bjDouble},
+		{"Ts 2d", "2h", bjHit},
+	}
+	for _, tt := range tests {
+		hand := cardsFromCodes(t, tt.hand)
+		total, soft := blackjackTotal(hand)
+		allowed := []bjAction{bjHit, bjStand, bjDouble, bjSurrender}
+		if bjCardValue(hand[0]) == bjCardValue(hand[1]) {
+			allowed = append(allowed, bjSplit)
+		}
+		v := bjView{Hand: hand, Total: total, Soft: soft, DealerUp: cardsFromCodes(t, tt.up)[0], Allowed: allowed}
+		if got := (basicStrategy{}).decide(v); got != tt.want {
+			t.Errorf("%s against %s: expected %v, got %v", tt.hand, tt.up, tt.want, got)
+		}
+	}
+
+	// without the double option a soft 18 against a 5 stands
+	v := bjView{Hand: cardsFromCodes(t, "As 7d"), Total: 18, Soft: true, DealerUp: Card{Rank: Five, Suit: Clubs}, Allowed: []bjAction{bjHit, bjStand}}
+	if got := (basicStrategy{}).decide(v); got != bjStand {
+		t.Errorf("Expected stand when double isn't allowed, got %v", got)
+	}
+}
+
+// cheater always asks for an action the table doesn't offer.
+type cheater struct{}
+
+func (cheater) insurance(bjView) bool  { return false }
+func (cheater) decide(bjView) bjAction { return bjAction(99) }
+
+func TestPlayRoundRejectsIllegalActions(t *testing.T) {
+	g, err := newBlackjack(defaultBlackjackRules(), 1000, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 20 {
+		if _, err := g.playRound(cheater{}, 10); err != nil {
+			return
+		}
+	}
+	t.Errorf("Expected an illegal action error")
+}
+
+// A long headless run must keep the books straight and give a sane edge for basic strategy.
+func TestSimulateBlackjack(t *testing.T) {
+	stats, err := simulateBlackjack(defaultBlackjackRules(), basicStrategy{}, 20000, 10, 1e9, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Rounds != 20000 {
+		t.Errorf("Expected 20000 rounds, got %v", stats.Rounds)
+	}
+	if stats.Wins+stats.Losses+stats.Pushes != stats.Hands {
+		t.Errorf("Expected every hand to be won, lost or pushed: %+v", stats)
+	}
+	if diff := stats.Bankroll - stats.StartBankroll - stats.Net; diff > 1e-6 || diff < -1e-6 {
+		t.Errorf("Expected the bankroll to change by the net result: %+v", stats)
+	}
+	if stats.Splits == 0 || stats.Doubles == 0 || stats.Surrenders == 0 || stats.Blackjacks == 0 {
+		t.Errorf("Expected every kind of play to happen: %+v", stats)
+	}
+	// basic strategy is within a few percent of even, mimicking the dealer is clearly worse
+	if edge := stats.edge(); edge < -0.05 || edge > 0.05 {
+		t.Errorf("Expected an edge close to 0 for basic strategy, got %v", edge)
+	}
+
+	again, _ := simulateBlackjack(defaultBlackjackRules(), basicStrategy{}, 20000, 10, 1e9, 7)
+	if again != stats {
+		t.Errorf("Expected the same seed to play the same game")
+	}
+}
+
+func TestSimulateBlackjackStopsWhenBroke(t *testing.T) {
+	stats, err := simulateBlackjack(defaultBlackjackRules(), mimicDealerStrategy{}, 100000, 10, 100, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Rounds == 100000 && stats.Bankroll < 10 {
+		t.Errorf("Expected the game to stop once the bankroll can't cover the bet")
+	}
+	if stats.Bankroll < 0 {
+		t.Errorf("Expected the bankroll to never go below 0, got %v", stats.Bankroll)
+	}
+}
+
+func TestPlayBlackjackTerminal(t *testing.T) {
+	// bet the default, then stand on everything, then quit
+	in := strings.NewReader("\ns\ns\ns\nq\n")
+	var out bytes.Buffer
+	if err := playBlackjackTerminal(in, &out, defaultBlackjackRules(), 100, 10, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Dealer shows") || !strings.Contains(out.String(), "Rounds:") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+)
+
+// The bots play the shedding game of the card table ( see table.go ): on their turn the
+// players draw a card, play a card of the suit or the rank of the top of the pile, or pass.
+// The first player without cards wins. Every bot is a Player, so the engine and the
+// tournament don't know which strategy they are running.
+
+// sheddingMaxTurns ends a game that goes on forever as a draw.
+const sheddingMaxTurns = 1000
+
+// sheddingMove is the kind of action of a turn.
+type sheddingMove int
+
+const (
+	moveDraw sheddingMove = iota
+	movePlay
+	movePass
+)
+
+var sheddingMoveNames = []string{"draw", "play", "pass"}
+
+func (m sheddingMove) String() string {
+	if m < 0 || int(m) >= len(sheddingMoveNames) {
+		return fmt.Sprintf("sheddingMove(%d)", int(m))
+	}
+	return sheddingMoveNames[m]
+}
+
+// sheddingAction is what a player does on its turn. Card is only set to play it.
+type sheddingAction struct {
+	Move sheddingMove
+	Card Card
+}
+
+func (a sheddingAction) String() string {
+	if a.Move == movePlay {
+		return "play " + a.Card.String()
+	}
+	return a.Move.String()
+}
+
+// sheddingView is what a player can see on its turn: its own hand and everything on the table.
+type sheddingView struct {
+	Seat int
+	Hand deck
+	// Pile are the played cards, the last one is the top.
+	Pile deck
+	// Deck is the number of cards left to draw.
+	Deck int
+	// Cards is the number of cards in every hand, by seat.
+	Cards []int
+	// Legal are the actions allowed now.
+	Legal []sheddingAction
+}
+
+// Player is a strategy for the shedding game: it gets the view of its turn and picks one of
+// the legal actions.
+type Player interface {
+	play(v sheddingView) sheddingAction
+}
+
+// canPlayOn is the rule of the game: a card goes on the pile when it has the suit or the rank of the top.
+func canPlayOn(card, top Card) bool {
+	return card.Suit == top.Suit || card.Rank == top.Rank
+}
+
+// sheddingGame is the engine of one game. The top of the deck is its first card, like the table.
+type sheddingGame struct {
+	deck  deck
+	pile  deck
+	hands []deck
+	turn  int
+	turns int
+	// passes counts the passes in a row with an empty deck - once everybody passed nobody can move.
+	passes int
+	winner int
+}
+
+// newSheddingGame shuffles a new deck, deals handSize cards to every player and turns up the first card of the rest.
+func newSheddingGame(players, handSize int, r *rand.Rand) (*sheddingGame, error) {
+	if players < 2 {
+		return nil, fmt.Errorf("need at least 2 players, got %d", players)
+	}
+	cards := newDeck()
+	cards.shuffleWith(r)
+	dl := newDealer(cards)
+	hands, err := dl.dealHands(players, handSize, dealRoundRobin)
+	if err != nil {
+		return nil, err
+	}
+	pile, err := dl.take(1)
+	if err != nil {
+		return nil, err
+	}
+	return &sheddingGame{deck: dl.cards, pile: pile, hands: hands, winner: -1}, nil
+}
+
+// over reports whether the game has ended: somebody won, nobody can move or it took too long.
+func (g *sheddingGame) over() bool {
+	return g.winner >= 0 || g.passes >= len(g.hands) || g.turns >= sheddingMaxTurns
+}
+
+func (g *sheddingGame) legal() []sheddingAction {
+	var actions []sheddingAction
+	top := g.pile[len(g.pile)-1]
+	for _, card := range g.hands[g.turn] {
+		if canPlayOn(card, top) {
+			actions = append(actions, sheddingAction{Move: movePlay, Card: card})
+		}
+	}
+	if len(g.deck) > 0 {
+		actions = append(actions, sheddingAction{Move: moveDraw})
+	}
+	return append(actions, sheddingAction{Move: movePass})
+}
+
+// view is what the player whose turn it is can see. The slices are copies.
+func (g *sheddingGame) view() sheddingView {
+	v := sheddingView{Seat: g.turn, Hand: slices.Clone(g.hands[g.turn]), Pile: slices.Clone(g.pile), Deck: len(g.deck), Legal: g.legal()}
+	for _, hand := range g.hands {
+		v.Cards = append(v.Cards, len(hand))
+	}
+	return v
+}
+
+// apply plays the action for the player whose turn it is. Actions that aren't legal return
+// ErrIllegalMove ( the error of klondike.go ) and change nothing.
+func (g *sheddingGame) apply(a sheddingAction) error {
+	if g.over() {
+		return errors.New("the game is over")
+	}
+	hand := &g.hands[g.turn]
+	switch a.Move {
+	case moveDraw:
+		if len(g.deck) == 0 {
+			return fmt.Errorf("%w: the deck is empty", ErrIllegalMove)
+		}
+		*hand = append(*hand, g.deck[0])
+		g.deck = g.deck[1:]
+		g.passes = 0
+	case movePlay:
+		i := slices.Index(*hand, a.Card)
+		if i < 0 || !canPlayOn(a.Card, g.pile[len(g.pile)-1]) {
+			return fmt.Errorf("%w: can't play %v", ErrIllegalMove, a.Card)
+		}
+		*hand = slices.Delete(*hand, i, i+1)
+		g.pile = append(g.pile, a.Card)
+		g.passes = 0
+		if len(*hand) == 0 {
+			g.winner = g.turn
+		}
+	case movePass:
+		if len(g.deck) == 0 {
+			g.passes++
+		}
+	default:
+		return fmt.Errorf("%w: %v", ErrIllegalMove, a)
+	}
+	g.turns++
+	if g.winner < 0 {
+		g.turn = (g.turn + 1) % len(g.hands)
+	}
+	return nil
+}
+
+// playShedding plays a game to the end, the players by seat. It returns the winner, -1 for a draw.
+func playShedding(g *sheddingGame, players []Player) (int, error) {
+	if len(players) != len(g.hands) {
+		return -1, fmt.Errorf("%d players for %d hands", len(players), len(g.hands))
+	}
+	for !g.over() {
+		if err := g.apply(players[g.turn].play(g.view())); err != nil {
+			return -1, fmt.Errorf("seat %d: %w", g.turn, err)
+		}
+	}
+	return g.winner, nil
+}
+
+// randomBot picks any legal action.
+type randomBot struct {
+	r *rand.Rand
+}
+
+func (b randomBot) play(v sheddingView) sheddingAction {
+	return v.Legal[b.r.Intn(len(v.Legal))]
+}
+
+// rulesBot is the rule of thumb: play when it can, else draw, pass only when there is nothing else.
+// Out of the playable cards it picks one of the suit it holds most of - the next players have to
+// follow that suit and the bot is the most likely to follow it again.
+type rulesBot struct{}
+
+func (rulesBot) play(v sheddingView) sheddingAction {
+	suits := map[Suit]int{}
+	for _, card := range v.Hand {
+		suits[card.Suit]++
+	}
+	best, bestScore := sheddingAction{}, -1
+	for _, a := range v.Legal {
+		if a.Move != movePlay {
+			continue
+		}
+		// after this card the next player must follow its suit, so the more of the suit are left the better
+		score := suits[a.Card.Suit] - 1
+		if bestScore < 0 || score > bestScore {
+			best, bestScore = a, score
+		}
+	}
+	if bestScore >= 0 {
+		return best
+	}
+	if v.Deck > 0 {
+		return sheddingAction{Move: moveDraw}
+	}
+	return sheddingAction{Move: movePass}
+}
+
+// monteCarloBot tries every legal action on random guesses of the hidden cards and keeps the one
+// that wins most often. The guesses deal the cards it can't see ( not in its hand or on the pile )
+// to the other hands and the deck, then everybody plays rulesBot to the end of the game.
+type monteCarloBot struct {
+	r *rand.Rand
+	// samples is the number of games played out for every action.
+	samples int
+}
+
+// defaultMonteCarloSamples is the samples of the monteCarloBot when not given.
+const defaultMonteCarloSamples = 20
+
+func (b monteCarloBot) play(v sheddingView) sheddingAction {
+	if len(v.Legal) == 1 {
+		return v.Legal[0]
+	}
+	seen := slices.Concat(v.Hand, v.Pile)
+	var hidden deck
+	for _, card := range newDeck() {
+		if !slices.Contains(seen, card) {
+			hidden = append(hidden, card)
+		}
+	}
+
+	players := make([]Player, len(v.Cards))
+	for i := range players {
+		players[i] = rulesBot{}
+	}
+	best, bestWins := v.Legal[0], -1
+	for _, a := range v.Legal {
+		wins := 0
+		for range b.samples {
+			g := b.guess(v, hidden)
+			if err := g.apply(a); err != nil {
+				break
+			}
+			if winner, err := playShedding(g, players); err == nil && winner == v.Seat {
+				wins++
+			}
+		}
+		if wins > bestWins {
+			best, bestWins = a, wins
+		}
+	}
+	return best
+}
+
+// guess builds a game that looks like the view, the hidden cards dealt at random.
+func (b monteCarloBot) guess(v sheddingView, hidden deck) *sheddingGame {
+	cards := slices.Clone(hidden)
+	b.r.Shuffle(len(cards), func(i, j int) { cards[i], cards[j] = cards[j], cards[i] })
+	g := &sheddingGame{pile: slices.Clone(v.Pile), turn: v.Seat, winner: -1}
+	for seat, n := range v.Cards {
+		if seat == v.Seat {
+			g.hands = append(g.hands, slices.Clone(v.Hand))
+			continue
+		}
+		g.hands = append(g.hands, slices.Clone(cards[:n]))
+		cards = cards[n:]
+	}
+	g.deck = cards
+	return g
+}
+
+// bots are the strategies that can be picked by name. Every game gets new bots from its own
+// random generator, so a game plays the same whatever goroutine runs it.
+// samples is only used by the Monte Carlo bot.
+var bots = map[string]func(r *rand.Rand, samples int) Player{
+	"random":     func(r *rand.Rand, _ int) Player { return randomBot{r: r} },
+	"rules":      func(*rand.Rand, int) Player { return rulesBot{} },
+	"montecarlo": func(r *rand.Rand, samples int) Player { return monteCarloBot{r: r, samples: samples} },
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestSheddingGameRules(t *testing.T) {
+	g := &sheddingGame{
+		deck:   cardsFromCodes(t, "2c"),
+		pile:   cardsFromCodes(t, "9h"),
+		hands:  []deck{cardsFromCodes(t, "9s Kh"), cardsFromCodes(t, "3d")},
+		winner: -1,
+	}
+	if err := g.apply(sheddingAction{Move: movePlay, Card: cardsFromCodes(t, "3d")[0]}); !errors.Is(err, ErrIllegalMove) {
+		t.Errorf("Expected a card not in the hand to be illegal, got %v", err)
+	}
+	if len(g.legal()) != 4 {
+		t.Errorf("Expected 2 cards to play, draw and pass, got %v", g.legal())
+	}
+	if err := g.apply(sheddingAction{Move: movePlay, Card: cardsFromCodes(t, "9s")[0]}); err != nil {
+		t.Fatal(err)
+	}
+	// the 3 of Diamonds doesn't go on the 9 of Spades
+	if err := g.apply(sheddingAction{Move: movePlay, Card: cardsFromCodes(t, "3d")[0]}); !errors.Is(err, ErrIllegalMove) {
+		t.Errorf("Expected a card of another suit and rank to be illegal, got %v", err)
+	}
+	g.apply(sheddingAction{Move: moveDraw})
+	if err := g.apply(sheddingAction{Move: moveDraw}); !errors.Is(err, ErrIllegalMove) {
+		t.Errorf("Expected no draw from an empty deck, got %v", err)
+	}
+
+	// with nothing to draw and nothing to play everybody passes, and the game is a draw
+	g.apply(sheddingAction{Move: movePass})
+	g.apply(sheddingAction{Move: movePass})
+	if !g.over() || g.winner != -1 {
+		t.Errorf("Expected a draw after everybody passed, got over %v winner %v", g.over(), g.winner)
+	}
+}
+
+func TestSheddingGameWin(t *testing.T) {
+	g := &sheddingGame{pile: cardsFromCodes(t, "9h"), hands: []deck{cardsFromCodes(t, "3d"), cardsFromCodes(t, "Kh")}, winner: -1, turn: 1}
+	winner, err := playShedding(g, []Player{rulesBot{}, rulesBot{}})
+	if err != nil || winner != 1 {
+		t.Errorf("Expected the second player to go out, got %v ( %v )", winner, err)
+	}
+}
+
+// Whatever the seed, the bots only pick legal actions and every game ends.
+func TestBotsPlayLegalGames(t *testing.T) {
+	for name, newBot := range bots {
+		for seed := range int64(20) {
+			r := rand.New(rand.NewSource(seed))
+			g, err := newSheddingGame(3, 5, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			players := []Player{newBot(r, 4), rulesBot{}, randomBot{r: r}}
+			if _, err := playShedding(g, players); err != nil {
+				t.Fatalf("%s, seed %d: %v", name, seed, err)
+			}
+			if !g.over() {
+				t.Errorf("%s, seed %d: expected the game to be over", name, seed)
+			}
+		}
+	}
+}
+
+func TestRulesBot(t *testing.T) {
+	v := sheddingView{
+		Hand: cardsFromCodes(t, "9s 2c 5c Jc 4h"),
+		Pile: cardsFromCodes(t, "9h"),
+		Deck: 10,
+	}
+	v.Legal = []sheddingAction{
+		{Move: movePlay, Card: v.Hand[0]},
+		{Move: movePlay, Card: v.Hand[4]},
+		{Move: moveDraw},
+		{Move: movePass},
+	}
+	// two playable cards: the 9 of Spades has no other spade, the 4 of Hearts no other heart - the first wins the tie
+	if a := (rulesBot{}).play(v); a.Move != movePlay || a.Card != v.Hand[0] {
+		t.Errorf("Expected to play the 9 of Spades, got %v", a)
+	}
+
+	v.Hand = cardsFromCodes(t, "9s 2h 5h 4h")
+	v.Legal[1].Card = v.Hand[3]
+	if a := (rulesBot{}).play(v); a.Card != v.Hand[3] {
+		t.Errorf("Expected to stay in hearts, the longest suit, got %v", a)
+	}
+
+	v.Legal = v.Legal[2:]
+	if a := (rulesBot{}).play(v); a.Move != moveDraw {
+		t.Errorf("Expected to draw without a card to play, got %v", a)
+	}
+}
+
+func TestMonteCarloBotTakesTheWin(t *testing.T) {
+	g := &sheddingGame{
+		deck:   cardsFromCodes(t, "2c 3c 4c 5c"),
+		pile:   cardsFromCodes(t, "9h"),
+		hands:  []deck{cardsFromCodes(t, "Kh"), cardsFromCodes(t, "7d 8d")},
+		winner: -1,
+	}
+	bot := monteCarloBot{r: rand.New(rand.NewSource(1)), samples: 5}
+	if a := bot.play(g.view()); a.Move != movePlay {
+		t.Errorf("Expected to play the last card, got %v", a)
+	}
+}
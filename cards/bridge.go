@@ -0,0 +1,553 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Contract bridge: four players in two partnerships, North-South against East-West, get 13 cards
+// each. This file deals the boards, looks at the hands the way bidders do ( high card points and
+// shape ), deals boards that meet the constraints of a bidding exercise and scores the contracts
+// like a duplicate tournament does. PBN, the notation the bridge programs exchange deals in,
+// is in notation.go.
+
+// bridgeSeat is a place at the bridge table. The seats go clockwise from North.
+type bridgeSeat int
+
+const (
+	north bridgeSeat = iota
+	east
+	south
+	west
+)
+
+var bridgeSeatNames = []string{"North", "East", "South", "West"}
+
+func (s bridgeSeat) String() string {
+	if s < north || s > west {
+		return fmt.Sprintf("bridgeSeat(%d)", int(s))
+	}
+	return bridgeSeatNames[s]
+}
+
+// letter is the seat in PBN: N, E, S or W.
+func (s bridgeSeat) letter() string {
+	return s.String()[:1]
+}
+
+// next is the seat on the left, the next one to play.
+func (s bridgeSeat) next() bridgeSeat {
+	return (s + 1) % 4
+}
+
+// northSouth tells the partnership of the seat.
+func (s bridgeSeat) northSouth() bool {
+	return s == north || s == south
+}
+
+func parseBridgeSeat(name string) (bridgeSeat, bool) {
+	for s := north; s <= west; s++ {
+		if strings.EqualFold(name, s.letter()) || strings.EqualFold(name, s.String()) {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// bridgeDeal is the four hands of a board, by seat. First is the seat the deal starts from: the
+// dealer when it's dealt here, the first hand of the string when it's read from PBN.
+// An empty hand is one that isn't known ( "-" in PBN ).
+type bridgeDeal struct {
+	First bridgeSeat
+	Hands [4]deck
+}
+
+// cards is the deal as one deck, North's hand first.
+func (deal bridgeDeal) cards() deck {
+	return slices.Concat(deal.Hands[:]...)
+}
+
+// dealBridge deals the 52 cards of the dealer one at a time, starting on the left of the dealer.
+func dealBridge(dl *dealer, first bridgeSeat) (bridgeDeal, error) {
+	if dl.remaining() != 52 {
+		return bridgeDeal{}, fmt.Errorf("a bridge deal needs the 52 cards, the deck has %d", dl.remaining())
+	}
+	hands, err := dl.dealHands(4, 13, dealRoundRobin)
+	if err != nil {
+		return bridgeDeal{}, err
+	}
+	deal := bridgeDeal{First: first}
+	for i, hand := range hands {
+		deal.Hands[(int(first)+1+i)%4] = hand
+	}
+	return deal, nil
+}
+
+// hcp are the high card points of the hand, the usual count of its strength: 4 for an Ace, 3 for
+// a King, 2 for a Queen and 1 for a Jack. The deck has 40.
+func hcp(hand deck) int {
+	points := 0
+	for _, card := range hand {
+		switch card.Rank {
+		case Ace:
+			points += 4
+		case King:
+			points += 3
+		case Queen:
+			points += 2
+		case Jack:
+			points++
+		}
+	}
+	return points
+}
+
+// handShape is the number of cards of every suit, in the order of pbnSuits: spades, hearts, diamonds, clubs.
+type handShape [4]int
+
+func shapeOf(hand deck) handShape {
+	var sh handShape
+	for _, card := range hand {
+		if i := slices.Index(pbnSuits, card.Suit); i >= 0 {
+			sh[i]++
+		}
+	}
+	return sh
+}
+
+// String is the shape the way bridge players write it, e.g. "5-3-3-2" for five spades.
+func (sh handShape) String() string {
+	return fmt.Sprintf("%d-%d-%d-%d", sh[0], sh[1], sh[2], sh[3])
+}
+
+// balanced is a hand without a void or a singleton and with one doubleton at most:
+// 4-3-3-3, 4-4-3-2 or 5-3-3-2 in any order of the suits.
+func (sh handShape) balanced() bool {
+	sorted := slices.Clone(sh[:])
+	slices.Sort(sorted)
+	return sorted[0] >= 2 && sorted[1] >= 3
+}
+
+// handConstraint is what a hand of a constrained deal has to look like.
+type handConstraint struct {
+	MinHCP, MaxHCP int
+	Balanced       bool
+	// MinLength and MaxLength are the cards of every suit, in the order of pbnSuits.
+	MinLength, MaxLength handShape
+}
+
+// anyHand is the constraint every hand meets.
+var anyHand = handConstraint{MaxHCP: 37, MaxLength: handShape{13, 13, 13, 13}}
+
+// parseHandConstraint reads a constraint written the way the club describes it, the terms
+// separated by spaces or commas:
+//
+//	15-17, 12+, 10-, 8     the high card points ( "hcp" can follow, it's ignored )
+//	balanced               a balanced hand ( see handShape.balanced )
+//	5+s, 4-5h, 0-1d, 3c    the length of a suit: s, h, d or c for spades, hearts, diamonds, clubs
+//
+// e.g. "15-17 hcp balanced" or "12+ 5+h".
+func parseHandConstraint(s string) (handConstraint, error) {
+	c := anyHand
+	terms := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return r == ' ' || r == ',' })
+	for _, term := range terms {
+		if term == "hcp" {
+			continue
+		}
+		if term == "balanced" {
+			c.Balanced = true
+			continue
+		}
+		if suit := strings.IndexByte("shdc", term[len(term)-1]); suit >= 0 {
+			lo, hi, ok := parseBound(term[:len(term)-1], 13)
+			if !ok {
+				return c, fmt.Errorf("bad suit length %q, expected e.g. 5+s or 4-5h", term)
+			}
+			c.MinLength[suit], c.MaxLength[suit] = lo, hi
+			continue
+		}
+		lo, hi, ok := parseBound(strings.TrimSuffix(term, "hcp"), 37)
+		if !ok {
+			return c, fmt.Errorf("unknown term %q, expected points like 15-17, balanced or a suit length like 5+s", term)
+		}
+		c.MinHCP, c.MaxHCP = lo, hi
+	}
+	return c, nil
+}
+
+// parseBound reads "15-17", "15+", "15-" and "15" into the smallest and the biggest number, up to max.
+func parseBound(s string, max int) (int, int, bool) {
+	lo, hi := 0, max
+	var err error
+	switch {
+	case strings.HasSuffix(s, "+"):
+		lo, err = strconv.Atoi(s[:len(s)-1])
+	case strings.HasSuffix(s, "-"):
+		hi, err = strconv.Atoi(s[:len(s)-1])
+	default:
+		from, to, isRange := strings.Cut(s, "-")
+		if lo, err = strconv.Atoi(from); err == nil {
+			hi = lo
+			if isRange {
+				hi, err = strconv.Atoi(to)
+			}
+		}
+	}
+	return lo, hi, err == nil && 0 <= lo && lo <= hi && hi <= max
+}
+
+func (c handConstraint) match(hand deck) bool {
+	if points := hcp(hand); points < c.MinHCP || points > c.MaxHCP {
+		return false
+	}
+	sh := shapeOf(hand)
+	for i, n := range sh {
+		if n < c.MinLength[i] || n > c.MaxLength[i] {
+			return false
+		}
+	}
+	return !c.Balanced || sh.balanced()
+}
+
+// ErrNoDeal is returned when no deal meeting the constraints was found in the allowed tries.
+var ErrNoDeal = errors.New("no deal meets the constraints")
+
+// defaultDealTries is how many deals dealConstrained tries before giving up.
+const defaultDealTries = 1000000
+
+// dealConstrained deals boards until one meets the constraints of every seat and returns it with the
+// number of deals it took. This is rejection sampling: every deal that meets the constraints is
+// as likely as in a real shuffle. The same generator gives the same deals.
+func dealConstrained(r *rand.Rand, dealer bridgeSeat, constraints map[bridgeSeat]handConstraint, tries int) (bridgeDeal, int, error) {
+	if tries <= 0 {
+		tries = defaultDealTries
+	}
+	for try := 1; try <= tries; try++ {
+		d := newDeck()
+		d.shuffleWith(r)
+		deal, err := dealBridge(newDealer(d), dealer)
+		if err != nil {
+			return bridgeDeal{}, try, err
+		}
+		ok := true
+		for seat, c := range constraints {
+			if !c.match(deal.Hands[seat]) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return deal, try, nil
+		}
+	}
+	return bridgeDeal{}, tries, fmt.Errorf("%w after %d deals", ErrNoDeal, tries)
+}
+
+// bridgeVulnerability tells which partnerships are vulnerable, the ones that score more and lose more.
+type bridgeVulnerability int
+
+const (
+	vulNone bridgeVulnerability = iota
+	vulNorthSouth
+	vulEastWest
+	vulAll
+)
+
+// bridgeVulnerabilityNames are the values of the PBN Vulnerable tag.
+var bridgeVulnerabilityNames = []string{"None", "NS", "EW", "All"}
+
+func (v bridgeVulnerability) String() string {
+	if v < vulNone || v > vulAll {
+		return fmt.Sprintf("bridgeVulnerability(%d)", int(v))
+	}
+	return bridgeVulnerabilityNames[v]
+}
+
+func (v bridgeVulnerability) vulnerable(seat bridgeSeat) bool {
+	return v == vulAll || v == vulNorthSouth && seat.northSouth() || v == vulEastWest && !seat.northSouth()
+}
+
+func parseBridgeVulnerability(name string) (bridgeVulnerability, bool) {
+	for v := vulNone; v <= vulAll; v++ {
+		if strings.EqualFold(name, v.String()) {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// boardDealer and boardVulnerability follow the numbering of duplicate boards: the dealer turns
+// every board and the vulnerability goes through a cycle of 16 boards.
+func boardDealer(board int) bridgeSeat {
+	return bridgeSeat((board - 1) % 4)
+}
+
+func boardVulnerability(board int) bridgeVulnerability {
+	i := (board - 1) % 16
+	return bridgeVulnerability((i%4 + i/4) % 4)
+}
+
+// bridgeStrain is the trump suit of a contract, or no trump.
+type bridgeStrain int
+
+const (
+	strainClubs bridgeStrain = iota
+	strainDiamonds
+	strainHearts
+	strainSpades
+	noTrump
+)
+
+var bridgeStrainNames = []string{"C", "D", "H", "S", "NT"}
+
+func (s bridgeStrain) String() string {
+	if s < strainClubs || s > noTrump {
+		return fmt.Sprintf("bridgeStrain(%d)", int(s))
+	}
+	return bridgeStrainNames[s]
+}
+
+// bridgeContract is the final bid of the auction: the declarer's side has to take 6 + Level tricks.
+type bridgeContract struct {
+	Level  int
+	Strain bridgeStrain
+	// Doubled is 1 for a doubled contract and 2 for a redoubled one.
+	Doubled int
+}
+
+// String is the contract as written on the score sheet, e.g. "4S", "3NT" or "6HX".
+func (c bridgeContract) String() string {
+	return strconv.Itoa(c.Level) + c.Strain.String() + strings.Repeat("X", c.Doubled)
+}
+
+// parseContract reads a contract written by String, in any case.
+func parseContract(s string) (bridgeContract, error) {
+	var c bridgeContract
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	rest := strings.TrimRight(upper, "X")
+	c.Doubled = len(upper) - len(rest)
+	if len(rest) < 2 || c.Doubled > 2 {
+		return c, fmt.Errorf("bad contract %q, expected e.g. 4S, 3NT or 6HX", s)
+	}
+	level, err := strconv.Atoi(rest[:1])
+	strain := slices.Index(bridgeStrainNames, rest[1:])
+	if rest[1:] == "N" {
+		strain = int(noTrump)
+	}
+	if err != nil || level < 1 || level > 7 || strain < 0 {
+		return c, fmt.Errorf("bad contract %q, expected a level from 1 to 7 and C, D, H, S or NT", s)
+	}
+	c.Level, c.Strain = level, bridgeStrain(strain)
+	return c, nil
+}
+
+// duplicateScore is the score of the declarer's side for the contract and the tricks it took:
+// positive when the contract was made, negative for the penalty when it went down.
+func duplicateScore(c bridgeContract, vulnerable bool, tricks int) (int, error) {
+	if c.Level < 1 || c.Level > 7 || c.Strain < strainClubs || c.Strain > noTrump || c.Doubled < 0 || c.Doubled > 2 {
+		return 0, fmt.Errorf("bad contract %v", c)
+	}
+	if tricks < 0 || tricks > 13 {
+		return 0, fmt.Errorf("%d tricks, there are 13", tricks)
+	}
+	multiplier := 1 << c.Doubled
+	over := tricks - 6 - c.Level
+
+	if over < 0 {
+		down := -over
+		if c.Doubled == 0 {
+			if vulnerable {
+				return -100 * down, nil
+			}
+			return -50 * down, nil
+		}
+		// doubled: the first trick costs 100 ( 200 vulnerable ), the second and third 200 ( 300 ),
+		// every other 300; redoubled costs twice that
+		penalty := 0
+		for i := 1; i <= down; i++ {
+			switch {
+			case vulnerable && i == 1:
+				penalty += 200
+			case vulnerable:
+				penalty += 300
+			case i == 1:
+				penalty += 100
+			case i <= 3:
+				penalty += 200
+			default:
+				penalty += 300
+			}
+		}
+		return -penalty * multiplier / 2, nil
+	}
+
+	// the tricks bid: 20 a trick in the minors, 30 in the majors, 40 for the first in no trump and then 30
+	trickValue := 30
+	if c.Strain == strainClubs || c.Strain == strainDiamonds {
+		trickValue = 20
+	}
+	contractPoints := trickValue * c.Level * multiplier
+	if c.Strain == noTrump {
+		contractPoints += 10 * multiplier
+	}
+	score := contractPoints
+
+	switch {
+	case contractPoints >= 100 && vulnerable:
+		score += 500
+	case contractPoints >= 100:
+		score += 300
+	default:
+		score += 50
+	}
+	switch {
+	case c.Level == 6 && vulnerable:
+		score += 750
+	case c.Level == 6:
+		score += 500
+	case c.Level == 7 && vulnerable:
+		score += 1500
+	case c.Level == 7:
+		score += 1000
+	}
+	// the "insult" for making a doubled contract
+	score += 50 * c.Doubled
+
+	switch {
+	case c.Doubled == 0:
+		score += over * trickValue
+	case vulnerable:
+		score += over * 100 * multiplier
+	default:
+		score += over * 50 * multiplier
+	}
+	return score, nil
+}
+
+// runBridge is the "cards bridge" command: it deals boards, at random or meeting constraints.
+func runBridge(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "bridge")
+	boards := fs.Int("boards", 1, "number of boards")
+	first := fs.Int("board", 1, "number of the first board, it decides the dealer and the vulnerability")
+	seed := fs.Int64("seed", 0, "seed of the deals, a random one when not set")
+	tries := fs.Int("tries", defaultDealTries, "deals to try for every board before giving up on the constraints")
+	pbn := fs.Bool("pbn", false, "write the boards in PBN for other bridge programs")
+	seatFlags := make([]*string, 4)
+	for s := north; s <= west; s++ {
+		seatFlags[s] = fs.String(strings.ToLower(s.String()), "", `what the hand of `+s.String()+` has to look like, e.g. "15-17 balanced" or "12+ 5+h"`)
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *boards < 1 || *first < 1 {
+		return usagef("-boards and -board start at 1")
+	}
+	constraints := map[bridgeSeat]handConstraint{}
+	for s, text := range seatFlags {
+		if *text == "" {
+			continue
+		}
+		c, err := parseHandConstraint(*text)
+		if err != nil {
+			return usagef("-%s: %v", strings.ToLower(bridgeSeat(s).String()), err)
+		}
+		constraints[bridgeSeat(s)] = c
+	}
+	if !flagSet(fs, "seed") {
+		*seed = time.Now().UnixNano()
+	}
+
+	r := rand.New(rand.NewSource(*seed))
+	for board := *first; board < *first+*boards; board++ {
+		dealer := boardDealer(board)
+		deal, _, err := dealConstrained(r, dealer, constraints, *tries)
+		if err != nil {
+			return fmt.Errorf("board %d: %w", board, err)
+		}
+		if board > *first {
+			fmt.Fprintln(env.stdout)
+		}
+		if *pbn {
+			value, err := formatPBN(deal)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(env.stdout, "[Board %q]\n[Dealer %q]\n[Vulnerable %q]\n[Deal %q]\n",
+				strconv.Itoa(board), dealer.letter(), boardVulnerability(board), value)
+			continue
+		}
+
+		fmt.Fprintf(env.stdout, "Board %d, dealer %v, vulnerable %v\n", board, dealer, boardVulnerability(board))
+		tw := tabwriter.NewWriter(env.stdout, 0, 0, 2, ' ', 0)
+		for s := north; s <= west; s++ {
+			hand := deal.Hands[s]
+			text, err := formatPBNHand(hand)
+			if err != nil {
+				return err
+			}
+			sh := shapeOf(hand)
+			balanced := ""
+			if sh.balanced() {
+				balanced = ", balanced"
+			}
+			fmt.Fprintf(tw, "%v\t%s\t%d HCP\t%v%s\n", s, text, hcp(hand), sh, balanced)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runScore is the "cards score" command: the duplicate score of a bridge contract.
+func runScore(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "score")
+	contract := fs.String("contract", "", "the contract, e.g. 4S, 3NT, 6HX or 2CXX")
+	by := fs.String("by", "N", "the seat of the declarer")
+	vul := fs.String("vul", "None", "the vulnerable side: None, NS, EW or All")
+	tricks := fs.Int("tricks", -1, "the tricks taken by the declarer")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	c, err := parseContract(*contract)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+	declarer, ok := parseBridgeSeat(*by)
+	if !ok {
+		return usagef("unknown seat %q, expected N, E, S or W", *by)
+	}
+	v, ok := parseBridgeVulnerability(*vul)
+	if !ok {
+		return usagef("unknown vulnerability %q, expected None, NS, EW or All", *vul)
+	}
+	if *tricks < 0 {
+		return usagef("-tricks is needed, e.g. -tricks 10")
+	}
+	score, err := duplicateScore(c, v.vulnerable(declarer), *tricks)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+
+	result := "made"
+	if over := *tricks - 6 - c.Level; over < 0 {
+		result = fmt.Sprintf("down %d", -over)
+	} else if over > 0 {
+		result = fmt.Sprintf("made with %d over", over)
+	}
+	side := "North-South"
+	if declarer.northSouth() != (score >= 0) {
+		side = "East-West"
+	}
+	if score < 0 {
+		score = -score
+	}
+	fmt.Fprintf(env.stdout, "%v by %v, %s: %d to %s\n", c, declarer, result, score, side)
+	return nil
+}
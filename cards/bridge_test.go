@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestHandAnalysis(t *testing.T) {
+	tests := []struct {
+		codes    string
+		hcp      int
+		shape    string
+		balanced bool
+	}{
+		{"As Ks Qs Js Ah Kh Qh Ad Kd Qd Ac Kc Qc", 37, "4-3-3-3", true},
+		{"2s 3s 4s 5s 6s 2h 3h 4h 2d 3d 4d 2c 3c", 0, "5-3-3-2", true},
+		{"As 3s 4s 5s 2h 3h 4h 5h Jd 3d 2c 3c Qc", 7, "4-4-2-3", true},
+		{"As 3s 4s 5s 6s 2h 3h 4h 5h Jd 3d 2c 3c", 5, "5-4-2-2", false},
+		{"As Ks 4s 5s 6s 7s 8s 9s Ts Jh Qh Kh Ah", 17, "9-4-0-0", false},
+	}
+	for _, tt := range tests {
+		hand := cardsFromCodes(t, tt.codes)
+		sh := shapeOf(hand)
+		if got := hcp(hand); got != tt.hcp {
+			t.Errorf("%s: expected %d HCP, got %d", tt.codes, tt.hcp, got)
+		}
+		if sh.String() != tt.shape || sh.balanced() != tt.balanced {
+			t.Errorf("%s: expected %s balanced %v, got %v %v", tt.codes, tt.shape, tt.balanced, sh, sh.balanced())
+		}
+	}
+	if got := hcp(newDeck()); got != 40 {
+		t.Errorf("Expected 40 points in the deck, got %d", got)
+	}
+}
+
+func TestParseHandConstraint(t *testing.T) {
+	c, err := parseHandConstraint("15-17 HCP, balanced")
+	if err != nil || c.MinHCP != 15 || c.MaxHCP != 17 || !c.Balanced || c.MaxLength != anyHand.MaxLength {
+		t.Errorf("Expected 15-17 balanced, got %+v ( %v )", c, err)
+	}
+	c, err = parseHandConstraint("12+ 5+h 0-1c")
+	if err != nil || c.MinHCP != 12 || c.MaxHCP != 37 || c.MinLength != (handShape{0, 5, 0, 0}) || c.MaxLength != (handShape{13, 13, 13, 1}) {
+		t.Errorf("Expected 12+ with 5 hearts and a short club, got %+v ( %v )", c, err)
+	}
+	if c, err = parseHandConstraint("10-"); err != nil || c.MinHCP != 0 || c.MaxHCP != 10 {
+		t.Errorf("Expected up to 10, got %+v ( %v )", c, err)
+	}
+	for _, bad := range []string{"17-15", "40", "14s", "5+x", "strong", "-3"} {
+		if _, err := parseHandConstraint(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestDealBridge(t *testing.T) {
+	d := newDeck()
+	deal, err := dealBridge(newDealer(d), west)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// North is on the left of West and gets the first card
+	if deal.Hands[north][0] != d[0] || deal.Hands[east][0] != d[1] || deal.Hands[west][12] != d[51] {
+		t.Errorf("Expected the deal to start on the left of the dealer, got %v", deal.Hands)
+	}
+	if !sameCards(deal.cards(), d) {
+		t.Errorf("Expected the 52 cards in the hands")
+	}
+	if _, err := dealBridge(newDealer(d[:40]), north); err == nil {
+		t.Errorf("Expected an error for a short deck")
+	}
+}
+
+func TestDealConstrained(t *testing.T) {
+	constraints := map[bridgeSeat]handConstraint{north: {MinHCP: 15, MaxHCP: 17, Balanced: true, MaxLength: anyHand.MaxLength}}
+	constraints[south], _ = parseHandConstraint("5+s 8+")
+	for seed := range int64(20) {
+		deal, tries, err := dealConstrained(rand.New(rand.NewSource(seed)), north, constraints, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !constraints[north].match(deal.Hands[north]) || !constraints[south].match(deal.Hands[south]) || tries < 1 {
+			t.Errorf("seed %d: expected the constraints to be met, got %v", seed, deal.Hands)
+		}
+		again, _, _ := dealConstrained(rand.New(rand.NewSource(seed)), north, constraints, 0)
+		if again.cards().toString() != deal.cards().toString() {
+			t.Errorf("seed %d: expected the same deal for the same seed", seed)
+		}
+	}
+
+	impossible := map[bridgeSeat]handConstraint{north: {MinHCP: 25, MaxHCP: 37}, south: {MinHCP: 20, MaxHCP: 37}}
+	if _, _, err := dealConstrained(rand.New(rand.NewSource(1)), north, impossible, 1000); !errors.Is(err, ErrNoDeal) {
+		t.Errorf("Expected no deal with 45 points, got %v", err)
+	}
+}
+
+func TestBoardRotation(t *testing.T) {
+	want := "None NS EW All NS EW All None EW All None NS All None NS EW None"
+	var got []string
+	for board := 1; board <= 17; board++ {
+		got = append(got, boardVulnerability(board).String())
+	}
+	if strings.Join(got, " ") != want {
+		t.Errorf("Expected %s, got %v", want, got)
+	}
+	if boardDealer(1) != north || boardDealer(4) != west || boardDealer(6) != east {
+		t.Errorf("Expected the dealer to turn every board")
+	}
+	if !vulNorthSouth.vulnerable(south) || vulNorthSouth.vulnerable(east) || !vulAll.vulnerable(west) || vulNone.vulnerable(north) {
+		t.Errorf("Expected the vulnerability of the partnerships")
+	}
+}
+
+func TestDuplicateScore(t *testing.T) {
+	tests := []struct {
+		contract   string
+		vulnerable bool
+		tricks     int
+		want       int
+	}{
+		{"1C", false, 7, 70},
+		{"2S", false, 8, 110},
+		{"4S", false, 10, 420},
+		{"4S", true, 10, 620},
+		{"3NT", false, 9, 400},
+		{"3NT", true, 9, 600},
+		{"3NT", false, 11, 460},
+		{"6NT", false, 12, 990},
+		{"7NT", true, 13, 2220},
+		{"1NTX", false, 7, 180},
+		{"2CX", false, 8, 180},
+		{"2HX", false, 8, 470},
+		{"1NTXX", false, 7, 560},
+		{"4SX", true, 11, 990},
+		{"4S", false, 9, -50},
+		{"4S", true, 8, -200},
+		{"4SX", false, 9, -100},
+		{"4SX", false, 7, -500},
+		{"4SX", false, 6, -800},
+		{"4SX", true, 8, -500},
+		{"4SXX", true, 9, -400},
+		{"7NTX", false, 0, -3500},
+		{"7NTX", true, 0, -3800},
+	}
+	for _, tt := range tests {
+		c, err := parseContract(tt.contract)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := duplicateScore(c, tt.vulnerable, tt.tricks); err != nil || got != tt.want {
+			t.Errorf("%s vulnerable %v with %d tricks: expected %d, got %d ( %v )", tt.contract, tt.vulnerable, tt.tricks, tt.want, got, err)
+		}
+	}
+	if _, err := duplicateScore(bridgeContract{Level: 4, Strain: strainSpades}, false, 14); err == nil {
+		t.Errorf("Expected an error for 14 tricks")
+	}
+}
+
+func TestParseContract(t *testing.T) {
+	for _, s := range []string{"1C", "3NT", "6HX", "7SXX", "4D"} {
+		c, err := parseContract(strings.ToLower(s))
+		if err != nil || c.String() != s {
+			t.Errorf("Expected %s back, got %v ( %v )", s, c, err)
+		}
+	}
+	if c, _ := parseContract("3N"); c.Strain != noTrump {
+		t.Errorf("Expected 3N to be no trump, got %v", c)
+	}
+	for _, bad := range []string{"", "8S", "0NT", "4", "4SXXX", "4X", "4B"} {
+		if _, err := parseContract(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestBridgeCommands(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "bridge", "-seed", "2", "-boards", "3", "-north", "15-17 balanced")
+	if code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+	if strings.Count(stdout, "Board ") != 3 || !strings.Contains(stdout, "Board 3, dealer South, vulnerable EW") {
+		t.Errorf("Expected 3 boards, got\n%s", stdout)
+	}
+
+	code, stdout, _ = runCLI(t, "", "bridge", "-seed", "2", "-pbn", "-board", "4")
+	if code != exitOK || !strings.Contains(stdout, "[Board \"4\"]\n[Dealer \"W\"]\n[Vulnerable \"All\"]\n[Deal \"W:") {
+		t.Errorf("Expected the PBN tags of board 4, got %v ( %s )", code, stdout)
+	}
+	if _, err := parsePBN(pbnDealTag(stdout)); err != nil {
+		t.Errorf("Expected a deal PBN can read, got %v", err)
+	}
+
+	code, stdout, _ = runCLI(t, "", "score", "-contract", "4SX", "-by", "E", "-vul", "ew", "-tricks", "7")
+	if code != exitOK || stdout != "4SX by East, down 3: 800 to North-South\n" {
+		t.Errorf("Expected 800 to North-South, got %v ( %s )", code, stdout)
+	}
+	code, stdout, _ = runCLI(t, "", "score", "-contract", "3NT", "-by", "W", "-tricks", "10")
+	if code != exitOK || stdout != "3NT by West, made with 1 over: 430 to East-West\n" {
+		t.Errorf("Expected 430 to East-West, got %v ( %s )", code, stdout)
+	}
+
+	for _, args := range [][]string{
+		{"bridge", "-north", "strong"},
+		{"bridge", "-boards", "0"},
+		{"score", "-contract", "9NT", "-tricks", "9"},
+		{"score", "-contract", "3NT"},
+		{"score", "-contract", "3NT", "-tricks", "9", "-vul", "some"},
+	} {
+		if code, _, _ := runCLI(t, "", args...); code != exitUsage {
+			t.Errorf("Expected a usage error for %v, got %v", args, code)
+		}
+	}
+	if code, _, _ := runCLI(t, "", "bridge", "-north", "30+", "-south", "15+", "-tries", "100"); code != exitError {
+		t.Errorf("Expected an error when no deal is found, got %v", code)
+	}
+}
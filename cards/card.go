@@ -0,0 +1,245 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Suit and Rank are small integer types ( enums ) instead of raw strings.
+// Because they are numbers they can be compared with < and > which is what game logic needs,
+// and the String() methods turn them back into the human readable names used in the deck files.
+type Suit int
+
+// The suits are declared in the same order newDeck has always built them.
+// iota - starts at 0 and grows by one for every constant in the block.
+const (
+	Spades Suit = iota
+	Diamonds
+	Hearts
+	Clubs
+)
+
+// noSuit is used by cards that don't belong to any suit ( the jokers ).
+const noSuit Suit = -1
+
+type Rank int
+
+// The Ace starts at 1 so that the zero value of a Card is not a valid card.
+// Ace is low in this order - use Rank.high() when the Ace should beat the King.
+const (
+	Ace Rank = iota + 1
+	Two
+	Three
+	Four
+	Five
+	Six
+	Seven
+	Eight
+	Nine
+	Ten
+	Jack
+	Queen
+	King
+	BlackJoker
+	RedJoker
+)
+
+// The names are indexed by the numeric value of the suit/rank.
+// Card sets append the suits and ranks they add ( see registerCardSet ).
+var suitNames = []string{"Spades", "Diamonds", "Hearts", "Clubs"}
+
+var rankNames = []string{"", "Ace", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Ten",
+	"Jack", "Queen", "King", "Black Joker", "Red Joker"}
+
+// suitlessRanks are the ranks of the cards without a suit. Card sets add their own ( see cardset.go ).
+var suitlessRanks = map[Rank]bool{BlackJoker: true, RedJoker: true}
+
+// standardSuits and standardRanks are the suits/ranks of the French 52 card deck in deck order.
+var standardSuits = []Suit{Spades, Diamonds, Hearts, Clubs}
+
+var standardRanks = []Rank{Ace, Two, Three, Four, Five, Six, Seven, Eight, Nine, Ten, Jack, Queen, King}
+
+func (s Suit) String() string {
+	registry.RLock()
+	defer registry.RUnlock()
+	if s < 0 || int(s) >= len(suitNames) {
+		return fmt.Sprintf("Suit(%d)", int(s))
+	}
+	return suitNames[s]
+}
+
+func (r Rank) String() string {
+	registry.RLock()
+	defer registry.RUnlock()
+	if r <= 0 || int(r) >= len(rankNames) {
+		return fmt.Sprintf("Rank(%d)", int(r))
+	}
+	return rankNames[r]
+}
+
+// high returns the value of the rank when the Ace is played high ( Two = 2 ... King = 13, Ace = 14 ).
+// The jokers stay above the Ace.
+func (r Rank) high() int {
+	if r == Ace {
+		return 14
+	}
+	if r > King {
+		return int(r) + 1
+	}
+	return int(r)
+}
+
+// red reports whether the suit is printed in red.
+func (s Suit) red() bool {
+	return s == Diamonds || s == Hearts
+}
+
+// Card is a single playing card. Jokers have no suit.
+type Card struct {
+	Rank Rank
+	Suit Suit
+}
+
+func (c Card) isJoker() bool {
+	return c.Rank == BlackJoker || c.Rank == RedJoker
+}
+
+// valid reports whether the card is one of the standard deck or a joker.
+// Games built on the French deck ( poker, blackjack ) check their cards with it.
+func (c Card) valid() bool {
+	if c.isJoker() {
+		return c.Suit == noSuit
+	}
+	return c.Rank >= Ace && c.Rank <= King && c.Suit >= Spades && c.Suit <= Clubs
+}
+
+// known reports whether the card belongs to any registered card set,
+// e.g. the Knight of Spades of a Tarot deck or a Wild of Uno - but not a Zero of Spades.
+func (c Card) known() bool {
+	registry.RLock()
+	defer registry.RUnlock()
+	return knownCards[c]
+}
+
+// String gives back the "Value of Suit" text the deck has always used, e.g. "Ace of Spades".
+// The jokers don't have a suit so they are printed only by their name.
+func (c Card) String() string {
+	if c.Suit == noSuit {
+		return c.Rank.String()
+	}
+	return c.Rank.String() + " of " + c.Suit.String()
+}
+
+// code is the short two letter name of a standard card, rank then suit: "AS", "TD", "KC".
+// The jokers are "BJ" and "RJ". Cards of other sets don't have one and print as "??".
+// parseCode reads it back ( see render.go ).
+func (c Card) code() string {
+	switch {
+	case c.Rank == BlackJoker && c.Suit == noSuit:
+		return "BJ"
+	case c.Rank == RedJoker && c.Suit == noSuit:
+		return "RJ"
+	case !c.valid():
+		return "??"
+	}
+	return string("A23456789TJQK"[c.Rank-1]) + string("SDHC"[c.Suit])
+}
+
+// ErrUnknownCard is returned for a name or value that isn't a card of the deck.
+var ErrUnknownCard = errors.New("unknown card")
+
+// parseCard is the opposite of Card.String - parseCard(c.String()) gives back c.
+func parseCard(s string) (Card, error) {
+	s = strings.TrimSpace(s)
+	value, suit, found := strings.Cut(s, " of ")
+	if !found {
+		r, ok := lookupRank(s)
+		if !ok || !(Card{Rank: r, Suit: noSuit}).known() {
+			return Card{}, fmt.Errorf("%w %q", ErrUnknownCard, s)
+		}
+		return Card{Rank: r, Suit: noSuit}, nil
+	}
+
+	r, ok := lookupRank(value)
+	if !ok {
+		return Card{}, fmt.Errorf("%w %q: unknown value %q", ErrUnknownCard, s, value)
+	}
+	st, ok := lookupSuit(suit)
+	if !ok {
+		return Card{}, fmt.Errorf("%w %q: unknown suit %q", ErrUnknownCard, s, suit)
+	}
+	c := Card{Rank: r, Suit: st}
+	if !c.known() {
+		return Card{}, fmt.Errorf("%w %q", ErrUnknownCard, s)
+	}
+	return c, nil
+}
+
+// MarshalText and UnmarshalText write the card by its name, so cards show up
+// as "Ace of Spades" in JSON instead of two numbers.
+func (c Card) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Card) UnmarshalText(text []byte) error {
+	card, err := parseCard(string(text))
+	if err != nil {
+		return err
+	}
+	*c = card
+	return nil
+}
+
+func lookupRank(name string) (Rank, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	return rankByName(name)
+}
+
+func lookupSuit(name string) (Suit, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	return suitByName(name)
+}
+
+// rankByName and suitByName are lookupRank and lookupSuit for a caller that holds the registry lock.
+func rankByName(name string) (Rank, bool) {
+	for i, n := range rankNames {
+		if i > 0 && n == name {
+			return Rank(i), true
+		}
+	}
+	return 0, false
+}
+
+func suitByName(name string) (Suit, bool) {
+	for i, n := range suitNames {
+		if n == name {
+			return Suit(i), true
+		}
+	}
+	return 0, false
+}
+
+// compare orders the cards by suit first and then by rank ( the order of newDeck ).
+// It returns -1, 0 or +1 so it can be passed straight to slices.SortFunc.
+// The jokers ( no suit ) go after every other card.
+func (c Card) compare(o Card) int {
+	if c.Suit != o.Suit {
+		if c.Suit == noSuit {
+			return 1
+		}
+		if o.Suit == noSuit {
+			return -1
+		}
+		return cmp.Compare(c.Suit, o.Suit)
+	}
+	return cmp.Compare(c.Rank, o.Rank)
+}
+
+// compareRank orders the cards by rank only, with the Ace played high.
+func (c Card) compareRank(o Card) int {
+	return cmp.Compare(c.Rank.high(), o.Rank.high())
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// cardMask is a set of cards in one 64-bit number, one bit a card. Membership, union,
+// intersection and removal are a single instruction and a mask never allocates, which is what
+// the hot loops of the simulations need - a deck is a slice that has to be searched and copied.
+//
+// The bits follow the order of newDeckWithJokers: bit 13*suit + rank-1 for the 52 standard
+// cards ( Ace of Spades is bit 0, King of Clubs bit 51 ), then the Black and the Red Joker.
+// Cards of other sets don't fit in a mask.
+type cardMask uint64
+
+// The masks of the whole decks.
+const (
+	standardMask cardMask = 1<<52 - 1
+	jokersMask   cardMask = 1<<52 | 1<<53
+	allMask               = standardMask | jokersMask
+)
+
+// maskIndex is the bit of the card, false for a card that has none.
+func (c Card) maskIndex() (int, bool) {
+	switch {
+	case c.Rank == BlackJoker && c.Suit == noSuit:
+		return 52, true
+	case c.Rank == RedJoker && c.Suit == noSuit:
+		return 53, true
+	case c.Suit < Spades || c.Suit > Clubs || c.Rank < Ace || c.Rank > King:
+		return 0, false
+	}
+	return 13*int(c.Suit) + int(c.Rank) - 1, true
+}
+
+// cardAt is the card of a bit, the opposite of maskIndex.
+func cardAt(i int) Card {
+	switch i {
+	case 52:
+		return Card{Rank: BlackJoker, Suit: noSuit}
+	case 53:
+		return Card{Rank: RedJoker, Suit: noSuit}
+	}
+	return Card{Rank: Rank(i%13 + 1), Suit: Suit(i / 13)}
+}
+
+// maskOf turns the deck into a mask. A mask can't hold a card twice or cards of other sets,
+// so those return ErrDuplicateCard and ErrUnknownCard.
+func maskOf(d deck) (cardMask, error) {
+	var m cardMask
+	for i, card := range d {
+		bit, ok := card.maskIndex()
+		if !ok {
+			return 0, fmt.Errorf("%w at position %d: %v has no place in a card mask", ErrUnknownCard, i, card)
+		}
+		if m&(1<<bit) != 0 {
+			return 0, fmt.Errorf("%w at position %d: %v", ErrDuplicateCard, i, card)
+		}
+		m |= 1 << bit
+	}
+	return m, nil
+}
+
+// maskOfCard is the mask of one card, 0 for a card that has no bit.
+func maskOfCard(c Card) cardMask {
+	bit, ok := c.maskIndex()
+	if !ok {
+		return 0
+	}
+	return 1 << bit
+}
+
+func (m cardMask) has(c Card) bool {
+	return m&maskOfCard(c) != 0
+}
+
+func (m cardMask) union(o cardMask) cardMask {
+	return m | o
+}
+
+func (m cardMask) intersect(o cardMask) cardMask {
+	return m & o
+}
+
+// without removes the cards of o.
+func (m cardMask) without(o cardMask) cardMask {
+	return m &^ o
+}
+
+func (m cardMask) count() int {
+	return bits.OnesCount64(uint64(m))
+}
+
+// cards is the deck of the mask in deck order ( see newDeckWithJokers ).
+func (m cardMask) cards() deck {
+	d := make(deck, 0, m.count())
+	for rest := m; rest != 0; rest &= rest - 1 {
+		d = append(d, cardAt(bits.TrailingZeros64(uint64(rest))))
+	}
+	return d
+}
+
+// nth is the card of the n-th set bit counting from 0, so a random card of the mask is
+// m.nth(r.Intn(m.count())). It panics when the mask has n cards or less.
+func (m cardMask) nth(n int) Card {
+	rest := m
+	for range n {
+		rest &= rest - 1
+	}
+	if rest == 0 {
+		panic(fmt.Sprintf("card %d of a mask of %d cards", n, m.count()))
+	}
+	return cardAt(bits.TrailingZeros64(uint64(rest)))
+}
+
+// suitRanks are the ranks of one suit of the mask, Ace high: bit 0 is the Two and bit 12 the Ace,
+// the order of Rank.high minus 2.
+func (m cardMask) suitRanks(s Suit) uint16 {
+	ranks := uint16(m>>(13*uint(s))) & 0x1FFF
+	// the Ace is bit 0 of the suit, move it above the King
+	return ranks>>1 | (ranks&1)<<12
+}
+
+// straightHigh is the Rank.high of the top card of the best straight of the ranks, 0 without one.
+func straightHigh(ranks uint16) int {
+	for top := 12; top >= 4; top-- {
+		run := uint16(0x1F) << (top - 4)
+		if ranks&run == run {
+			return top + 2
+		}
+	}
+	// the wheel: A 2 3 4 5
+	const wheel = 1<<12 | 0xF
+	if ranks&wheel == wheel {
+		return 5
+	}
+	return 0
+}
+
+// topRanks fills values with the Rank.high of the n highest bits of the ranks.
+func topRanks(ranks uint16, n int, values []int) {
+	for i := 0; i < n && ranks != 0; i++ {
+		top := bits.Len16(ranks) - 1
+		values[i] = top + 2
+		ranks &^= 1 << top
+	}
+}
+
+// maskValue scores the best five cards of a hand of 5 to 7 standard cards without trying every
+// combination: the category and Ranks are the ones of bestFive, but Cards is left empty. It's the
+// evaluator for the loops that score millions of hands; jokers in the mask are ignored.
+func maskValue(m cardMask) handValue {
+	var v handValue
+	var suits [4]uint16
+	var seen, pairs, trips, quads uint16
+	for s := range suits {
+		suits[s] = m.suitRanks(Suit(s))
+		// count the cards of every rank with the bits: a rank seen twice moves up to pairs and so on
+		quads |= trips & suits[s]
+		trips |= pairs & suits[s]
+		pairs |= seen & suits[s]
+		seen |= suits[s]
+	}
+	trips &^= quads
+	pairs &^= trips | quads
+
+	flushRanks := uint16(0)
+	for _, ranks := range suits {
+		if bits.OnesCount16(ranks) < 5 {
+			continue
+		}
+		flushRanks = ranks
+		if high := straightHigh(ranks); high > 0 {
+			v.Category = straightFlush
+			if high == 14 {
+				v.Category = royalFlush
+			}
+			v.Ranks = straightRanks(high)
+			return v
+		}
+	}
+
+	switch {
+	case quads != 0:
+		v.Category = fourOfAKind
+		topRanks(quads, 1, v.Ranks[:])
+		topRanks(seen&^(1<<(v.Ranks[0]-2)), 1, v.Ranks[1:])
+	case trips != 0 && bits.OnesCount16(trips)+bits.OnesCount16(pairs) >= 2:
+		v.Category = fullHouse
+		topRanks(trips, 1, v.Ranks[:])
+		// the pair can be a second three of a kind
+		topRanks((trips|pairs)&^(1<<(v.Ranks[0]-2)), 1, v.Ranks[1:])
+	case flushRanks != 0:
+		v.Category = flush
+		topRanks(flushRanks, 5, v.Ranks[:])
+	case straightHigh(seen) > 0:
+		v.Category = straight
+		v.Ranks = straightRanks(straightHigh(seen))
+	case trips != 0:
+		v.Category = threeOfAKind
+		topRanks(trips, 1, v.Ranks[:])
+		topRanks(seen&^trips, 2, v.Ranks[1:])
+	case bits.OnesCount16(pairs) >= 2:
+		v.Category = twoPair
+		topRanks(pairs, 2, v.Ranks[:])
+		used := uint16(1)<<(v.Ranks[0]-2) | 1<<(v.Ranks[1]-2)
+		topRanks(seen&^used, 1, v.Ranks[2:])
+	case pairs != 0:
+		v.Category = onePair
+		topRanks(pairs, 1, v.Ranks[:])
+		topRanks(seen&^pairs, 3, v.Ranks[1:])
+	default:
+		v.Category = highCard
+		topRanks(seen, 5, v.Ranks[:])
+	}
+	return v
+}
+
+// straightRanks are the Ranks of a straight with the given top card, [5 4 3 2 1] for the wheel.
+func straightRanks(high int) [5]int {
+	return [5]int{high, high - 1, high - 2, high - 3, high - 4}
+}
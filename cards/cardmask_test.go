@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestCardMaskRoundTrip(t *testing.T) {
+	d := newDeckWithJokers()
+	m, err := maskOf(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != allMask || m.count() != 54 {
+		t.Errorf("Expected every bit of the deck, got %x", m)
+	}
+	if back := m.cards(); back.toString() != d.toString() {
+		t.Errorf("Expected the deck back in order, got %v", back)
+	}
+	for i, card := range d {
+		if got := m.nth(i); got != card {
+			t.Errorf("Expected card %d to be %v, got %v", i, card, got)
+		}
+	}
+
+	// a shuffled deck gives the same mask, the mask has no order
+	d.shuffleSeed(3)
+	if again, _ := maskOf(d); again != m {
+		t.Errorf("Expected the same mask after a shuffle, got %x", again)
+	}
+}
+
+func TestCardMaskOperations(t *testing.T) {
+	hand, _ := maskOf(cardsFromCodes(t, "As Kd 7h"))
+	board, _ := maskOf(cardsFromCodes(t, "Kd 2c"))
+	if !hand.has(Card{Ace, Spades}) || hand.has(Card{Two, Clubs}) || hand.has(Card{Rank: RedJoker, Suit: noSuit}) {
+		t.Errorf("Expected only the cards of the hand, got %v", hand.cards())
+	}
+	if got := hand.union(board).cards(); !sameCards(got, cardsFromCodes(t, "As Kd 7h 2c")) {
+		t.Errorf("Expected the union of 4 cards, got %v", got)
+	}
+	if got := hand.intersect(board).cards(); !sameCards(got, cardsFromCodes(t, "Kd")) {
+		t.Errorf("Expected the King of Diamonds in both, got %v", got)
+	}
+	if got := standardMask.without(hand).count(); got != 49 {
+		t.Errorf("Expected 49 cards left, got %v", got)
+	}
+	if jokersMask.intersect(standardMask) != 0 {
+		t.Errorf("Expected the jokers outside the standard deck")
+	}
+}
+
+func TestMaskOfErrors(t *testing.T) {
+	if _, err := maskOf(cardsFromCodes(t, "As Kd As")); !errors.Is(err, ErrDuplicateCard) {
+		t.Errorf("Expected a duplicate card, got %v", err)
+	}
+	cs, err := lookupCardSet("tarot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := maskOf(cs.newDeck()); !errors.Is(err, ErrUnknownCard) {
+		t.Errorf("Expected the tarot cards not to fit, got %v", err)
+	}
+}
+
+func TestMaskValue(t *testing.T) {
+	tests := []struct {
+		codes string
+		want  handValue
+	}{
+		{"As Ks Qs Js Ts 2d 2c", handValue{Category: royalFlush, Ranks: [5]int{14, 13, 12, 11, 10}}},
+		{"As 2s 3s 4s 5s 6d 7c", handValue{Category: straightFlush, Ranks: [5]int{5, 4, 3, 2, 1}}},
+		{"9h 9d 9s 9c Kd Ks Kh", handValue{Category: fourOfAKind, Ranks: [5]int{9, 13}}},
+		{"9h 9d 9s Kc Kd Ks 2h", handValue{Category: fullHouse, Ranks: [5]int{13, 9}}},
+		{"2h 7h 9h Jh Qh Kh 3c", handValue{Category: flush, Ranks: [5]int{13, 12, 11, 9, 7}}},
+		{"Ah 2d 3c 4s 5h Kd Kc", handValue{Category: straight, Ranks: [5]int{5, 4, 3, 2, 1}}},
+		{"7h 7d 2c 2s 5h 5d Ac", handValue{Category: twoPair, Ranks: [5]int{7, 5, 14}}},
+		{"7h 8d Tc Qs Ah 3d 2c", handValue{Category: highCard, Ranks: [5]int{14, 12, 10, 8, 7}}},
+	}
+	for _, tt := range tests {
+		m, err := maskOf(cardsFromCodes(t, tt.codes))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := maskValue(m); got.compare(tt.want) != 0 || got.Category != tt.want.Category {
+			t.Errorf("Expected %v %v for %s, got %v %v", tt.want.Category, tt.want.Ranks, tt.codes, got.Category, got.Ranks)
+		}
+	}
+}
+
+// maskValue has to score every hand like bestFive does.
+func TestMaskValueMatchesBestFive(t *testing.T) {
+	r := rand.New(rand.NewSource(21))
+	d := newDeck()
+	for i := range 20000 {
+		r.Shuffle(len(d), func(i, j int) { d[i], d[j] = d[j], d[i] })
+		hand := d[:5+i%3]
+		m, _ := maskOf(hand)
+		want, got := bestFive(hand), maskValue(m)
+		if got.Category != want.Category || got.Ranks != want.Ranks {
+			t.Fatalf("%v: expected %v %v, got %v %v", hand.toString(), want.Category, want.Ranks, got.Category, got.Ranks)
+		}
+	}
+}
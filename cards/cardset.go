@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A cardSet describes a kind of deck - its suits, its ranks, how many copies of every card
+// and the special cards that have no suit ( jokers, Tarot trumps, Uno wilds ).
+// The built-in sets are registered below, more can be read from a JSON definition file:
+//
+//	{
+//	  "name": "pinochle",
+//	  "copies": 2,
+//	  "suits": ["Spades", "Diamonds", "Hearts", "Clubs"],
+//	  "ranks": ["Nine", "Ten", "Jack", "Queen", "King", "Ace"],
+//	  "specials": [{"name": "Wild", "copies": 4}]
+//	}
+//
+// A rank or a special is either just its name or {"name": ..., "copies": ...}.
+// The cards are plain Cards, so every deck function works with them and saveToFile writes
+// them with their "Value of Suit" names like any other deck.
+type cardSet struct {
+	Name  string    `json:"name"`
+	Suits []string  `json:"suits"`
+	Ranks []setRank `json:"ranks"`
+	// Copies is how many copies of every card the deck has, 1 when not set.
+	Copies   int       `json:"copies,omitempty"`
+	Specials []setRank `json:"specials,omitempty"`
+
+	// order is the deck in the order newDeck builds it, counts how many of every card it holds.
+	// Both are filled in by registerCardSet.
+	order  deck
+	counts map[Card]int
+}
+
+// setRank is a rank or a special card of a set. Copies overrides the copies of the set,
+// like the single Zero of every colour in an Uno deck.
+type setRank struct {
+	Name   string `json:"name"`
+	Copies int    `json:"copies,omitempty"`
+}
+
+// UnmarshalJSON accepts the name alone as well as the full object.
+func (r *setRank) UnmarshalJSON(bs []byte) error {
+	var name string
+	if err := json.Unmarshal(bs, &name); err == nil {
+		*r = setRank{Name: name}
+		return nil
+	}
+	type plain setRank
+	return json.Unmarshal(bs, (*plain)(r))
+}
+
+var (
+	// ErrUnknownCardSet is returned for a set name that isn't registered.
+	ErrUnknownCardSet = errors.New("unknown card set")
+	// ErrBadCardSet is returned for a definition that can't make a deck.
+	ErrBadCardSet = errors.New("bad card set")
+)
+
+// cardSets holds the registered sets by name and knownCards every card of any of them.
+// setFiles holds the sets read from a definition file by the file name they were looked up with.
+// Registering changes the suit and rank names as well. A set file can be registered at any time
+// ( lookupCardSet reads one the first time it's named ), so all of them, with the names of
+// card.go, are guarded by registry: registering takes the write lock, the lookups and the
+// String methods the read lock.
+var (
+	registry   sync.RWMutex
+	cardSets   = map[string]*cardSet{}
+	setFiles   = map[string]*cardSet{}
+	knownCards = map[Card]bool{}
+)
+
+// registerCardSet checks the definition and adds it to cardSets. Suits and ranks that
+// don't exist yet get a new number after the existing ones. The numbers of the built-in sets
+// never change, but the ones of a definition file depend on what was registered before it -
+// save such decks in a format that writes the names ( legacy, JSON or CSV ), not binary.
+func registerCardSet(cs *cardSet) error {
+	registry.Lock()
+	defer registry.Unlock()
+	return addCardSet(cs)
+}
+
+// addCardSet is registerCardSet for a caller that holds the registry lock.
+func addCardSet(cs *cardSet) error {
+	if cs.Name == "" || strings.ContainsRune(cs.Name, 0) {
+		return fmt.Errorf("%w: the set has no name, or a zero byte in it", ErrBadCardSet)
+	}
+	if _, ok := cardSets[cs.Name]; ok {
+		return fmt.Errorf("%w: %q is already registered", ErrBadCardSet, cs.Name)
+	}
+	if len(cs.Ranks) > 0 && len(cs.Suits) == 0 {
+		return fmt.Errorf("%w %q: ranks without suits", ErrBadCardSet, cs.Name)
+	}
+	if cs.Copies < 0 {
+		return fmt.Errorf("%w %q: negative copies", ErrBadCardSet, cs.Name)
+	}
+	// check everything before any name is registered, a bad set leaves nothing behind
+	for _, r := range slices.Concat(cs.Ranks, cs.Specials) {
+		if r.Name == "" || r.Copies < 0 {
+			return fmt.Errorf("%w %q: bad rank %+v", ErrBadCardSet, cs.Name, r)
+		}
+	}
+	for _, r := range cs.Ranks {
+		if rank, ok := rankByName(r.Name); ok && suitlessRanks[rank] {
+			return fmt.Errorf("%w %q: %s is a card without suit", ErrBadCardSet, cs.Name, r.Name)
+		}
+	}
+	for _, r := range cs.Specials {
+		if rank, ok := rankByName(r.Name); ok && !suitlessRanks[rank] {
+			return fmt.Errorf("%w %q: %s is a rank with suits", ErrBadCardSet, cs.Name, r.Name)
+		}
+	}
+
+	copies := func(r setRank) int {
+		switch {
+		case r.Copies > 0:
+			return r.Copies
+		case cs.Copies > 0:
+			return cs.Copies
+		}
+		return 1
+	}
+	cs.order = deck{}
+	cs.counts = map[Card]int{}
+	for _, suitName := range cs.Suits {
+		suit := registerSuit(suitName)
+		for _, r := range cs.Ranks {
+			card := Card{Rank: registerRank(r.Name, false), Suit: suit}
+			cs.add(card, copies(r))
+		}
+	}
+	for _, r := range cs.Specials {
+		cs.add(Card{Rank: registerRank(r.Name, true), Suit: noSuit}, copies(r))
+	}
+	if len(cs.order) == 0 {
+		return fmt.Errorf("%w %q: the deck has no cards", ErrBadCardSet, cs.Name)
+	}
+	cardSets[cs.Name] = cs
+	return nil
+}
+
+func (cs *cardSet) add(card Card, copies int) {
+	for range copies {
+		cs.order = append(cs.order, card)
+	}
+	cs.counts[card] += copies
+	knownCards[card] = true
+}
+
+// registerSuit returns the suit with the name, adding it when it's new. The caller holds the registry lock.
+func registerSuit(name string) Suit {
+	if s, ok := suitByName(name); ok {
+		return s
+	}
+	suitNames = append(suitNames, name)
+	return Suit(len(suitNames) - 1)
+}
+
+// registerRank returns the rank with the name, adding it when it's new.
+// Suitless ranks are printed and parsed without " of Suit". The caller holds the registry lock.
+func registerRank(name string, suitless bool) Rank {
+	if r, ok := rankByName(name); ok {
+		return r
+	}
+	rankNames = append(rankNames, name)
+	r := Rank(len(rankNames) - 1)
+	if suitless {
+		suitlessRanks[r] = true
+	}
+	return r
+}
+
+// lookupCardSet returns a registered set, or reads and registers the definition
+// when the name is a .json file. The file is only read the first time it's named.
+func lookupCardSet(name string) (*cardSet, error) {
+	registry.RLock()
+	cs, ok := cardSets[name]
+	if !ok {
+		cs, ok = setFiles[name]
+	}
+	registry.RUnlock()
+	if ok {
+		return cs, nil
+	}
+	if strings.HasSuffix(strings.ToLower(name), ".json") {
+		return loadCardSet(name)
+	}
+	return nil, fmt.Errorf("%w %q, known sets are %s", ErrUnknownCardSet, name, strings.Join(cardSetNames(), ", "))
+}
+
+// loadCardSet reads a JSON definition file and registers it.
+func loadCardSet(filename string) (*cardSet, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cs := &cardSet{}
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cs); err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrBadCardSet, filename, err)
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	// another goroutine may have read the same file in the meantime
+	if loaded, ok := setFiles[filename]; ok {
+		return loaded, nil
+	}
+	// the same definition under another path ( a copy, or a relative path ) is the same set
+	cs, err = reuseCardSet(cs)
+	if err != nil {
+		return nil, err
+	}
+	setFiles[filename] = cs
+	return cs, nil
+}
+
+// defineCardSet registers a definition that came inside a document ( see fair.go ) instead of
+// a file. A set registered under the name with the same definition is returned as it is.
+func defineCardSet(def *cardSet) (*cardSet, error) {
+	registry.Lock()
+	defer registry.Unlock()
+	return reuseCardSet(def.definition())
+}
+
+// reuseCardSet returns the registered set when it has the same definition as cs, or registers cs.
+// The caller holds the registry lock.
+func reuseCardSet(cs *cardSet) (*cardSet, error) {
+	if registered, ok := cardSets[cs.Name]; ok && registered.sameDefinition(cs) {
+		return registered, nil
+	}
+	if err := addCardSet(cs); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// definition is a copy of the set as it's written in a definition file, without the deck.
+func (cs *cardSet) definition() *cardSet {
+	return &cardSet{Name: cs.Name, Suits: slices.Clone(cs.Suits), Ranks: slices.Clone(cs.Ranks),
+		Copies: cs.Copies, Specials: slices.Clone(cs.Specials)}
+}
+
+func (cs *cardSet) sameDefinition(o *cardSet) bool {
+	return cs.Name == o.Name && cs.Copies == o.Copies && slices.Equal(cs.Suits, o.Suits) &&
+		slices.Equal(cs.Ranks, o.Ranks) && slices.Equal(cs.Specials, o.Specials)
+}
+
+func cardSetNames() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(cardSets))
+	for name := range cardSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newDeck builds a full deck of the set: suit by suit with the ranks in the order of the
+// definition, the copies of a card next to each other and the specials at the end.
+func (cs *cardSet) newDeck() deck {
+	return slices.Clone(cs.order)
+}
+
+// size is the number of cards of a full deck.
+func (cs *cardSet) size() int {
+	return len(cs.order)
+}
+
+// validate checks a deck against the set: every card has to belong to the set and no card
+// can show up more often than the set has copies of it. The deck doesn't have to be complete,
+// a file saved after dealing is still a valid deck.
+func (cs *cardSet) validate(d deck) error {
+	seen := map[Card]int{}
+	for i, card := range d {
+		n, ok := cs.counts[card]
+		if !ok {
+			return fmt.Errorf("%w at position %d: %v is not a %s card", ErrUnknownCard, i, card, cs.Name)
+		}
+		seen[card]++
+		if seen[card] > n {
+			return fmt.Errorf("%w at position %d: more than %d %v in a %s deck", ErrDuplicateCard, i, n, card, cs.Name)
+		}
+	}
+	return nil
+}
+
+// validateFor validates the deck against the named set, or with validateDeck when there is no set.
+func validateFor(d deck, set string) error {
+	if set == "" {
+		return validateDeck(d)
+	}
+	cs, err := lookupCardSet(set)
+	if err != nil {
+		return err
+	}
+	return cs.validate(d)
+}
+
+// The built-in sets. The French suits and ranks are the ones of card.go, so a "standard"
+// deck is exactly newDeck() and "jokers" is newDeckWithJokers().
+func init() {
+	french := []string{"Spades", "Diamonds", "Hearts", "Clubs"}
+	ranks := func(names ...string) []setRank {
+		rs := make([]setRank, len(names))
+		for i, name := range names {
+			rs[i] = setRank{Name: name}
+		}
+		return rs
+	}
+	standard := ranks("Ace", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Ten", "Jack", "Queen", "King")
+
+	// Tarot: 14 ranks per suit with the Knight between Jack and Queen, 21 trumps and the Fool.
+	var trumps []setRank
+	for i := 1; i <= 21; i++ {
+		trumps = append(trumps, setRank{Name: "Trump " + strconv.Itoa(i)})
+	}
+	tarot := slices.Concat(standard[:11], ranks("Knight", "Queen", "King"))
+
+	// Uno: one Zero and two of every other card per colour, four of each wild.
+	uno := slices.Concat([]setRank{{Name: "Zero", Copies: 1}},
+		ranks("One", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Skip", "Reverse", "Draw Two"))
+
+	for _, cs := range []*cardSet{
+		{Name: "standard", Suits: french, Ranks: standard},
+		{Name: "jokers", Suits: french, Ranks: standard, Specials: ranks("Black Joker", "Red Joker")},
+		{Name: "pinochle", Suits: french, Ranks: ranks("Nine", "Ten", "Jack", "Queen", "King", "Ace"), Copies: 2},
+		{Name: "skat", Suits: french, Ranks: ranks("Seven", "Eight", "Nine", "Ten", "Jack", "Queen", "King", "Ace")},
+		{Name: "tarot", Suits: french, Ranks: tarot, Specials: append(trumps, setRank{Name: "Fool"})},
+		{Name: "uno", Suits: []string{"Red", "Yellow", "Green", "Blue"}, Ranks: uno, Copies: 2,
+			Specials: []setRank{{Name: "Wild", Copies: 4}, {Name: "Wild Draw Four", Copies: 4}}},
+	} {
+		if err := registerCardSet(cs); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBuiltinCardSets(t *testing.T) {
+	tests := []struct {
+		set      string
+		size     int
+		distinct int
+	}{
+		{"standard", 52, 52},
+		{"jokers", 54, 54},
+		{"pinochle", 48, 24},
+		{"skat", 32, 32},
+		{"tarot", 78, 78},
+		{"uno", 108, 54},
+	}
+	for _, tt := range tests {
+		cs, err := lookupCardSet(tt.set)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := cs.newDeck()
+		if len(d) != tt.size || cs.size() != tt.size {
+			t.Errorf("%s: expected %v cards, got %v", tt.set, tt.size, len(d))
+		}
+		if len(cs.counts) != tt.distinct {
+			t.Errorf("%s: expected %v different cards, got %v", tt.set, tt.distinct, len(cs.counts))
+		}
+		if err := cs.validate(d); err != nil {
+			t.Errorf("%s: expected a new deck to be valid, got %v", tt.set, err)
+		}
+		// every card prints and parses back
+		for _, card := range d {
+			parsed, err := parseCard(card.String())
+			if err != nil || parsed != card {
+				t.Errorf("%s: %v parsed back as %v, %v", tt.set, card, parsed, err)
+			}
+		}
+	}
+
+	standard, _ := lookupCardSet("standard")
+	jokers, _ := lookupCardSet("jokers")
+	if standard.newDeck().toString() != newDeck().toString() || jokers.newDeck().toString() != newDeckWithJokers().toString() {
+		t.Errorf("Expected the standard sets to build the same decks as newDeck and newDeckWithJokers")
+	}
+
+	if _, err := lookupCardSet("canasta"); !errors.Is(err, ErrUnknownCardSet) {
+		t.Errorf("Expected ErrUnknownCardSet, got %v", err)
+	}
+}
+
+func TestCardSetCards(t *testing.T) {
+	for _, name := range []string{"Knight of Hearts", "Trump 21", "Fool", "Zero of Red", "Draw Two of Blue", "Wild Draw Four"} {
+		if _, err := parseCard(name); err != nil {
+			t.Errorf("Expected %q to be a card, got %v", name, err)
+		}
+	}
+	// the ranks and suits exist, but no set has these cards
+	for _, name := range []string{"Zero of Spades", "Knight of Green", "Ace of Red", "Trump 21 of Clubs", "Skip"} {
+		if _, err := parseCard(name); !errors.Is(err, ErrUnknownCard) {
+			t.Errorf("Expected %q to be unknown, got %v", name, err)
+		}
+	}
+	knight, _ := parseCard("Knight of Spades")
+	if knight.valid() {
+		t.Errorf("Expected the Knight not to be a card of the standard deck")
+	}
+
+	uno, _ := lookupCardSet("uno")
+	zeros, wilds := 0, 0
+	for card, n := range uno.counts {
+		switch card.Rank.String() {
+		case "Zero":
+			zeros += n
+		case "Wild":
+			wilds += n
+		}
+	}
+	if zeros != 4 || wilds != 4 {
+		t.Errorf("Expected 4 Zeros and 4 Wilds in Uno, got %v and %v", zeros, wilds)
+	}
+}
+
+func TestCardSetValidate(t *testing.T) {
+	pinochle, _ := lookupCardSet("pinochle")
+	d := pinochle.newDeck()
+	nine := d[0]
+	if err := pinochle.validate(append(d[:10:10], nine, nine)); !errors.Is(err, ErrDuplicateCard) {
+		t.Errorf("Expected a third Nine to be a duplicate, got %v", err)
+	}
+	if err := pinochle.validate(deck{{Rank: Two, Suit: Spades}}); !errors.Is(err, ErrUnknownCard) {
+		t.Errorf("Expected the Two to be unknown in pinochle, got %v", err)
+	}
+	if err := pinochle.validate(d[:5]); err != nil {
+		t.Errorf("Expected part of a deck to be valid, got %v", err)
+	}
+}
+
+func TestCardSetFiles(t *testing.T) {
+	dir := t.TempDir()
+	pinochle, _ := lookupCardSet("pinochle")
+	d := pinochle.newDeck()
+	d.shuffleSeed(3)
+
+	// saveToFile writes the copies like any deck, the generic loader refuses them
+	legacy := filepath.Join(dir, "pinochle.txt")
+	if err := d.saveToFile(legacy); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDeck(legacy); !errors.Is(err, ErrDuplicateCard) {
+		t.Errorf("Expected ErrDuplicateCard without the set, got %v", err)
+	}
+	loaded, info, err := LoadDeckAs(legacy, "pinochle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.toString() != d.toString() || info.Set != "pinochle" {
+		t.Errorf("Expected the pinochle deck back, got %v cards of set %q", len(loaded), info.Set)
+	}
+	if _, _, err := LoadDeckAs(legacy, "uno"); !errors.Is(err, ErrUnknownCard) {
+		t.Errorf("Expected the French suits to be unknown in uno, got %v", err)
+	}
+
+	// JSON keeps the set, so the file loads without naming it
+	doc := filepath.Join(dir, "pinochle.json")
+	if err := SaveDeckInfo(doc, d, deckInfo{Set: "pinochle"}); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := LoadDeck(doc); err != nil || len(loaded) != 48 {
+		t.Errorf("Expected 48 cards, got %v, %v", len(loaded), err)
+	}
+	if _, _, err := LoadDeckAs(doc, "uno"); !errors.Is(err, ErrBadDeckFile) {
+		t.Errorf("Expected ErrBadDeckFile for a pinochle file loaded as uno, got %v", err)
+	}
+	if err := SaveDeckInfo(doc, append(d, d[0]), deckInfo{Set: "pinochle"}); !errors.Is(err, ErrDuplicateCard) {
+		t.Errorf("Expected a third copy to be refused on save, got %v", err)
+	}
+}
+
+func TestLoadCardSetDefinition(t *testing.T) {
+	dir := t.TempDir()
+	def := filepath.Join(dir, "euchre.json")
+	os.WriteFile(def, []byte(`{
+		"name": "euchre-test",
+		"suits": ["Spades", "Diamonds", "Hearts", "Clubs"],
+		"ranks": ["Nine", "Ten", "Jack", "Queen", "King", "Ace"],
+		"specials": [{"name": "Benny", "copies": 1}]
+	}`), 0666)
+
+	cs, err := lookupCardSet(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs.size() != 25 {
+		t.Errorf("Expected 25 cards, got %v", cs.size())
+	}
+	if _, err := parseCard("Benny"); err != nil {
+		t.Errorf("Expected the new special to parse, got %v", err)
+	}
+	if again, err := lookupCardSet("euchre-test"); err != nil || again != cs {
+		t.Errorf("Expected the set to be registered under its name, got %v", err)
+	}
+	// the file is read once, naming it again gives the same set instead of registering it twice
+	if again, err := lookupCardSet(def); err != nil || again != cs {
+		t.Errorf("Expected the same set for the file again, got %v", err)
+	}
+	copyDef := filepath.Join(dir, "copy.json")
+	bs, _ := os.ReadFile(def)
+	os.WriteFile(copyDef, bs, 0666)
+	if again, err := lookupCardSet(copyDef); err != nil || again != cs {
+		t.Errorf("Expected a copy of the file to give the same set, got %v", err)
+	}
+	changed := filepath.Join(dir, "changed.json")
+	os.WriteFile(changed, bytes.Replace(bs, []byte(`"Nine", `), nil, 1), 0666)
+	if _, err := lookupCardSet(changed); !errors.Is(err, ErrBadCardSet) {
+		t.Errorf("Expected another definition with the same name to fail, got %v", err)
+	}
+
+	bad := []string{
+		`{"suits": ["Spades"], "ranks": ["Ace"]}`,
+		`{"name": "standard", "suits": ["Spades"], "ranks": ["Ace"]}`,
+		`{"name": "x", "ranks": ["Ace"]}`,
+		`{"name": "y", "suits": ["Spades"], "ranks": ["Red Joker"]}`,
+		`{"name": "z", "suits": ["Spades"], "ranks": ["Ace"], "colour": "blue"}`,
+	}
+	for i, b := range bad {
+		file := filepath.Join(dir, "bad.json")
+		os.WriteFile(file, []byte(b), 0666)
+		if _, err := lookupCardSet(file); !errors.Is(err, ErrBadCardSet) {
+			t.Errorf("%d: expected ErrBadCardSet, got %v", i, err)
+		}
+	}
+}
+
+func TestNewAndLoadWithSet(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "skat.txt")
+	if code, _, stderr := runCLI(t, "", "new", "-set", "skat", "-out", file); code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+	code, stdout, stderr := runCLI(t, "", "load", "-set", "skat", file)
+	if code != exitOK || !strings.HasPrefix(stdout, "32 cards, skat set") {
+		t.Errorf("Expected the 32 card skat deck, got %v ( %s%s )", code, stdout, stderr)
+	}
+	if code, _, _ := runCLI(t, "", "new", "-set", "canasta"); code != exitUsage {
+		t.Errorf("Expected a usage error for an unknown set, got %v", code)
+	}
+}
+
+// Reading a set file registers it while other goroutines may be printing and parsing cards.
+func TestCardSetRegistryConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := range 4 {
+		def := filepath.Join(dir, fmt.Sprintf("race%d.json", i))
+		os.WriteFile(def, []byte(fmt.Sprintf(`{"name": "race-%d", "suits": ["Race%d"], "ranks": ["Ace", "Runner%d"]}`, i, i, i)), 0666)
+		// two goroutines name the same file, only one of them reads it
+		wg.Add(3)
+		for range 2 {
+			go func() {
+				defer wg.Done()
+				if _, err := lookupCardSet(def); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		go func() {
+			defer wg.Done()
+			for _, card := range newDeckWithJokers() {
+				if parsed, err := parseCard(card.String()); err != nil || parsed != card {
+					t.Errorf("Expected %v back, got %v, %v", card, parsed, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := parseCard("Runner2 of Race2"); err != nil {
+		t.Errorf("Expected the new card to parse, got %v", err)
+	}
+}
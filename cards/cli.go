@@ -0,0 +1,546 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// The exit codes of the program.
+const (
+	exitOK    = 0
+	exitError = 1
+	// exitUsage is returned for bad flags or arguments, like most command line tools do.
+	exitUsage = 2
+)
+
+// cliEnv is where a command reads and writes, so the commands can be tested without a terminal.
+type cliEnv struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// command is one subcommand of the program, e.g. "cards shuffle".
+type command struct {
+	name    string
+	summary string
+	run     func(env cliEnv, args []string) error
+}
+
+// usageError is returned by a command when it was called the wrong way.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...any) error {
+	return usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// commands is filled in init because the help command prints the list itself.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"new", "create a new deck", runNew},
+		{"shuffle", "shuffle a deck", runShuffle},
+		{"deal", "deal hands from a deck", runDeal},
+		{"save", "save a deck to a file ( the format follows the extension )", runSave},
+		{"load", "load a deck file and show it with its metadata", runLoad},
+		{"check", "check that a deck file holds every card of its set, once", runCheck},
+		{"show", "show a deck as text, a table or json", runShow},
+		{"export", "write a deck as card codes, a PBN bridge deal or a poker hand history", runExport},
+		{"import", "read card codes, a PBN bridge deal or a poker hand history into a deck", runImport},
+		{"evaluate", "score poker hands", runEvaluate},
+		{"odds", "the exact chance of drawing ranks, suits or cards", runOdds},
+		{"outs", "count the outs of a hold'em hand on the flop or the turn", runOuts},
+		{"categories", "how often every poker hand is dealt", runCategories},
+		{"bridge", "deal bridge boards with their points and shape, -north \"15-17 balanced\" to pick the hands", runBridge},
+		{"score", "the duplicate bridge score of a contract", runScore},
+		{"blackjack", "play blackjack, interactive or headless with -rounds", runBlackjack},
+		{"war", "play War between two goroutines and show how long the games last", runWar},
+		{"gofish", "play Go Fish between goroutines and show how long the games last", runGoFish},
+		{"klondike", "deal a game of solitaire and search for a win", runKlondike},
+		{"serve", "serve decks over HTTP", runServe},
+		{"commit", "pick a secret seed for a fair shuffle and show its commitment", runCommit},
+		{"reveal", "shuffle with the players' entropy and publish the seed", runReveal},
+		{"verify", "check that a fair shuffle receipt matches its commitment", runVerify},
+		{"tournament", "pit bots against each other in the shedding game of the table", runTournamentCommand},
+		{"replay", "show a game log and the state of the game after any event", runReplay},
+		{"diff", "find where two game logs stop giving the same game", runDiff},
+		{"table", "open a card table for players over TCP", runTable},
+		{"join", "sit at a card table from the terminal", runJoin},
+		{"help", "show this help", func(env cliEnv, args []string) error {
+			printUsage(env.stdout)
+			return nil
+		}},
+	}
+}
+
+// run is the whole program: it picks the command from args ( without the program name )
+// and returns the exit code. Only main calls os.Exit.
+func run(args []string, env cliEnv) int {
+	if len(args) == 0 {
+		printUsage(env.stderr)
+		return exitUsage
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(env, args[1:])
+		var usage usageError
+		switch {
+		case err == nil, errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.As(err, &usage):
+			fmt.Fprintf(env.stderr, "cards %s: %v\n", cmd.name, err)
+			return exitUsage
+		default:
+			fmt.Fprintf(env.stderr, "cards %s: %v\n", cmd.name, err)
+			return exitError
+		}
+	}
+
+	fmt.Fprintf(env.stderr, "cards: unknown command %q\n\n", args[0])
+	printUsage(env.stderr)
+	return exitUsage
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: cards <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "cards <command> -h" for the flags of a command.`)
+}
+
+// newFlagSet makes a flag set that reports bad flags as usage errors instead of exiting.
+func newFlagSet(env cliEnv, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("cards "+name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{msg: err.Error()}
+	}
+	return nil
+}
+
+// deckFlags are the flags shared by the commands that read a deck and write it back.
+type deckFlags struct {
+	in     *string
+	out    *string
+	format *string
+}
+
+func addDeckFlags(fs *flag.FlagSet) deckFlags {
+	return deckFlags{
+		in:     fs.String("in", "", "read the deck from this file instead of starting from a new deck"),
+		out:    fs.String("out", "", "save the deck to this file instead of showing it"),
+		format: fs.String("format", "text", "how to show the deck: text, table, json, compact, glyph, box or ascii"),
+	}
+}
+
+// load returns the deck of -in, or a new deck when -in isn't set.
+func (f deckFlags) load() (deck, deckInfo, error) {
+	if *f.in == "" {
+		return newDeck(), deckInfo{Created: time.Now()}, nil
+	}
+	return LoadDeckInfo(*f.in)
+}
+
+// write saves the deck to -out, or shows it on stdout in -format.
+func (f deckFlags) write(env cliEnv, d deck, info deckInfo) error {
+	if *f.out != "" {
+		return SaveDeckInfo(*f.out, d, info)
+	}
+	return showDeck(env.stdout, d, *f.format)
+}
+
+func showDeck(w io.Writer, d deck, format string) error {
+	switch format {
+	case "text":
+		d.fprint(w)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "#\tCARD\tRANK\tSUIT")
+		for i, card := range d {
+			suit := ""
+			if card.Suit != noSuit {
+				suit = card.Suit.String()
+			}
+			fmt.Fprintf(tw, "%d\t%v\t%v\t%s\n", i, card, card.Rank, suit)
+		}
+		return tw.Flush()
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	default:
+		style, ok := parseRenderStyle(format)
+		if !ok {
+			return usagef("unknown format %q, expected text, table, json, compact, glyph, box or ascii", format)
+		}
+		return newRenderer(w, style).render(w, d)
+	}
+	return nil
+}
+
+func runNew(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "new")
+	jokers := fs.Bool("jokers", false, "add the two jokers")
+	set := fs.String("set", "", "build a deck of this card set ( "+strings.Join(cardSetNames(), ", ")+" ) or of a .json definition file")
+	out := fs.String("out", "", "save the deck to this file instead of showing it")
+	format := fs.String("format", "text", "how to show the deck: text, table, json, compact, glyph, box or ascii")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	d, info := newDeck(), deckInfo{Created: time.Now()}
+	switch {
+	case *jokers && *set != "":
+		return usagef("-jokers and -set can't be used together, use -set jokers")
+	case *jokers:
+		d = newDeckWithJokers()
+	case *set != "":
+		cs, err := lookupCardSet(*set)
+		if err != nil {
+			return usageError{msg: err.Error()}
+		}
+		d, info.Set = cs.newDeck(), cs.Name
+	}
+	if *out != "" {
+		return SaveDeckInfo(*out, d, info)
+	}
+	return showDeck(env.stdout, d, *format)
+}
+
+func runShuffle(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "shuffle")
+	files := addDeckFlags(fs)
+	seed := fs.Int64("seed", 0, "shuffle with this seed ( the same seed gives the same order ), a random one when not set")
+	secure := fs.Bool("crypto", false, "use the secure random generator ( can't be used with -seed )")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	seeded := flagSet(fs, "seed")
+	if seeded && *secure {
+		return usagef("-seed and -crypto can't be used together")
+	}
+
+	d, info, err := files.load()
+	if err != nil {
+		return err
+	}
+	switch {
+	case *secure:
+		d.cryptoShuffle()
+		info.Seed = nil
+	default:
+		if !seeded {
+			*seed = time.Now().UnixNano()
+		}
+		d.shuffleSeed(*seed)
+		// the seed only rebuilds the order when the shuffle started from a new deck
+		info.Seed = nil
+		if *files.in == "" {
+			info.Seed = seed
+		}
+	}
+	return files.write(env, d, info)
+}
+
+func runDeal(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "deal")
+	in := fs.String("in", "", "deal from the deck in this file instead of a new shuffled deck")
+	seed := fs.Int64("seed", 0, "seed for shuffling the new deck, a random one when not set")
+	hands := fs.Int("hands", 2, "number of hands")
+	size := fs.Int("size", 5, "cards per hand")
+	burn := fs.Int("burn", 0, "cards to burn before dealing")
+	blocks := fs.Bool("blocks", false, "deal each hand in one block instead of one card at a time")
+	format := fs.String("format", "text", "how to show the hands: text, table, json, compact, glyph, box or ascii")
+	logFile := fs.String("log", "", "append the deal to this game log ( JSON Lines, see cards replay )")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *hands < 1 || *size < 0 || *burn < 0 {
+		return usagef("need at least 1 hand and no negative sizes")
+	}
+
+	var dl *dealer
+	var start gameEvent
+	if *in != "" {
+		d, err := LoadDeck(*in)
+		if err != nil {
+			return err
+		}
+		dl = newDealer(d)
+		start = gameEvent{Type: eventNew, Cards: d}
+	} else {
+		if !flagSet(fs, "seed") {
+			*seed = time.Now().UnixNano()
+		}
+		dl = newSeededDealer(*seed)
+	}
+
+	if err := dl.burn(*burn); err != nil {
+		return err
+	}
+	strategy := dealRoundRobin
+	if *blocks {
+		strategy = dealBlocks
+	}
+	dealt, err := dl.dealHands(*hands, *size, strategy)
+	if err != nil {
+		return err
+	}
+	if *logFile != "" {
+		err := appendGameLog(*logFile, func(g *gameRecorder) error {
+			// the log deals again from the same deck, so it holds the same cards as dl
+			if start.Type == "" {
+				if err := g.newGame("standard"); err != nil {
+					return err
+				}
+				if err := g.shuffle(*seed); err != nil {
+					return err
+				}
+			} else if _, err := g.record(start); err != nil {
+				return err
+			}
+			if *burn > 0 {
+				if _, err := g.burn(*burn); err != nil {
+					return err
+				}
+			}
+			_, err := g.deal(*hands, *size, strategy)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(env.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]any{"hands": dealt, "log": dl.history()})
+	}
+	for i, hand := range dealt {
+		fmt.Fprintf(env.stdout, "Hand %d:\n", i+1)
+		if err := showDeck(env.stdout, hand, *format); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(env.stdout, "%d cards left in the deck\n", dl.remaining())
+	return nil
+}
+
+func runSave(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "save")
+	in := fs.String("in", "", "the deck to save, a new deck when not set")
+	keyFile := fs.String("key", "", "sign the file with the key in this file ( the signed format, whatever the extension )")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected the file to save to, e.g. cards save deck.json")
+	}
+
+	d, info := newDeck(), deckInfo{Created: time.Now()}
+	if *in != "" {
+		var err error
+		if d, info, err = LoadDeckInfo(*in); err != nil {
+			return err
+		}
+	}
+	if *keyFile != "" {
+		key, err := readKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		return SaveSignedDeck(fs.Arg(0), d, info, key)
+	}
+	return SaveDeckInfo(fs.Arg(0), d, info)
+}
+
+func runLoad(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "load")
+	format := fs.String("format", "text", "how to show the deck: text, table, json, compact, glyph, box or ascii")
+	set := fs.String("set", "", "validate the deck against this card set")
+	keyFile := fs.String("key", "", "check the signature of a signed file with the key in this file")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected the file to load, e.g. cards load deck.json")
+	}
+
+	var (
+		d    deck
+		info deckInfo
+		err  error
+	)
+	if *keyFile != "" {
+		var key []byte
+		if key, err = readKey(*keyFile); err != nil {
+			return err
+		}
+		d, info, err = LoadSignedDeck(fs.Arg(0), *set, key)
+	} else {
+		d, info, err = LoadDeckAs(fs.Arg(0), *set)
+	}
+	if err != nil {
+		return err
+	}
+	if *format != "json" {
+		fmt.Fprintf(env.stdout, "%d cards", len(d))
+		if !info.Created.IsZero() {
+			fmt.Fprintf(env.stdout, ", created %v", info.Created.Format(time.RFC3339))
+		}
+		if info.Seed != nil {
+			fmt.Fprintf(env.stdout, ", seed %d", *info.Seed)
+		}
+		if info.Set != "" {
+			fmt.Fprintf(env.stdout, ", %s set", info.Set)
+		}
+		fmt.Fprintln(env.stdout)
+	}
+	return showDeck(env.stdout, d, *format)
+}
+
+// runCheck compares a deck file with the full deck of its set and lists what is missing,
+// duplicated or unknown. An incomplete deck is an error, so scripts can test the exit code.
+func runCheck(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "check")
+	set := fs.String("set", "", "the card set of the deck, the one stored in the file or standard when not set")
+	keyFile := fs.String("key", "", "check the signature of a signed file with the key in this file")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected the file to check, e.g. cards check deck.json")
+	}
+
+	var key []byte
+	if *keyFile != "" {
+		var err error
+		if key, err = readKey(*keyFile); err != nil {
+			return err
+		}
+	}
+	r, err := checkDeckFile(fs.Arg(0), *set, key)
+	if err != nil {
+		return err
+	}
+	r.print(env.stdout)
+	return r.err()
+}
+
+func runShow(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "show")
+	files := addDeckFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	d, _, err := files.load()
+	if err != nil {
+		return err
+	}
+	return showDeck(env.stdout, d, *files.format)
+}
+
+// runEvaluate scores one hand, or compares several hands that share a board.
+// Cards are given by name and separated by commas, e.g. "Ace of Spades,King of Spades".
+func runEvaluate(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "evaluate")
+	board := fs.String("board", "", "community cards shared by all the hands")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef(`expected at least one hand, e.g. cards evaluate "Ace of Spades,King of Spades,Queen of Spades,Jack of Spades,Ten of Spades"`)
+	}
+
+	boardCards, err := parseCardList(*board)
+	if err != nil {
+		return usagef("board: %v", err)
+	}
+	hands := make([]deck, fs.NArg())
+	for i, arg := range fs.Args() {
+		if hands[i], err = parseCardList(arg); err != nil {
+			return usagef("hand %d: %v", i+1, err)
+		}
+	}
+
+	s, err := compareHands(hands, boardCards)
+	if err != nil {
+		return err
+	}
+	if len(hands) == 1 {
+		fmt.Fprintln(env.stdout, s.Results[0].Value)
+		return nil
+	}
+	for _, r := range s.Results {
+		fmt.Fprintf(env.stdout, "%d. Hand %d: %v\n", r.Place, r.Player+1, r.Value)
+	}
+	if s.split() {
+		fmt.Fprintln(env.stdout, "Split pot")
+	}
+	return nil
+}
+
+// parseCardList parses comma separated card names, or the compact codes separated by spaces
+// ( "AS KS QS" ). An empty string is an empty deck.
+func parseCardList(s string) (deck, error) {
+	if strings.TrimSpace(s) == "" {
+		return deck{}, nil
+	}
+	if !strings.Contains(s, ",") {
+		if d, err := parseCompact(s); err == nil {
+			return d, nil
+		}
+	}
+	return parseCards(strings.Split(s, ","))
+}
+
+// stringsFlag is a flag that can be repeated, every value is kept.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// flagSet tells if the flag was given on the command line ( and not just left at its default ).
+func flagSet(fs *flag.FlagSet, name string) bool {
+	found := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
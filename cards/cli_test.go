@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCLI runs the program with the arguments and returns the exit code and what it printed.
+func runCLI(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, cliEnv{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr})
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunExitCodes(t *testing.T) {
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{nil, exitUsage},
+		{[]string{"nope"}, exitUsage},
+		{[]string{"help"}, exitOK},
+		{[]string{"new", "-h"}, exitOK},
+		{[]string{"new", "-bogus"}, exitUsage},
+		{[]string{"show", "-format", "yaml"}, exitUsage},
+		{[]string{"shuffle", "-seed", "1", "-crypto"}, exitUsage},
+		{[]string{"load", filepath.Join(t.TempDir(), "missing.json")}, exitError},
+		{[]string{"deal", "-hands", "11", "-size", "5"}, exitError},
+		{[]string{"evaluate", "Ace of Spades"}, exitError},
+		{[]string{"evaluate", "Ace of Stars"}, exitUsage},
+	}
+	for _, tt := range tests {
+		if code, _, stderr := runCLI(t, "", tt.args...); code != tt.code {
+			t.Errorf("%v: expected exit code %v, got %v ( %s )", tt.args, tt.code, code, stderr)
+		}
+	}
+}
+
+func TestRunShuffleSaveAndLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "deck.json")
+	if code, _, stderr := runCLI(t, "", "shuffle", "-seed", "42", "-out", filename); code != exitOK {
+		t.Fatalf("shuffle failed: %s", stderr)
+	}
+
+	code, stdout, stderr := runCLI(t, "", "load", filename)
+	if code != exitOK {
+		t.Fatalf("load failed: %s", stderr)
+	}
+	d := newDeck()
+	d.shuffleSeed(42)
+	if !strings.Contains(stdout, "52 cards") || !strings.Contains(stdout, "seed 42") || !strings.Contains(stdout, "0 "+d[0].String()) {
+		t.Errorf("Unexpected load output:\n%s", stdout)
+	}
+
+	// converting the file to another format keeps the cards
+	csvFile := filepath.Join(t.TempDir(), "deck.csv")
+	if code, _, stderr := runCLI(t, "", "save", "-in", filename, csvFile); code != exitOK {
+		t.Fatalf("save failed: %s", stderr)
+	}
+	loaded, err := LoadDeck(csvFile)
+	if err != nil || loaded.toString() != d.toString() {
+		t.Errorf("Expected the shuffled deck in the csv file, got %v", err)
+	}
+}
+
+func TestRunDealAndEvaluate(t *testing.T) {
+	code, stdout, _ := runCLI(t, "", "deal", "-hands", "4", "-size", "5", "-seed", "7")
+	if code != exitOK || strings.Count(stdout, "Hand ") != 4 || !strings.Contains(stdout, "32 cards left") {
+		t.Errorf("Unexpected deal output:\n%s", stdout)
+	}
+
+	code, stdout, _ = runCLI(t, "", "evaluate", "-board", "Ten of Spades,Jack of Spades,Queen of Spades",
+		"Ace of Spades,King of Spades", "Two of Hearts,Three of Clubs")
+	if code != exitOK || !strings.HasPrefix(stdout, "1. Hand 1: Royal Flush") {
+		t.Errorf("Unexpected evaluate output:\n%s", stdout)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// InsufficientCardsError is returned when the deck runs out of cards in the middle of a deal.
+// Nothing is dealt when the error is returned - the deck is left as it was.
+type InsufficientCardsError struct {
+	Need int
+	Have int
+}
+
+func (e *InsufficientCardsError) Error() string {
+	return fmt.Sprintf("not enough cards: need %d, have %d", e.Need, e.Have)
+}
+
+// dealStrategy decides in what order the cards go to the players.
+type dealStrategy int
+
+const (
+	// dealRoundRobin gives one card to every player in turn, like at a real table.
+	dealRoundRobin dealStrategy = iota
+	// dealBlocks gives the whole hand to the first player, then to the next one.
+	dealBlocks
+)
+
+func (s dealStrategy) String() string {
+	switch s {
+	case dealRoundRobin:
+		return "round-robin"
+	case dealBlocks:
+		return "blocks"
+	}
+	return fmt.Sprintf("dealStrategy(%d)", int(s))
+}
+
+// The actions written in the deal log.
+const (
+	actionHand  = "hand"
+	actionBurn  = "burn"
+	actionBoard = "board"
+)
+
+// dealEvent is one card leaving the deck. Player is only set for the "hand" action.
+type dealEvent struct {
+	Action string `json:"action"`
+	Player int    `json:"player,omitempty"`
+	Round  string `json:"round,omitempty"`
+	Card   Card   `json:"card"`
+}
+
+// dealLog is everything needed to audit a deal: where the deck came from and every card that was dealt.
+// A seeded deal only keeps the seed - the starting order is rebuilt with newDeck and shuffleSeed.
+type dealLog struct {
+	Seed   *int64      `json:"seed,omitempty"`
+	Start  deck        `json:"start,omitempty"`
+	Events []dealEvent `json:"events"`
+}
+
+// dealer owns a deck and deals from the top of it, keeping a log of every card it hands out.
+type dealer struct {
+	cards  deck
+	hands  []deck
+	board  deck
+	burned deck
+	log    dealLog
+}
+
+// newDealer deals from the deck as it is. The deck is copied so the caller's slice isn't changed.
+func newDealer(d deck) *dealer {
+	cards := make(deck, len(d))
+	copy(cards, d)
+	start := make(deck, len(d))
+	copy(start, d)
+	return &dealer{cards: cards, log: dealLog{Start: start}}
+}
+
+// newSeededDealer shuffles a new deck with the seed. The same seed always deals the same cards.
+func newSeededDealer(seed int64) *dealer {
+	cards := newDeck()
+	cards.shuffleSeed(seed)
+	return &dealer{cards: cards, log: dealLog{Seed: &seed}}
+}
+
+// remaining is the number of cards left in the deck.
+func (dl *dealer) remaining() int {
+	return len(dl.cards)
+}
+
+// take removes n cards from the top of the deck.
+func (dl *dealer) take(n int) (deck, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("can't deal %d cards", n)
+	}
+	if n > len(dl.cards) {
+		return nil, &InsufficientCardsError{Need: n, Have: len(dl.cards)}
+	}
+	cards := dl.cards[:n:n]
+	dl.cards = dl.cards[n:]
+	return cards, nil
+}
+
+// dealHands deals size cards to each of the players and returns the cards dealt by this call.
+// Calling it again adds more cards to the same hands ( see hand ).
+func (dl *dealer) dealHands(players, size int, strategy dealStrategy) ([]deck, error) {
+	if players <= 0 {
+		return nil, fmt.Errorf("can't deal to %d players", players)
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("can't deal hands of %d cards", size)
+	}
+	if size > 0 && players > math.MaxInt/size {
+		return nil, fmt.Errorf("can't deal %d hands of %d cards", players, size)
+	}
+
+	cards, err := dl.take(players * size)
+	if err != nil {
+		return nil, err
+	}
+
+	dealt := make([]deck, players)
+	for i, card := range cards {
+		player := i % players
+		if strategy == dealBlocks {
+			player = i / size
+		}
+		dealt[player] = append(dealt[player], card)
+		dl.log.Events = append(dl.log.Events, dealEvent{Action: actionHand, Player: player, Card: card})
+	}
+
+	for len(dl.hands) < players {
+		dl.hands = append(dl.hands, deck{})
+	}
+	for player, cards := range dealt {
+		dl.hands[player] = append(dl.hands[player], cards...)
+	}
+	return dealt, nil
+}
+
+// burn discards n cards from the top of the deck face down.
+func (dl *dealer) burn(n int) error {
+	cards, err := dl.take(n)
+	if err != nil {
+		return err
+	}
+	dl.burned = append(dl.burned, cards...)
+	for _, card := range cards {
+		dl.log.Events = append(dl.log.Events, dealEvent{Action: actionBurn, Card: card})
+	}
+	return nil
+}
+
+// dealCommunity burns burnCards and then deals count cards face up to the board.
+// The round name ( "flop", "turn", ... ) is only written to the log.
+func (dl *dealer) dealCommunity(round string, burnCards, count int) (deck, error) {
+	if need := burnCards + count; need > len(dl.cards) {
+		return nil, &InsufficientCardsError{Need: need, Have: len(dl.cards)}
+	}
+	if err := dl.burn(burnCards); err != nil {
+		return nil, err
+	}
+
+	cards, err := dl.take(count)
+	if err != nil {
+		return nil, err
+	}
+	dl.board = append(dl.board, cards...)
+	for _, card := range cards {
+		dl.log.Events = append(dl.log.Events, dealEvent{Action: actionBoard, Round: round, Card: card})
+	}
+	return cards, nil
+}
+
+// The hold'em community rounds - one burn card before each of them.
+func (dl *dealer) flop() (deck, error)  { return dl.dealCommunity("flop", 1, 3) }
+func (dl *dealer) turn() (deck, error)  { return dl.dealCommunity("turn", 1, 1) }
+func (dl *dealer) river() (deck, error) { return dl.dealCommunity("river", 1, 1) }
+
+// hand returns all the cards dealt to the player so far.
+func (dl *dealer) hand(player int) deck {
+	if player < 0 || player >= len(dl.hands) {
+		return nil
+	}
+	return dl.hands[player]
+}
+
+// history returns a copy of the log so far.
+func (dl *dealer) history() dealLog {
+	l := dl.log
+	l.Events = append([]dealEvent(nil), dl.log.Events...)
+	return l
+}
+
+// replayDeal rebuilds the starting deck of the log and deals every logged card again.
+// It fails if a card in the log doesn't match the card on top of the rebuilt deck,
+// which means the log was changed or doesn't belong to that seed.
+func replayDeal(l dealLog) (*dealer, error) {
+	var dl *dealer
+	if l.Seed != nil {
+		dl = newSeededDealer(*l.Seed)
+	} else {
+		dl = newDealer(l.Start)
+	}
+
+	for i, event := range l.Events {
+		cards, err := dl.take(1)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", i, err)
+		}
+		card := cards[0]
+		if card != event.Card {
+			return nil, fmt.Errorf("event %d: log says %v but the deck gives %v", i, event.Card, card)
+		}
+
+		switch event.Action {
+		case actionHand:
+			if event.Player < 0 {
+				return nil, fmt.Errorf("event %d: bad player %d", i, event.Player)
+			}
+			for len(dl.hands) <= event.Player {
+				dl.hands = append(dl.hands, deck{})
+			}
+			dl.hands[event.Player] = append(dl.hands[event.Player], card)
+		case actionBurn:
+			dl.burned = append(dl.burned, card)
+		case actionBoard:
+			dl.board = append(dl.board, card)
+		default:
+			return nil, fmt.Errorf("event %d: unknown action %q", i, event.Action)
+		}
+		dl.log.Events = append(dl.log.Events, event)
+	}
+	return dl, nil
+}
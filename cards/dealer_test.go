@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDeal(t *testing.T) {
+	hand, rest, err := deal(newDeck(), 5)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(hand) != 5 || len(rest) != 47 {
+		t.Errorf("Expected 5 and 47 cards, got %v and %v", len(hand), len(rest))
+	}
+
+	var insufficient *InsufficientCardsError
+	if _, _, err := deal(newDeck(), 53); !errors.As(err, &insufficient) {
+		t.Fatalf("Expected an InsufficientCardsError, got %v", err)
+	}
+	if insufficient.Need != 53 || insufficient.Have != 52 {
+		t.Errorf("Expected need 53 have 52, got %+v", insufficient)
+	}
+}
+
+func TestDealHandsStrategies(t *testing.T) {
+	d := newDeck()
+
+	roundRobin := newDealer(d)
+	hands, err := roundRobin.dealHands(4, 2, dealRoundRobin)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// the first player gets the 1st and the 5th card
+	if hands[0][0] != d[0] || hands[0][1] != d[4] || hands[3][1] != d[7] {
+		t.Errorf("Unexpected round robin deal: %v", hands)
+	}
+
+	blocks := newDealer(d)
+	hands, err = blocks.dealHands(4, 2, dealBlocks)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// the first player gets the 1st and the 2nd card
+	if hands[0][0] != d[0] || hands[0][1] != d[1] || hands[3][1] != d[7] {
+		t.Errorf("Unexpected block deal: %v", hands)
+	}
+
+	if roundRobin.remaining() != 44 || blocks.remaining() != 44 {
+		t.Errorf("Expected 44 cards left, got %v and %v", roundRobin.remaining(), blocks.remaining())
+	}
+}
+
+func TestDealHandsInsufficientCards(t *testing.T) {
+	dl := newSeededDealer(1)
+	var insufficient *InsufficientCardsError
+	if _, err := dl.dealHands(11, 5, dealRoundRobin); !errors.As(err, &insufficient) {
+		t.Fatalf("Expected an InsufficientCardsError, got %v", err)
+	}
+	if dl.remaining() != 52 {
+		t.Errorf("Expected the deck to be untouched, got %v cards", dl.remaining())
+	}
+	// players * size overflows to 0, that mustn't look like an empty deal
+	if _, err := dl.dealHands(1<<32, 1<<32, dealRoundRobin); err == nil {
+		t.Errorf("Expected an error when players * size overflows")
+	}
+}
+
+// A hold'em hand: 2 cards each, flop, turn and river with a burn card before each round.
+func TestDealCommunityRounds(t *testing.T) {
+	dl := newSeededDealer(99)
+	if _, err := dl.dealHands(6, 2, dealRoundRobin); err != nil {
+		t.Fatal(err)
+	}
+	for _, round := range []func() (deck, error){dl.flop, dl.turn, dl.river} {
+		if _, err := round(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(dl.board) != 5 || len(dl.burned) != 3 {
+		t.Errorf("Expected 5 board and 3 burned cards, got %v and %v", len(dl.board), len(dl.burned))
+	}
+	if dl.remaining() != 52-12-8 {
+		t.Errorf("Expected %v cards left, got %v", 52-12-8, dl.remaining())
+	}
+}
+
+// The log has to replay to the same hands, also after a trip through JSON.
+func TestReplayDeal(t *testing.T) {
+	dl := newSeededDealer(2024)
+	dl.dealHands(3, 2, dealRoundRobin)
+	dl.flop()
+
+	bs, err := json.Marshal(dl.history())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l dealLog
+	if err := json.Unmarshal(bs, &l); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := replayDeal(l)
+	if err != nil {
+		t.Fatalf("Expected the log to replay, got %v", err)
+	}
+	for player := range 3 {
+		if replayed.hand(player).toString() != dl.hand(player).toString() {
+			t.Errorf("Player %v: expected %v, got %v", player, dl.hand(player), replayed.hand(player))
+		}
+	}
+	if replayed.board.toString() != dl.board.toString() {
+		t.Errorf("Expected board %v, got %v", dl.board, replayed.board)
+	}
+
+	// changing a single card in the log must be caught
+	l.Events[0].Card, l.Events[1].Card = l.Events[1].Card, l.Events[0].Card
+	if _, err := replayDeal(l); err == nil {
+		t.Errorf("Expected the changed log to fail the replay")
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	crand "crypto/rand"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"strings"
@@ -9,43 +12,65 @@ import (
 )
 
 // Create a new type of 'deck'
-// which is a slice of strings
+// which is a slice of cards ( see card.go for the Card type )
 
-type deck []string
+type deck []Card
 
 // function extending type deck with a receiver
 // (d deck) - receiver !
 // Any variable of type 'deck' gets access to the print method.
 func (d deck) print() {
+	d.fprint(os.Stdout)
+}
+
+// fprint is print to any writer ( a file, a buffer in the tests, ... ).
+func (d deck) fprint(w io.Writer) {
 	for i, card := range d {
-		fmt.Println(i, card)
+		fmt.Fprintln(w, i, card)
 	}
 }
 
+// newDeck builds the standard 52 card deck - Ace to King for every suit.
+// Other decks ( Pinochle, Tarot, Uno, ... ) are built from their card set, see cardset.go.
 func newDeck() deck {
-	cards := deck{}
+	cards := make(deck, 0, len(standardSuits)*len(standardRanks))
 
-	cardSuits := []string{"Spades", "Diamonds", "Hearts", "Clubs"}
-	cardValues := []string{"Ace", "Two", "Three", "Four"}
 	// _ - dummy iterator with no meaning outside of the iteration.
-	for _, suit := range cardSuits {
-		for _, value := range cardValues {
-			cards = append(cards, value+" of "+suit)
+	for _, suit := range standardSuits {
+		for _, value := range standardRanks {
+			cards = append(cards, Card{Rank: value, Suit: suit})
 		}
 	}
 	return cards
 }
 
+// newDeckWithJokers is the standard deck with the black and the red joker added at the end ( 54 cards ).
+func newDeckWithJokers() deck {
+	return append(newDeck(), Card{Rank: BlackJoker, Suit: noSuit}, Card{Rank: RedJoker, Suit: noSuit})
+}
+
 // Defining a function with multiple return values ( GO supports multiple return values from a function)
-// returning two values of type deck -> (deck, deck)
-func deal(d deck, handSize int) (deck, deck) {
-	return d[:handSize], d[handSize:]
+// returning two values of type deck and an error -> (deck, deck, error)
+// Asking for more cards than the deck has returns an *InsufficientCardsError instead of panicking.
+// See dealer.go for dealing to more than one player.
+func deal(d deck, handSize int) (deck, deck, error) {
+	if handSize < 0 {
+		return nil, nil, fmt.Errorf("can't deal %d cards", handSize)
+	}
+	if handSize > len(d) {
+		return nil, nil, &InsufficientCardsError{Need: handSize, Have: len(d)}
+	}
+	return d[:handSize], d[handSize:], nil
 }
 
-// converting a slice of strings into one big string by , separator
+// converting a slice of cards into one big string by , separator
+// every card is written with its "Value of Suit" name
 func (d deck) toString() string {
-	return strings.Join([]string(d), ",")
-
+	names := make([]string, len(d))
+	for i, card := range d {
+		names[i] = card.String()
+	}
+	return strings.Join(names, ",")
 }
 
 // Save to local file function following the documentation it needs to return type of error, if something goes wrong.
@@ -57,37 +82,69 @@ func (d deck) saveToFile(filename string) error {
 // Read a file from a the local fs - after you read a file you need to return a string of characters
 // No receiver needed here because we need a brand new deck. The func will return a byte slice (bs ) and err (if any or nil)
 // Opposite to the WriteFile here we need to go in reverse order from []byte -> string -> []string -> deck
-func newDeckFromFile(filename string) deck {
+// every name is parsed back into a Card with parseCard
+// The error is returned to the caller instead of stopping the program - see LoadDeck in persist.go for the other formats.
+func newDeckFromFile(filename string) (deck, error) {
 	bs, err := os.ReadFile(filename)
 	// error handling in go like this:
 	if err != nil {
-		// Option #1 - log the error and return a call to newDeck().
-		// Option #2 - stop the execution and log the error.
-		fmt.Println("Error:", err)
-		os.Exit(1)
+		return nil, err
 	}
 
-	s := strings.Split(string(bs), ",")
-	return deck(s)
+	d, _, err := decodeDeck(formatLegacy, bs)
+	return d, err
 }
 
-// Shuffle function logic:
-// for each index, card in cards
-//   gen random number between 0 - len(cards) - 1
-//   Swap the current card and the card at cards[randomNumber]
+// Shuffle function logic ( Fisher-Yates ):
+// for each index i from the last card down to 1
+//   gen random number j between 0 - i ( both included )
+//   Swap the card at i with the card at cards[j]
+// Picking j from 0..i ( and not from the whole deck ) is what makes every order equally likely.
 
+// shuffle mixes the deck with a time based seed.
 func (d deck) shuffle() {
-	// true random number generator, by creating our own source from which the seed for randomization will be taken
 	// for the actual seed value - UnixNano from the Time lib will be used (nano sec from 1970)
 	// time.Now().UnixNano() - gives the time when called and converts to UnixNano sec.
-	source := rand.NewSource(time.Now().UnixNano())
+	d.shuffleSeed(time.Now().UnixNano())
+}
+
+// shuffleSeed mixes the deck from a fixed seed - the same seed always gives the same order,
+// which is what tests and replays need.
+func (d deck) shuffleSeed(seed int64) {
+	d.shuffleWith(rand.NewSource(seed))
+}
+
+// shuffleWith mixes the deck using any random source passed in by the caller.
+func (d deck) shuffleWith(source rand.Source) {
 	r := rand.New(source)
-	for i := range d {
-		// rand.Intn() - will generate a random number from the length of the slice
-		newPosition := r.Intn(len(d) - 1)
-		fmt.Println(newPosition)
-		// one line swap - i takes newPosition and newPosition takes i.
-		d[i], d[newPosition] = d[newPosition], d[i]
+	for i := len(d) - 1; i > 0; i-- {
+		// rand.Intn(i + 1) - will generate a random number between 0 and i
+		j := r.Intn(i + 1)
+		// one line swap - i takes j and j takes i.
+		d[i], d[j] = d[j], d[i]
 	}
+}
+
+// cryptoShuffle mixes the deck with the operating system's secure random generator.
+// Use it for anything where money is involved - the order can't be predicted from a seed.
+func (d deck) cryptoShuffle() {
+	d.shuffleWith(cryptoSource{})
+}
+
+// cryptoSource is a rand.Source that reads from crypto/rand instead of a seeded generator.
+// rand.Intn only needs Int63 so the shuffle stays the same Fisher-Yates loop.
+type cryptoSource struct{}
 
+func (cryptoSource) Int63() int64 {
+	return int64(cryptoSource{}.Uint64() & (1<<63 - 1))
 }
+
+func (cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	// crypto/rand.Read never fails - it crashes the program if the OS can't provide randomness.
+	crand.Read(b[:])
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// Seed does nothing - a secure source can't be seeded.
+func (cryptoSource) Seed(int64) {}
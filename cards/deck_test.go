@@ -6,6 +6,7 @@
 package main
 
 import (
+	"math/rand"
 	"os"
 	"testing"
 )
@@ -15,17 +16,62 @@ func TestNewDeck(t *testing.T) {
 	// Test the length of a Deck
 	// t - test handler
 	d := newDeck()
-	if len(d) != 16 {
-		t.Errorf("Expected deck length of 16, but got %v", len(d))
+	if len(d) != 52 {
+		t.Errorf("Expected deck length of 52, but got %v", len(d))
 	}
 
 	// Here we are checking the order of the deck by taking the first and last cards.
-	if d[0] != "Ace of Spades" {
+	if d[0].String() != "Ace of Spades" {
 		t.Errorf("Expected first card of Ace of Spades, but got %v", d[0])
 	}
 
-	if d[len(d)-1] != "Four of Clubs" {
-		t.Errorf("Expected last card of Four of Clubs, but got %v", d[len(d)-1])
+	if d[len(d)-1].String() != "King of Clubs" {
+		t.Errorf("Expected last card of King of Clubs, but got %v", d[len(d)-1])
+	}
+}
+
+func TestNewDeckWithJokers(t *testing.T) {
+	d := newDeckWithJokers()
+	if len(d) != 54 {
+		t.Errorf("Expected deck length of 54, but got %v", len(d))
+	}
+	if d[len(d)-1].String() != "Red Joker" {
+		t.Errorf("Expected last card of Red Joker, but got %v", d[len(d)-1])
+	}
+}
+
+// Every card name needs to parse back into the same card.
+func TestParseCardRoundTrip(t *testing.T) {
+	for _, card := range newDeckWithJokers() {
+		parsed, err := parseCard(card.String())
+		if err != nil {
+			t.Fatalf("parseCard(%q) failed: %v", card.String(), err)
+		}
+		if parsed != card {
+			t.Errorf("Expected %v after the round trip, got %v", card, parsed)
+		}
+	}
+
+	for _, bad := range []string{"", "Ace", "One of Spades", "Ace of Stars", "Ace of Spades of Hearts"} {
+		if _, err := parseCard(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestCardCompare(t *testing.T) {
+	aceSpades := Card{Rank: Ace, Suit: Spades}
+	kingSpades := Card{Rank: King, Suit: Spades}
+	twoHearts := Card{Rank: Two, Suit: Hearts}
+
+	if aceSpades.compare(kingSpades) >= 0 {
+		t.Errorf("Expected %v before %v in deck order", aceSpades, kingSpades)
+	}
+	if kingSpades.compare(twoHearts) >= 0 {
+		t.Errorf("Expected %v before %v in deck order", kingSpades, twoHearts)
+	}
+	if aceSpades.compareRank(kingSpades) <= 0 {
+		t.Errorf("Expected %v to outrank %v", aceSpades, kingSpades)
 	}
 }
 
@@ -39,11 +85,172 @@ func TestSaveToDeckAndNewDeckFromFIle(t *testing.T) {
 
 	deck := newDeck()
 	deck.saveToFile("_decktesting")
-	loadedDeck := newDeckFromFile("_decktesting")
+	loadedDeck, err := newDeckFromFile("_decktesting")
+	if err != nil {
+		t.Fatalf("Expected to load the deck, got %v", err)
+	}
 
-	if len(loadedDeck) != 16 {
-		t.Errorf("Expected 16 cards in deck, got %v", len(loadedDeck))
+	if len(loadedDeck) != 52 {
+		t.Errorf("Expected 52 cards in deck, got %v", len(loadedDeck))
+	}
+	if loadedDeck[0] != deck[0] {
+		t.Errorf("Expected first card of %v, got %v", deck[0], loadedDeck[0])
 	}
 
 	os.Remove("_decktesting")
 }
+
+// The same seed has to give the same order every time - that's what makes a shuffle replayable.
+func TestShuffleSeedIsReproducible(t *testing.T) {
+	d1 := newDeck()
+	d2 := newDeck()
+	d1.shuffleSeed(42)
+	d2.shuffleSeed(42)
+	if d1.toString() != d2.toString() {
+		t.Errorf("Expected the same order for the same seed")
+	}
+
+	d3 := newDeck()
+	d3.shuffleSeed(43)
+	if d1.toString() == d3.toString() {
+		t.Errorf("Expected a different order for a different seed")
+	}
+}
+
+// A shuffle must never lose or duplicate a card.
+func TestShuffleKeepsAllCards(t *testing.T) {
+	for _, shuffle := range []func(deck){
+		deck.shuffle,
+		deck.cryptoShuffle,
+		func(d deck) { d.shuffleSeed(7) },
+	} {
+		d := newDeck()
+		shuffle(d)
+		seen := map[Card]bool{}
+		for _, card := range d {
+			seen[card] = true
+		}
+		if len(d) != 52 || len(seen) != 52 {
+			t.Errorf("Expected 52 different cards after the shuffle, got %v cards and %v different", len(d), len(seen))
+		}
+	}
+}
+
+// Shuffle a 4 card deck many times and count how often every one of the 24 orders comes out.
+// With a fair shuffle every order shows up about runs/24 times. The chi-square statistic
+// measures how far the counts are from that - 23 degrees of freedom, 49.73 is the p = 0.001 limit.
+// The old shuffle could never keep a card at the last position so it fails this badly.
+func TestShuffleIsUniform(t *testing.T) {
+	const runs = 240000
+	base := newDeck()[:4]
+	source := rand.NewSource(2024)
+	counts := map[string]int{}
+	for range runs {
+		d := make(deck, len(base))
+		copy(d, base)
+		d.shuffleWith(source)
+		counts[d.toString()]++
+	}
+
+	if len(counts) != 24 {
+		t.Fatalf("Expected all 24 orders of 4 cards, got %v", len(counts))
+	}
+
+	expected := float64(runs) / 24
+	chiSquare := 0.0
+	for _, observed := range counts {
+		diff := float64(observed) - expected
+		chiSquare += diff * diff / expected
+	}
+	if chiSquare > 49.73 {
+		t.Errorf("Shuffle looks biased: chi-square %.2f over 23 degrees of freedom", chiSquare)
+	}
+}
+
+// The benchmarks below compare the deck with the card mask ( see cardmask.go ) on the work of the
+// simulations: dealing random 7 card hands, scoring them and looking cards up.
+// Run them with go test -bench 'Deck|Mask' -benchmem.
+
+// benchmarkHands are 1000 random 7 card hands, the same ones for every benchmark.
+func benchmarkHands() []deck {
+	r := rand.New(rand.NewSource(1))
+	hands := make([]deck, 1000)
+	for i := range hands {
+		d := newDeck()
+		r.Shuffle(len(d), func(i, j int) { d[i], d[j] = d[j], d[i] })
+		hands[i] = d[:7]
+	}
+	return hands
+}
+
+func BenchmarkDealDeck(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	d := newDeck()
+	for range b.N {
+		// put 7 random cards on top and deal them, the hand is a copy the caller can keep
+		for i := range 7 {
+			j := i + r.Intn(len(d)-i)
+			d[i], d[j] = d[j], d[i]
+		}
+		top, _, _ := deal(d, 7)
+		hand := make(deck, len(top))
+		copy(hand, top)
+	}
+}
+
+func BenchmarkDealMask(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	for range b.N {
+		var hand cardMask
+		for hand.count() < 7 {
+			hand |= 1 << r.Intn(52)
+		}
+	}
+}
+
+func BenchmarkEvaluateDeck(b *testing.B) {
+	hands := benchmarkHands()
+	b.ResetTimer()
+	for i := range b.N {
+		bestFive(hands[i%len(hands)])
+	}
+}
+
+func BenchmarkEvaluateMask(b *testing.B) {
+	hands := benchmarkHands()
+	masks := make([]cardMask, len(hands))
+	for i, hand := range hands {
+		masks[i], _ = maskOf(hand)
+	}
+	b.ResetTimer()
+	for i := range b.N {
+		maskValue(masks[i%len(masks)])
+	}
+}
+
+// The membership test of the simulations: is a card of the deck already dealt?
+func BenchmarkContainsDeck(b *testing.B) {
+	hands := benchmarkHands()
+	card := Card{King, Clubs}
+	b.ResetTimer()
+	for i := range b.N {
+		for _, c := range hands[i%len(hands)] {
+			if c == card {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkContainsMask(b *testing.B) {
+	hands := benchmarkHands()
+	masks := make([]cardMask, len(hands))
+	for i, hand := range hands {
+		masks[i], _ = maskOf(hand)
+	}
+	card := Card{King, Clubs}
+	b.ResetTimer()
+	for i := range b.N {
+		masks[i%len(masks)].has(card)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// equityConfig describes a hold'em spot: the known hole cards of every player and the board so far.
+type equityConfig struct {
+	// Hands are the 2 hole cards of every player.
+	Hands []deck
+	// Board are the community cards already dealt ( 0, 3, 4 or 5 of them ).
+	Board deck
+	// Trials is the number of random boards to play out, 1,000,000 when 0.
+	Trials int
+	// Workers is the number of goroutines running the trials, one per CPU when 0.
+	Workers int
+	// Seed makes the simulation reproducible - the same seed and Workers give the same numbers.
+	Seed int64
+	// ExactLimit is the most boards that are enumerated instead of simulated, 50,000 when 0.
+	// Set it to -1 to always simulate.
+	ExactLimit int
+}
+
+const (
+	defaultEquityTrials     = 1000000
+	defaultEquityExactLimit = 50000
+)
+
+// playerEquity is the outcome of one player over all the boards.
+type playerEquity struct {
+	Wins   int
+	Ties   int
+	Losses int
+	// Equity is the share of the pot the player wins on average - a tie with 2 players counts as half a win.
+	Equity float64
+	// Margin is the half width of the 95% confidence interval of Equity ( 0 for an exact result ).
+	Margin float64
+}
+
+func (p playerEquity) total() int {
+	return p.Wins + p.Ties + p.Losses
+}
+
+func (p playerEquity) winRate() float64  { return float64(p.Wins) / float64(p.total()) }
+func (p playerEquity) tieRate() float64  { return float64(p.Ties) / float64(p.total()) }
+func (p playerEquity) lossRate() float64 { return float64(p.Losses) / float64(p.total()) }
+
+// equityResult is the equity of every player, in the order of equityConfig.Hands.
+type equityResult struct {
+	Players []playerEquity
+	// Boards is the number of boards played out.
+	Boards int
+	// Exact is true when every possible board was played instead of a random sample.
+	Exact bool
+}
+
+// equityTally adds up the results of many boards. Every worker has its own tally and they are merged at the end.
+type equityTally struct {
+	wins, ties, losses []int
+	// share and shareSquares are the sums of the pot share and its square, for the mean and the variance.
+	share, shareSquares []float64
+	boards              int
+}
+
+func newEquityTally(players int) *equityTally {
+	return &equityTally{
+		wins:         make([]int, players),
+		ties:         make([]int, players),
+		losses:       make([]int, players),
+		share:        make([]float64, players),
+		shareSquares: make([]float64, players),
+	}
+}
+
+// add scores one complete board for every player.
+func (t *equityTally) add(hands []deck, board deck, cards deck) {
+	best := handValue{}
+	winners := 0
+	values := make([]handValue, len(hands))
+	for player, hand := range hands {
+		cards = append(cards[:0], hand...)
+		cards = append(cards, board...)
+		values[player] = bestFive(cards)
+		c := values[player].compare(best)
+		switch {
+		case player == 0 || c > 0:
+			best = values[player]
+			winners = 1
+		case c == 0:
+			winners++
+		}
+	}
+
+	for player, v := range values {
+		switch {
+		case v.compare(best) < 0:
+			t.losses[player]++
+		case winners == 1:
+			t.wins[player]++
+			t.share[player]++
+			t.shareSquares[player]++
+		default:
+			share := 1 / float64(winners)
+			t.ties[player]++
+			t.share[player] += share
+			t.shareSquares[player] += share * share
+		}
+	}
+	t.boards++
+}
+
+func (t *equityTally) merge(o *equityTally) {
+	for player := range t.wins {
+		t.wins[player] += o.wins[player]
+		t.ties[player] += o.ties[player]
+		t.losses[player] += o.losses[player]
+		t.share[player] += o.share[player]
+		t.shareSquares[player] += o.shareSquares[player]
+	}
+	t.boards += o.boards
+}
+
+func (t *equityTally) result(exact bool) equityResult {
+	r := equityResult{Boards: t.boards, Exact: exact}
+	n := float64(t.boards)
+	for player := range t.wins {
+		p := playerEquity{Wins: t.wins[player], Ties: t.ties[player], Losses: t.losses[player]}
+		if t.boards > 0 {
+			p.Equity = t.share[player] / n
+		}
+		if !exact && t.boards > 1 {
+			// 1.96 standard errors of the mean cover 95% of a normal distribution
+			variance := (t.shareSquares[player] - n*p.Equity*p.Equity) / (n - 1)
+			p.Margin = 1.96 * math.Sqrt(math.Max(variance, 0)/n)
+		}
+		r.Players = append(r.Players, p)
+	}
+	return r
+}
+
+// calculateEquity plays out the rest of the board to find out how often every hand wins.
+// When the number of possible boards is at most ExactLimit all of them are played ( exact result ),
+// otherwise Trials random boards are played in parallel ( Monte Carlo ).
+func calculateEquity(cfg equityConfig) (equityResult, error) {
+	if len(cfg.Hands) < 2 {
+		return equityResult{}, fmt.Errorf("need at least 2 hands, got %d", len(cfg.Hands))
+	}
+	if len(cfg.Board) > 5 {
+		return equityResult{}, fmt.Errorf("the board has at most 5 cards, got %d", len(cfg.Board))
+	}
+
+	// take the known cards out of a new deck - what is left can still come on the board
+	known := map[Card]bool{}
+	for player, hand := range cfg.Hands {
+		if len(hand) != 2 {
+			return equityResult{}, fmt.Errorf("player %d: need 2 hole cards, got %d", player, len(hand))
+		}
+	}
+	for _, card := range append(flattenHands(cfg.Hands), cfg.Board...) {
+		if !card.valid() || card.isJoker() {
+			return equityResult{}, fmt.Errorf("%w: %v", ErrUnknownCard, card)
+		}
+		if known[card] {
+			return equityResult{}, fmt.Errorf("%w: %v", ErrDuplicateCard, card)
+		}
+		known[card] = true
+	}
+	remaining := deck{}
+	for _, card := range newDeck() {
+		if !known[card] {
+			remaining = append(remaining, card)
+		}
+	}
+
+	missing := 5 - len(cfg.Board)
+	if len(remaining) < missing {
+		// with an empty board that is more than 23 hands
+		return equityResult{}, fmt.Errorf("%d hands leave too few cards for the board: %w",
+			len(cfg.Hands), &InsufficientCardsError{Need: missing, Have: len(remaining)})
+	}
+	limit := cfg.ExactLimit
+	if limit == 0 {
+		limit = defaultEquityExactLimit
+	}
+	if combinations(len(remaining), missing) <= float64(limit) {
+		return enumerateEquity(cfg.Hands, cfg.Board, remaining, missing), nil
+	}
+	return simulateEquity(cfg, remaining, missing), nil
+}
+
+// enumerateEquity plays every possible way to complete the board.
+func enumerateEquity(hands []deck, board, remaining deck, missing int) equityResult {
+	tally := newEquityTally(len(hands))
+	full := append(board[:len(board):len(board)], make(deck, missing)...)
+	cards := make(deck, 0, 7)
+
+	// indexes holds the positions in remaining of the cards that complete the board, always increasing
+	indexes := make([]int, missing)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	for {
+		for i, idx := range indexes {
+			full[len(board)+i] = remaining[idx]
+		}
+		tally.add(hands, full, cards)
+
+		// move to the next combination: find the last index that can still grow
+		i := missing - 1
+		for i >= 0 && indexes[i] == len(remaining)-missing+i {
+			i--
+		}
+		if i < 0 {
+			break
+		}
+		indexes[i]++
+		for j := i + 1; j < missing; j++ {
+			indexes[j] = indexes[j-1] + 1
+		}
+	}
+	return tally.result(true)
+}
+
+// simulateEquity plays random boards on several goroutines.
+// Every worker gets its own random generator seeded from cfg.Seed, so the result doesn't depend on scheduling.
+func simulateEquity(cfg equityConfig, remaining deck, missing int) equityResult {
+	trials := cfg.Trials
+	if trials <= 0 {
+		trials = defaultEquityTrials
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = min(workers, trials)
+
+	tallies := make([]*equityTally, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		// split the trials as evenly as possible
+		n := trials / workers
+		if w < trials%workers {
+			n++
+		}
+		tallies[w] = newEquityTally(len(cfg.Hands))
+
+		wg.Add(1)
+		go func(tally *equityTally, seed int64, n int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			pool := append(deck{}, remaining...)
+			full := append(cfg.Board[:len(cfg.Board):len(cfg.Board)], make(deck, missing)...)
+			cards := make(deck, 0, 7)
+			for range n {
+				// a partial Fisher-Yates shuffle - only the first missing cards are needed
+				for i := range missing {
+					j := i + r.Intn(len(pool)-i)
+					pool[i], pool[j] = pool[j], pool[i]
+					full[len(cfg.Board)+i] = pool[i]
+				}
+				tally.add(cfg.Hands, full, cards)
+			}
+		}(tallies[w], cfg.Seed+int64(w), n)
+	}
+	wg.Wait()
+
+	total := newEquityTally(len(cfg.Hands))
+	for _, tally := range tallies {
+		total.merge(tally)
+	}
+	return total.result(false)
+}
+
+// combinations is n choose k as a float, so it can't overflow.
+func combinations(n, k int) float64 {
+	if k < 0 || k > n {
+		return 0
+	}
+	result := 1.0
+	for i := range k {
+		result = result * float64(n-i) / float64(i+1)
+	}
+	return math.Round(result)
+}
+
+// flattenHands puts the cards of all the hands in one deck.
+func flattenHands(hands []deck) deck {
+	all := deck{}
+	for _, hand := range hands {
+		all = append(all, hand...)
+	}
+	return all
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// With the whole board known there is only one board to play.
+func TestCalculateEquityRiver(t *testing.T) {
+	r, err := calculateEquity(equityConfig{
+		Hands: []deck{cardsFromCodes(t, "As Ad"), cardsFromCodes(t, "Kh Kd")},
+		Board: cardsFromCodes(t, "2c 7d 9h Js 3c"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Exact || r.Boards != 1 {
+		t.Errorf("Expected 1 exact board, got %v exact %v", r.Boards, r.Exact)
+	}
+	if r.Players[0].Equity != 1 || r.Players[1].Equity != 0 {
+		t.Errorf("Expected the aces to win, got %+v", r.Players)
+	}
+}
+
+// On the turn the kings need one of the 2 kings left out of 44 cards.
+func TestCalculateEquityTurnIsExact(t *testing.T) {
+	r, err := calculateEquity(equityConfig{
+		Hands: []deck{cardsFromCodes(t, "As Ad"), cardsFromCodes(t, "Kh Kd")},
+		Board: cardsFromCodes(t, "2c 7d 9h Js"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Exact || r.Boards != 44 {
+		t.Fatalf("Expected 44 exact boards, got %v exact %v", r.Boards, r.Exact)
+	}
+	if r.Players[1].Wins != 2 || r.Players[0].Wins != 42 {
+		t.Errorf("Expected 42 and 2 wins, got %+v", r.Players)
+	}
+}
+
+func TestCalculateEquitySplitPot(t *testing.T) {
+	r, err := calculateEquity(equityConfig{
+		Hands: []deck{cardsFromCodes(t, "2c 3d"), cardsFromCodes(t, "2d 3c")},
+		Board: cardsFromCodes(t, "Ts Js Qs Ks As"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range r.Players {
+		if p.Ties != 1 || p.Equity != 0.5 {
+			t.Errorf("Expected a split pot, got %+v", p)
+		}
+	}
+}
+
+// Aces against kings before the flop are about 82% - 18%.
+func TestCalculateEquityMonteCarlo(t *testing.T) {
+	cfg := equityConfig{
+		Hands:   []deck{cardsFromCodes(t, "As Ad"), cardsFromCodes(t, "Kh Kd")},
+		Trials:  40000,
+		Workers: 4,
+		Seed:    11,
+	}
+	r, err := calculateEquity(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Exact || r.Boards != cfg.Trials {
+		t.Fatalf("Expected %v simulated boards, got %v exact %v", cfg.Trials, r.Boards, r.Exact)
+	}
+
+	aces := r.Players[0]
+	if aces.Margin <= 0 || aces.Margin > 0.01 {
+		t.Errorf("Expected a margin under 1%%, got %v", aces.Margin)
+	}
+	if math.Abs(aces.Equity-0.8236) > 3*aces.Margin {
+		t.Errorf("Expected about 82.4%% for the aces, got %.4f ± %.4f", aces.Equity, aces.Margin)
+	}
+	if sum := r.Players[0].Equity + r.Players[1].Equity; math.Abs(sum-1) > 1e-9 {
+		t.Errorf("Expected the equities to add up to 1, got %v", sum)
+	}
+
+	// the same seed gives the same numbers
+	again, _ := calculateEquity(cfg)
+	if again.Players[0] != aces {
+		t.Errorf("Expected the same result for the same seed, got %+v and %+v", aces, again.Players[0])
+	}
+}
+
+func TestCalculateEquityErrors(t *testing.T) {
+	for _, cfg := range []equityConfig{
+		{Hands: []deck{cardsFromCodes(t, "As Ad")}},
+		{Hands: []deck{cardsFromCodes(t, "As Ad"), cardsFromCodes(t, "As Kd")}},
+		{Hands: []deck{cardsFromCodes(t, "As"), cardsFromCodes(t, "Kh Kd")}},
+		{Hands: []deck{cardsFromCodes(t, "As Ad"), cardsFromCodes(t, "Kh Kd")}, Board: cardsFromCodes(t, "2c 3c 4c 5c 6c 7c")},
+	} {
+		if _, err := calculateEquity(cfg); err == nil {
+			t.Errorf("Expected an error for %+v", cfg)
+		}
+	}
+}
+
+// 24 hands leave 4 cards for a board of 5 - an error, both when enumerating and when simulating.
+func TestCalculateEquityTooManyHands(t *testing.T) {
+	d := newDeck()
+	var hands []deck
+	for i := 0; i+2 <= 48; i += 2 {
+		hands = append(hands, d[i:i+2])
+	}
+	for _, limit := range []int{0, -1} {
+		_, err := calculateEquity(equityConfig{Hands: hands, ExactLimit: limit, Trials: 100})
+		var insufficient *InsufficientCardsError
+		if !errors.As(err, &insufficient) || insufficient.Need != 5 || insufficient.Have != 4 {
+			t.Errorf("ExactLimit %d: expected an InsufficientCardsError, got %v", limit, err)
+		}
+	}
+	if _, err := calculateEquity(equityConfig{Hands: hands[:23], Trials: 100}); err != nil {
+		t.Errorf("Expected 23 hands to work, got %v", err)
+	}
+}
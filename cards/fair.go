@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// A provably fair shuffle, for players who don't trust the dealer ( commit - reveal ):
+//
+//  1. commit: the dealer picks a secret random seed and publishes the SHA-256 of the card set
+//     and the seed, the commitment - so neither can be changed once the players joined. The
+//     hash covers every card of the set, a set file changed after the commit doesn't verify.
+//  2. the players each send some entropy - any text they like.
+//  3. the deck order comes from the secret seed and all the entropy together, so the dealer
+//     couldn't pick it ( the seed was fixed before the entropy was known ) and neither could
+//     the players ( they don't know the seed ).
+//  4. reveal: after the game the dealer publishes the seed. Anyone can check it against the
+//     commitment and replay the shuffle - see verifyFair and the "verify" command.
+//
+// The commit and the receipt carry the definition of the set, so whoever checks a receipt
+// needs nothing else - verifyFair never reads a set file named by a receipt.
+
+// fairSeedSize is the size of the dealer's secret seed in bytes.
+const fairSeedSize = 32
+
+var (
+	// ErrFairCommitment is returned when the revealed seed isn't the one committed to.
+	ErrFairCommitment = errors.New("the seed doesn't match the commitment")
+	// ErrFairOrder is returned when the deck isn't the one the seeds give.
+	ErrFairOrder = errors.New("the deck order doesn't match the seeds")
+)
+
+// fairCommit is the dealer's side before the reveal. Seed is secret until then.
+type fairCommit struct {
+	Seed       string   `json:"seed"`
+	Commitment string   `json:"commitment"`
+	Set        string   `json:"set"`
+	Definition *cardSet `json:"definition,omitempty"`
+}
+
+// fairReceipt is everything published after the game, what verifyFair checks.
+type fairReceipt struct {
+	Commitment string   `json:"commitment"`
+	Seed       string   `json:"seed"`
+	Entropy    []string `json:"entropy"`
+	Set        string   `json:"set"`
+	Definition *cardSet `json:"definition,omitempty"`
+	Cards      []string `json:"cards"`
+}
+
+// newFairCommit draws a secret seed from the secure generator for a deck of the set.
+// set is a registered name or a definition file of the dealer.
+func newFairCommit(set string) (fairCommit, error) {
+	cs, err := lookupCardSet(set)
+	if err != nil {
+		return fairCommit{}, err
+	}
+	seed := make([]byte, fairSeedSize)
+	crand.Read(seed)
+	return fairCommit{Seed: hex.EncodeToString(seed), Commitment: commitment(cs, seed), Set: cs.Name, Definition: cs.definition()}, nil
+}
+
+// fairCardSet is the set of a commit or a receipt: its definition, or a registered set for a
+// document without one. A name is never read as a file, the document may come from anyone.
+func fairCardSet(set string, def *cardSet) (*cardSet, error) {
+	if def == nil {
+		registry.RLock()
+		cs, ok := cardSets[set]
+		registry.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("%w %q without its definition", ErrUnknownCardSet, set)
+		}
+		return cs, nil
+	}
+	if def.Name != set {
+		return nil, fmt.Errorf("%w: the definition is for %q, not %q", ErrBadCardSet, def.Name, set)
+	}
+	return defineCardSet(def)
+}
+
+// commitment is the hex SHA-256 of the set name, a zero byte, every card of a full deck of the
+// set and the seed. The zero byte keeps the name apart from the rest, set names can't hold one,
+// and every card name is written with its length like the entropy in fairDeck.
+func commitment(cs *cardSet, seed []byte) string {
+	h := sha256.New()
+	h.Write([]byte(cs.Name))
+	h.Write([]byte{0})
+	for _, card := range cs.order {
+		name := card.String()
+		binary.Write(h, binary.BigEndian, uint32(len(name)))
+		h.Write([]byte(name))
+	}
+	h.Write(seed)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fairDeck is the deck of the set shuffled from the seed and the entropy, in that order.
+// Every piece of entropy is written with its length, so "ab"+"c" and "a"+"bc" are different.
+func fairDeck(seed []byte, entropy []string, cs *cardSet) deck {
+	h := sha256.New()
+	h.Write(seed)
+	for _, e := range entropy {
+		binary.Write(h, binary.BigEndian, uint32(len(e)))
+		h.Write([]byte(e))
+	}
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+
+	d := cs.newDeck()
+	d.shuffleWith(&hashSource{key: key})
+	return d
+}
+
+// reveal deals the deck of the commit with the players' entropy and returns the receipt to publish.
+func (c fairCommit) reveal(entropy []string) (fairReceipt, deck, error) {
+	seed, err := hex.DecodeString(c.Seed)
+	if err != nil {
+		return fairReceipt{}, nil, fmt.Errorf("bad seed: %w", err)
+	}
+	cs, err := fairCardSet(c.Set, c.Definition)
+	if err != nil {
+		return fairReceipt{}, nil, err
+	}
+	d := fairDeck(seed, entropy, cs)
+	r := fairReceipt{Commitment: c.Commitment, Seed: c.Seed, Entropy: entropy, Set: c.Set, Definition: c.Definition, Cards: make([]string, len(d))}
+	for i, card := range d {
+		r.Cards[i] = card.String()
+	}
+	return r, d, nil
+}
+
+// verifyFair checks the receipt: the set and the seed have to match the commitment and replaying
+// the shuffle from the seed and the entropy has to give the same cards in the same order.
+func verifyFair(r fairReceipt) error {
+	cs, err := fairCardSet(r.Set, r.Definition)
+	if err != nil {
+		return err
+	}
+	seed, err := hex.DecodeString(r.Seed)
+	if err != nil {
+		return fmt.Errorf("%w: the seed isn't hex", ErrFairCommitment)
+	}
+	if commitment(cs, seed) != r.Commitment {
+		return ErrFairCommitment
+	}
+	d := fairDeck(seed, r.Entropy, cs)
+	if len(d) != len(r.Cards) {
+		return fmt.Errorf("%w: expected %d cards, the receipt has %d", ErrFairOrder, len(d), len(r.Cards))
+	}
+	for i, card := range d {
+		if card.String() != r.Cards[i] {
+			return fmt.Errorf("%w: position %d should be %v, the receipt has %s", ErrFairOrder, i, card, r.Cards[i])
+		}
+	}
+	return nil
+}
+
+// hashSource is a rand.Source that reads SHA-256 of the key and a counter, block after block.
+// Unlike a seeded math/rand source it keeps all 256 bits of the key, which is more than the
+// 226 bits needed to reach every order of 52 cards, and anyone can rebuild it from the key.
+type hashSource struct {
+	key     [sha256.Size]byte
+	counter uint64
+	block   []byte
+}
+
+func (s *hashSource) Uint64() uint64 {
+	if len(s.block) < 8 {
+		var buf [sha256.Size + 8]byte
+		copy(buf[:], s.key[:])
+		binary.BigEndian.PutUint64(buf[sha256.Size:], s.counter)
+		s.counter++
+		sum := sha256.Sum256(buf[:])
+		s.block = sum[:]
+	}
+	v := binary.BigEndian.Uint64(s.block)
+	s.block = s.block[8:]
+	return v
+}
+
+func (s *hashSource) Int63() int64 {
+	return int64(s.Uint64() & (1<<63 - 1))
+}
+
+// Seed does nothing, the source is fixed by its key.
+func (s *hashSource) Seed(int64) {}
+
+// runCommit is the "cards commit" command: pick the secret seed and show the commitment.
+func runCommit(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "commit")
+	out := fs.String("out", "commit.json", "file that keeps the secret seed until the reveal")
+	set := fs.String("set", "standard", "card set of the deck")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	c, err := newFairCommit(*set)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+	if err := writeJSONFile(*out, c, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stdout, "Commitment: %s\n", c.Commitment)
+	fmt.Fprintf(env.stdout, "Publish it before the players send their entropy. The seed is in %s, keep it secret.\n", *out)
+	return nil
+}
+
+// runReveal is the "cards reveal" command: shuffle with the players' entropy and write the receipt.
+func runReveal(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "reveal")
+	in := fs.String("in", "commit.json", "the file written by commit")
+	out := fs.String("out", "", "write the receipt to this file instead of stdout")
+	var entropy stringsFlag
+	fs.Var(&entropy, "entropy", "entropy of a player, repeat the flag for every player")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if len(entropy) == 0 {
+		return usagef("at least one -entropy is needed, or the dealer alone picks the order")
+	}
+
+	var c fairCommit
+	bs, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(bs, &c); err != nil {
+		return fmt.Errorf("reading %s: %w", *in, err)
+	}
+	r, _, err := c.reveal(entropy)
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		return writeJSONFile(*out, r, 0666)
+	}
+	enc := json.NewEncoder(env.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// runVerify is the "cards verify" command: anyone can check a receipt.
+func runVerify(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "verify")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected the receipt to verify, e.g. cards verify receipt.json")
+	}
+	bs, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var r fairReceipt
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		return fmt.Errorf("reading %s: %w", fs.Arg(0), err)
+	}
+	if err := verifyFair(r); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stdout, "OK: the seed matches commitment %s and the %d cards are in the order it gives\n", r.Commitment, len(r.Cards))
+	return nil
+}
+
+func writeJSONFile(filename string, v any, perm os.FileMode) error {
+	bs, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(bs, '\n'), perm)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFairShuffle(t *testing.T) {
+	c, err := newFairCommit("standard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Commitment) != 64 || c.Commitment == c.Seed {
+		t.Fatalf("Expected a hex SHA-256 commitment, got %q", c.Commitment)
+	}
+
+	r, d, err := c.reveal([]string{"alice", "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameCards(d, newDeck()) {
+		t.Errorf("Expected all 52 cards, got %v", d.toString())
+	}
+	if err := verifyFair(r); err != nil {
+		t.Errorf("Expected the receipt to verify, got %v", err)
+	}
+
+	// the same seeds always give the same order, any change of entropy another one
+	again, _, _ := c.reveal([]string{"alice", "bob"})
+	if strings.Join(again.Cards, ",") != strings.Join(r.Cards, ",") {
+		t.Errorf("Expected the same order from the same seeds")
+	}
+	for _, entropy := range [][]string{{"bob", "alice"}, {"alic", "ebob"}, {"alice", "bob", ""}} {
+		other, _, _ := c.reveal(entropy)
+		if strings.Join(other.Cards, ",") == strings.Join(r.Cards, ",") {
+			t.Errorf("Expected entropy %q to give another order", entropy)
+		}
+	}
+}
+
+func TestVerifyFairCatchesCheats(t *testing.T) {
+	c, _ := newFairCommit("standard")
+	r, _, err := c.reveal([]string{"carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, _ := newFairCommit("standard")
+	jokers, _ := lookupCardSet("jokers")
+	shortDeck := jokers.definition()
+	shortDeck.Name, shortDeck.Specials, shortDeck.Ranks = "standard", nil, shortDeck.Ranks[1:]
+	tests := []struct {
+		name   string
+		change func(r *fairReceipt)
+		err    error
+	}{
+		{"other seed", func(r *fairReceipt) { r.Seed = other.Seed }, ErrFairCommitment},
+		{"bad seed", func(r *fairReceipt) { r.Seed = "zz" }, ErrFairCommitment},
+		{"dropped entropy", func(r *fairReceipt) { r.Entropy = nil }, ErrFairOrder},
+		{"swapped cards", func(r *fairReceipt) { r.Cards[0], r.Cards[5] = r.Cards[5], r.Cards[0] }, ErrFairOrder},
+		{"missing card", func(r *fairReceipt) { r.Cards = r.Cards[1:] }, ErrFairOrder},
+		{"unknown set", func(r *fairReceipt) { r.Set, r.Definition = "canasta", nil }, ErrUnknownCardSet},
+		{"set file", func(r *fairReceipt) { r.Set, r.Definition = "carol.json", nil }, ErrUnknownCardSet},
+		{"other definition", func(r *fairReceipt) { r.Definition = jokers.definition() }, ErrBadCardSet},
+		{"changed definition", func(r *fairReceipt) { r.Definition = shortDeck }, ErrBadCardSet},
+	}
+	for _, tt := range tests {
+		cheat := r
+		cheat.Cards = append([]string{}, r.Cards...)
+		tt.change(&cheat)
+		if err := verifyFair(cheat); !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+// A dealer that commits to one set can't deal another one, even with a receipt that adds up.
+func TestVerifyFairCatchesSwappedSet(t *testing.T) {
+	c, _ := newFairCommit("standard")
+	jokers, _ := lookupCardSet("jokers")
+	c.Set, c.Definition = "jokers", jokers.definition()
+	r, d, err := c.reveal([]string{"dave"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d) != 54 {
+		t.Fatalf("Expected the jokers deck, got %d cards", len(d))
+	}
+	if err := verifyFair(r); !errors.Is(err, ErrFairCommitment) {
+		t.Errorf("Expected %v for a swapped set, got %v", ErrFairCommitment, err)
+	}
+}
+
+// A set file is committed to card by card, and the receipt carries the definition.
+func TestFairCustomSet(t *testing.T) {
+	def := filepath.Join(t.TempDir(), "fair.json")
+	os.WriteFile(def, []byte(`{"name": "fair-test", "suits": ["Spades", "Hearts"], "ranks": ["Ace", "King"], "copies": 2}`), 0666)
+	c, err := newFairCommit(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, d, err := c.reveal([]string{"erin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d) != 8 || r.Set != "fair-test" || r.Definition == nil {
+		t.Fatalf("Expected 8 cards of the set and its definition in the receipt, got %v cards of %+v", len(d), r)
+	}
+	// the definition file isn't needed any more - and changing it changes nothing
+	os.WriteFile(def, []byte(`{"name": "fair-test", "suits": ["Spades"], "ranks": ["Ace"]}`), 0666)
+	if err := verifyFair(r); err != nil {
+		t.Errorf("Expected the receipt to verify, got %v", err)
+	}
+
+	// the same name with other cards gives another commitment
+	seed, _ := hex.DecodeString(c.Seed)
+	cs, _ := lookupCardSet("fair-test")
+	changed := &cardSet{Name: cs.Name, order: cs.order[:6]}
+	if commitment(changed, seed) == c.Commitment {
+		t.Errorf("Expected the commitment to cover the cards of the set")
+	}
+}
+
+func TestHashSource(t *testing.T) {
+	a, b := &hashSource{key: [32]byte{1}}, &hashSource{key: [32]byte{1}}
+	seen := map[uint64]bool{}
+	for range 100 {
+		v := a.Uint64()
+		if v != b.Uint64() {
+			t.Fatalf("Expected the same key to give the same numbers")
+		}
+		seen[v] = true
+	}
+	if len(seen) != 100 {
+		t.Errorf("Expected 100 different numbers, got %v", len(seen))
+	}
+	if a.Int63() < 0 {
+		t.Errorf("Expected Int63 to be positive")
+	}
+}
+
+func TestCommitRevealVerifyCommands(t *testing.T) {
+	dir := t.TempDir()
+	commitFile := filepath.Join(dir, "commit.json")
+	receipt := filepath.Join(dir, "receipt.json")
+
+	code, stdout, stderr := runCLI(t, "", "commit", "-out", commitFile)
+	if code != exitOK || !strings.HasPrefix(stdout, "Commitment: ") {
+		t.Fatalf("Expected the commitment, got %v ( %s%s )", code, stdout, stderr)
+	}
+	published := strings.TrimSpace(strings.TrimPrefix(strings.SplitN(stdout, "\n", 2)[0], "Commitment:"))
+
+	if code, _, _ := runCLI(t, "", "reveal", "-in", commitFile); code != exitUsage {
+		t.Errorf("Expected a usage error without entropy, got %v", code)
+	}
+	if code, _, stderr := runCLI(t, "", "reveal", "-in", commitFile, "-entropy", "dave", "-entropy", "erin", "-out", receipt); code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+	var r fairReceipt
+	bs, _ := os.ReadFile(receipt)
+	if err := json.Unmarshal(bs, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Commitment != published || len(r.Entropy) != 2 {
+		t.Errorf("Expected the published commitment and 2 entropies in the receipt, got %+v", r)
+	}
+
+	code, stdout, _ = runCLI(t, "", "verify", receipt)
+	if code != exitOK || !strings.HasPrefix(stdout, "OK") {
+		t.Errorf("Expected the receipt to verify, got %v ( %s )", code, stdout)
+	}
+
+	r.Cards[0], r.Cards[1] = r.Cards[1], r.Cards[0]
+	writeJSONFile(receipt, r, 0666)
+	if code, _, stderr := runCLI(t, "", "verify", receipt); code != exitError || !strings.Contains(stderr, "order") {
+		t.Errorf("Expected a changed order to fail, got %v ( %s )", code, stderr)
+	}
+
+	// a set file, looked up by commit, reveal and verify in the same process
+	def := filepath.Join(dir, "euchre.json")
+	os.WriteFile(def, []byte(`{"name": "fair-euchre", "suits": ["Spades", "Diamonds", "Hearts", "Clubs"], "ranks": ["Nine", "Ten", "Jack", "Queen", "King", "Ace"]}`), 0666)
+	if code, _, stderr := runCLI(t, "", "commit", "-set", def, "-out", commitFile); code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+	if code, _, stderr := runCLI(t, "", "reveal", "-in", commitFile, "-entropy", "frank", "-out", receipt); code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+	if code, stdout, stderr := runCLI(t, "", "verify", receipt); code != exitOK || !strings.Contains(stdout, "24 cards") {
+		t.Errorf("Expected the euchre receipt to verify, got %v ( %s%s )", code, stdout, stderr)
+	}
+}
@@ -0,0 +1,560 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+)
+
+// The game log records everything that happens to a deck, one JSON object per line ( JSON Lines ):
+//
+//	{"seq":0,"type":"new","set":"standard"}
+//	{"seq":1,"type":"shuffle","seed":42}
+//	{"seq":2,"type":"deal","players":2,"count":5,"cards":["Ace of Spades", ...]}
+//	{"seq":3,"type":"bet","player":1,"amount":10}
+//
+// The log is only ever appended to. A replay applies the events from the start and can stop
+// at any index, so the state of the game can be looked at after every single event.
+// The cards taken from the deck are written too, the replay checks them against the deck it
+// rebuilds - a log that doesn't match its own seed is caught at the first wrong card.
+
+// The event types of the game log.
+const (
+	eventNew     = "new"
+	eventShuffle = "shuffle"
+	eventDeal    = "deal"
+	eventDraw    = "draw"
+	eventBurn    = "burn"
+	eventDiscard = "discard"
+	eventBet     = "bet"
+)
+
+// ErrBadGameLog is returned for a log that can't be replayed.
+var ErrBadGameLog = errors.New("bad game log")
+
+// gameEvent is one line of the log. Which fields are set depends on the type:
+//
+//	new      Set, or Cards for a deck in a given order
+//	shuffle  Seed
+//	deal     Players, Count ( cards each ), Blocks, and the Cards that were dealt
+//	draw     Player, Count and the Cards drawn
+//	burn     Count and the Cards burned ( to the discards )
+//	discard  Player and the Cards put on the discards
+//	bet      Player and Amount
+type gameEvent struct {
+	Seq     int     `json:"seq"`
+	Type    string  `json:"type"`
+	Set     string  `json:"set,omitempty"`
+	Seed    *int64  `json:"seed,omitempty"`
+	Player  int     `json:"player,omitempty"`
+	Players int     `json:"players,omitempty"`
+	Count   int     `json:"count,omitempty"`
+	Blocks  bool    `json:"blocks,omitempty"`
+	Amount  float64 `json:"amount,omitempty"`
+	Cards   deck    `json:"cards,omitempty"`
+}
+
+// takesCards reports whether the event takes its cards from the top of the deck.
+func (e gameEvent) takesCards() bool {
+	return e.Type == eventDeal || e.Type == eventDraw || e.Type == eventBurn
+}
+
+// gameState is everything the log knows about the game at one point.
+type gameState struct {
+	Deck     deck      `json:"deck"`
+	Hands    []deck    `json:"hands"`
+	Discards deck      `json:"discards"`
+	Bets     []float64 `json:"bets"`
+	Pot      float64   `json:"pot"`
+}
+
+// player makes room for the player's hand and bets and returns the hand.
+func (s *gameState) player(p int) (*deck, error) {
+	if p < 0 {
+		return nil, fmt.Errorf("bad player %d", p)
+	}
+	for len(s.Hands) <= p {
+		s.Hands = append(s.Hands, deck{})
+		s.Bets = append(s.Bets, 0)
+	}
+	return &s.Hands[p], nil
+}
+
+// apply changes the state by one event and returns the cards it took from the deck.
+func (s *gameState) apply(e gameEvent) (deck, error) {
+	switch e.Type {
+	case eventNew:
+		start := slices.Clone(e.Cards)
+		if e.Set != "" {
+			if len(e.Cards) > 0 {
+				return nil, errors.New("a new deck has a set or cards, not both")
+			}
+			cs, err := lookupCardSet(e.Set)
+			if err != nil {
+				return nil, err
+			}
+			start = cs.newDeck()
+		}
+		*s = gameState{Deck: start}
+		return nil, nil
+
+	case eventShuffle:
+		if e.Seed == nil {
+			return nil, errors.New("a shuffle needs its seed, or it can't be replayed")
+		}
+		s.Deck.shuffleSeed(*e.Seed)
+		return nil, nil
+
+	case eventDeal:
+		strategy := dealRoundRobin
+		if e.Blocks {
+			strategy = dealBlocks
+		}
+		dl := newDealer(s.Deck)
+		dealt, err := dl.dealHands(e.Players, e.Count, strategy)
+		if err != nil {
+			return nil, err
+		}
+		taken := slices.Clone(s.Deck[:e.Players*e.Count])
+		s.Deck = dl.cards
+		for p, cards := range dealt {
+			hand, _ := s.player(p)
+			*hand = append(*hand, cards...)
+		}
+		return taken, nil
+
+	case eventDraw, eventBurn:
+		if e.Count < 1 {
+			return nil, fmt.Errorf("can't %s %d cards", e.Type, e.Count)
+		}
+		if e.Type == eventDraw && e.Player < 0 {
+			return nil, fmt.Errorf("bad player %d", e.Player)
+		}
+		taken, rest, err := deal(s.Deck, e.Count)
+		if err != nil {
+			return nil, err
+		}
+		taken, s.Deck = slices.Clone(taken), rest
+		if e.Type == eventBurn {
+			s.Discards = append(s.Discards, taken...)
+			return taken, nil
+		}
+		hand, _ := s.player(e.Player)
+		*hand = append(*hand, taken...)
+		return taken, nil
+
+	case eventDiscard:
+		if e.Player < 0 || e.Player >= len(s.Hands) {
+			return nil, fmt.Errorf("player %d has no cards", e.Player)
+		}
+		hand := slices.Clone(s.Hands[e.Player])
+		for _, card := range e.Cards {
+			i := slices.Index(hand, card)
+			if i < 0 {
+				return nil, fmt.Errorf("%w: %v is not in the hand of player %d", ErrCardNotInPile, card, e.Player)
+			}
+			hand = slices.Delete(hand, i, i+1)
+		}
+		s.Hands[e.Player] = hand
+		s.Discards = append(s.Discards, e.Cards...)
+		return nil, nil
+
+	case eventBet:
+		if e.Amount <= 0 {
+			return nil, fmt.Errorf("can't bet %v", e.Amount)
+		}
+		if _, err := s.player(e.Player); err != nil {
+			return nil, err
+		}
+		s.Bets[e.Player] += e.Amount
+		s.Pot += e.Amount
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown event type %q", e.Type)
+}
+
+// clone copies the state, so changing the copy leaves the state as it was.
+func (s gameState) clone() gameState {
+	c := gameState{Deck: slices.Clone(s.Deck), Discards: slices.Clone(s.Discards), Bets: slices.Clone(s.Bets), Pot: s.Pot}
+	for _, hand := range s.Hands {
+		c.Hands = append(c.Hands, slices.Clone(hand))
+	}
+	return c
+}
+
+// print shows the state with the compact card codes, e.g. "Player 1: AS KD".
+func (s gameState) print(w io.Writer) {
+	top := s.Deck[:min(5, len(s.Deck))]
+	fmt.Fprintf(w, "Deck: %d cards", len(s.Deck))
+	if len(top) > 0 {
+		fmt.Fprintf(w, ", top %s", formatCompact(top))
+	}
+	fmt.Fprintln(w)
+	for p, hand := range s.Hands {
+		fmt.Fprintf(w, "Player %d: %s", p+1, formatCompact(hand))
+		if s.Bets[p] > 0 {
+			fmt.Fprintf(w, " ( bet %v )", s.Bets[p])
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Discards: %s\n", formatCompact(s.Discards))
+	fmt.Fprintf(w, "Pot: %v\n", s.Pot)
+}
+
+// gameReplay applies the events of a log one at a time.
+type gameReplay struct {
+	events []gameEvent
+	state  gameState
+	// applied is the number of events applied so far.
+	applied int
+}
+
+func newGameReplay(events []gameEvent) *gameReplay {
+	return &gameReplay{events: events}
+}
+
+func (r *gameReplay) done() bool {
+	return r.applied == len(r.events)
+}
+
+// step applies the next event and checks it against the state it was recorded from.
+func (r *gameReplay) step() error {
+	i := r.applied
+	e := r.events[i]
+	if e.Seq != i {
+		return fmt.Errorf("%w: event %d has seq %d", ErrBadGameLog, i, e.Seq)
+	}
+	taken, err := r.state.apply(e)
+	if err != nil {
+		return fmt.Errorf("%w: event %d ( %s ): %w", ErrBadGameLog, i, e.Type, err)
+	}
+	if e.takesCards() && !slices.Equal(taken, e.Cards) {
+		return fmt.Errorf("%w: event %d ( %s ): the log says %s but the deck gives %s",
+			ErrBadGameLog, i, e.Type, formatCompact(e.Cards), formatCompact(taken))
+	}
+	r.applied++
+	return nil
+}
+
+// replayGame rebuilds the state after the first n events of the log.
+func replayGame(events []gameEvent, n int) (gameState, error) {
+	if n < 0 || n > len(events) {
+		return gameState{}, fmt.Errorf("the log has %d events, can't stop after %d", len(events), n)
+	}
+	r := newGameReplay(events[:n])
+	for !r.done() {
+		if err := r.step(); err != nil {
+			return gameState{}, err
+		}
+	}
+	return r.state, nil
+}
+
+// gameRecorder plays the game and appends every event to the log as it happens.
+type gameRecorder struct {
+	enc   *json.Encoder
+	state gameState
+	seq   int
+}
+
+func newGameRecorder(w io.Writer) *gameRecorder {
+	return &gameRecorder{enc: json.NewEncoder(w)}
+}
+
+// resumeGameRecorder goes on with a log that already has events: they are replayed first
+// and the new events are numbered after them.
+func resumeGameRecorder(w io.Writer, events []gameEvent) (*gameRecorder, error) {
+	state, err := replayGame(events, len(events))
+	if err != nil {
+		return nil, err
+	}
+	return &gameRecorder{enc: json.NewEncoder(w), state: state, seq: len(events)}, nil
+}
+
+// record applies the event and writes it to the log. Nothing is written when the event fails.
+// The event is applied to a copy of the state, which replaces the state once the event is written,
+// so the state always matches the log.
+func (g *gameRecorder) record(e gameEvent) (deck, error) {
+	e.Seq = g.seq
+	state := g.state.clone()
+	taken, err := state.apply(e)
+	if err != nil {
+		return nil, err
+	}
+	if e.takesCards() {
+		e.Cards = taken
+	}
+	if err := g.enc.Encode(e); err != nil {
+		return nil, err
+	}
+	g.state = state
+	g.seq++
+	return taken, nil
+}
+
+func (g *gameRecorder) newGame(set string) error {
+	_, err := g.record(gameEvent{Type: eventNew, Set: set})
+	return err
+}
+
+func (g *gameRecorder) shuffle(seed int64) error {
+	_, err := g.record(gameEvent{Type: eventShuffle, Seed: &seed})
+	return err
+}
+
+func (g *gameRecorder) deal(players, count int, strategy dealStrategy) (deck, error) {
+	return g.record(gameEvent{Type: eventDeal, Players: players, Count: count, Blocks: strategy == dealBlocks})
+}
+
+func (g *gameRecorder) draw(player, count int) (deck, error) {
+	return g.record(gameEvent{Type: eventDraw, Player: player, Count: count})
+}
+
+func (g *gameRecorder) burn(count int) (deck, error) {
+	return g.record(gameEvent{Type: eventBurn, Count: count})
+}
+
+func (g *gameRecorder) discard(player int, cards ...Card) error {
+	_, err := g.record(gameEvent{Type: eventDiscard, Player: player, Cards: cards})
+	return err
+}
+
+func (g *gameRecorder) bet(player int, amount float64) error {
+	_, err := g.record(gameEvent{Type: eventBet, Player: player, Amount: amount})
+	return err
+}
+
+// readGameLog reads the events of a log. Empty lines are skipped, anything else that isn't
+// an event is an error with its line number.
+func readGameLog(r io.Reader) ([]gameEvent, error) {
+	var events []gameEvent
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var e gameEvent
+		dec := json.NewDecoder(bytes.NewReader(sc.Bytes()))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&e); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrBadGameLog, line, err)
+		}
+		events = append(events, e)
+	}
+	return events, sc.Err()
+}
+
+func loadGameLog(filename string) ([]gameEvent, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readGameLog(f)
+}
+
+// appendGameLog replays the log in the file ( a missing file is an empty log ) and calls play
+// with a recorder that appends to it.
+func appendGameLog(filename string, play func(g *gameRecorder) error) error {
+	events, err := loadGameLog(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	g, err := resumeGameRecorder(f, events)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	if err := play(g); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// gameDiff is where two replays part ways.
+type gameDiff struct {
+	// After is the number of events after which the states first differ, -1 when they never do.
+	After int
+	// Changes describe the differences at that point, e.g. "player 1: AS KD vs AS QD".
+	Changes []string
+}
+
+// diffGames replays both logs side by side and stops at the first event after which the
+// states differ. When one log is longer its extra events are compared to the last state of the other.
+func diffGames(a, b []gameEvent) (gameDiff, error) {
+	ra, rb := newGameReplay(a), newGameReplay(b)
+	for after := 0; ; after++ {
+		if changes := diffGameStates(ra.state, rb.state); len(changes) > 0 {
+			return gameDiff{After: after, Changes: changes}, nil
+		}
+		if ra.done() && rb.done() {
+			return gameDiff{After: -1}, nil
+		}
+		for _, r := range []*gameReplay{ra, rb} {
+			if !r.done() {
+				if err := r.step(); err != nil {
+					return gameDiff{}, err
+				}
+			}
+		}
+	}
+}
+
+// diffGameStates lists what is different between two states, nothing when they are the same.
+func diffGameStates(a, b gameState) []string {
+	var changes []string
+	if !slices.Equal(a.Deck, b.Deck) {
+		i := 0
+		for i < min(len(a.Deck), len(b.Deck)) && a.Deck[i] == b.Deck[i] {
+			i++
+		}
+		changes = append(changes, fmt.Sprintf("deck: %d vs %d cards, first difference at position %d: %s vs %s",
+			len(a.Deck), len(b.Deck), i, cardCodeAt(a.Deck, i), cardCodeAt(b.Deck, i)))
+	}
+	for p := range max(len(a.Hands), len(b.Hands)) {
+		ha, hb := handAt(a.Hands, p), handAt(b.Hands, p)
+		if !slices.Equal(ha, hb) {
+			changes = append(changes, fmt.Sprintf("player %d: %s vs %s", p+1, formatCompact(ha), formatCompact(hb)))
+		}
+		if ba, bb := betAt(a.Bets, p), betAt(b.Bets, p); ba != bb {
+			changes = append(changes, fmt.Sprintf("player %d bet: %v vs %v", p+1, ba, bb))
+		}
+	}
+	if !slices.Equal(a.Discards, b.Discards) {
+		changes = append(changes, fmt.Sprintf("discards: %s vs %s", formatCompact(a.Discards), formatCompact(b.Discards)))
+	}
+	if a.Pot != b.Pot {
+		changes = append(changes, fmt.Sprintf("pot: %v vs %v", a.Pot, b.Pot))
+	}
+	return changes
+}
+
+func cardCodeAt(d deck, i int) string {
+	if i >= len(d) {
+		return "( none )"
+	}
+	return d[i].code()
+}
+
+func handAt(hands []deck, p int) deck {
+	if p >= len(hands) {
+		return nil
+	}
+	return hands[p]
+}
+
+func betAt(bets []float64, p int) float64 {
+	if p >= len(bets) {
+		return 0
+	}
+	return bets[p]
+}
+
+// runReplay is the "cards replay" command: the state of a game after any event of its log.
+func runReplay(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "replay")
+	at := fs.Int("at", -1, "stop after this many events, all of them when not set")
+	events := fs.Bool("events", false, "list the events that were replayed")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected the log to replay, e.g. cards replay game.jsonl")
+	}
+	log, err := loadGameLog(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	n := *at
+	if n < 0 {
+		n = len(log)
+	}
+	if n > len(log) {
+		return usagef("the log has %d events, can't stop after %d", len(log), n)
+	}
+	state, err := replayGame(log, n)
+	if err != nil {
+		return err
+	}
+	if *events {
+		for _, e := range log[:n] {
+			fmt.Fprintln(env.stdout, e.describe())
+		}
+		fmt.Fprintln(env.stdout)
+	}
+	fmt.Fprintf(env.stdout, "After %d of %d events:\n", n, len(log))
+	state.print(env.stdout)
+	return nil
+}
+
+// describe is one line about the event for people, e.g. "3 draw: player 2 draws KD".
+func (e gameEvent) describe() string {
+	var what string
+	switch e.Type {
+	case eventNew:
+		what = fmt.Sprintf("a new deck of %d cards", len(e.Cards))
+		if e.Set != "" {
+			what = "a new " + e.Set + " deck"
+		}
+	case eventShuffle:
+		if e.Seed != nil {
+			what = fmt.Sprintf("shuffled with seed %d", *e.Seed)
+		}
+	case eventDeal:
+		what = fmt.Sprintf("%d cards each to %d players: %s", e.Count, e.Players, formatCompact(e.Cards))
+	case eventDraw:
+		what = fmt.Sprintf("player %d draws %s", e.Player+1, formatCompact(e.Cards))
+	case eventBurn:
+		what = "burned " + formatCompact(e.Cards)
+	case eventDiscard:
+		what = fmt.Sprintf("player %d discards %s", e.Player+1, formatCompact(e.Cards))
+	case eventBet:
+		what = fmt.Sprintf("player %d bets %v", e.Player+1, e.Amount)
+	}
+	return fmt.Sprintf("%d %s: %s", e.Seq, e.Type, what)
+}
+
+// runDiff is the "cards diff" command: where two game logs stop giving the same game.
+func runDiff(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "diff")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usagef("expected two logs, e.g. cards diff good.jsonl bad.jsonl")
+	}
+	a, err := loadGameLog(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := loadGameLog(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	d, err := diffGames(a, b)
+	if err != nil {
+		return err
+	}
+	if d.After < 0 {
+		fmt.Fprintf(env.stdout, "The games are the same ( %d and %d events )\n", len(a), len(b))
+		return nil
+	}
+	fmt.Fprintf(env.stdout, "The games differ after %d events:\n", d.After)
+	for _, c := range d.Changes {
+		fmt.Fprintf(env.stdout, "  %s\n", c)
+	}
+	return errors.New("the games differ")
+}
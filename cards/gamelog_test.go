@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with the file in testdata, or rewrites the file with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v ( run go test -update to write it )", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s changed, got\n%s\nwant\n%s", path, got, want)
+	}
+}
+
+// recordGame plays a small game of draw poker into a log.
+func recordGame(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	g := newGameRecorder(&buf)
+	steps := []func() error{
+		func() error { return g.newGame("standard") },
+		func() error { return g.shuffle(7) },
+		func() error { _, err := g.deal(3, 5, dealRoundRobin); return err },
+		func() error { return g.bet(0, 10) },
+		func() error { return g.bet(1, 10) },
+		func() error { return g.bet(2, 25) },
+		func() error { return g.discard(2, g.state.Hands[2][0], g.state.Hands[2][3]) },
+		func() error { _, err := g.draw(2, 2); return err },
+		func() error { _, err := g.burn(1); return err },
+		func() error { return g.bet(0, 15) },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestGameLogGolden(t *testing.T) {
+	golden(t, "game.jsonl", recordGame(t))
+
+	code, stdout, stderr := runCLI(t, "", "replay", "-events", filepath.Join("testdata", "game.jsonl"))
+	if code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+	golden(t, "game_replay.txt", []byte(stdout))
+}
+
+func TestReplayGameAtEveryEvent(t *testing.T) {
+	events, err := readGameLog(bytes.NewReader(recordGame(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 10 {
+		t.Fatalf("Expected 10 events, got %v", len(events))
+	}
+	bets := []float64{0, 0, 0, 0, 10, 20, 45, 45, 45, 45, 60}
+	for n := range len(events) + 1 {
+		s, err := replayGame(events, n)
+		if err != nil {
+			t.Fatalf("after %d: %v", n, err)
+		}
+		cards := len(s.Deck) + len(s.Discards)
+		for _, hand := range s.Hands {
+			cards += len(hand)
+		}
+		if n > 0 && cards != 52 {
+			t.Errorf("after %d: expected 52 cards, got %v", n, cards)
+		}
+		if s.Pot != bets[n] {
+			t.Errorf("after %d: expected a pot of %v, got %v", n, bets[n], s.Pot)
+		}
+	}
+
+	s, _ := replayGame(events, 8)
+	if len(s.Hands) != 3 || len(s.Hands[2]) != 5 || len(s.Discards) != 2 {
+		t.Errorf("Expected player 3 to have drawn back to 5 cards, got %+v", s)
+	}
+	if _, err := replayGame(events, 11); err == nil {
+		t.Errorf("Expected an error replaying past the end")
+	}
+}
+
+func TestReplayCatchesBadLogs(t *testing.T) {
+	good := string(recordGame(t))
+	tests := []struct {
+		name string
+		log  string
+	}{
+		{"changed card", strings.Replace(good, `"cards":["`, `"cards":["Ace of Spades","`, 1)},
+		{"changed seed", strings.Replace(good, `"seed":7`, `"seed":8`, 1)},
+		{"missing event", strings.Replace(good, `{"seq":1,"type":"shuffle","seed":7}`+"\n", "", 1)},
+		{"unknown type", strings.Replace(good, `"type":"burn"`, `"type":"steal"`, 1)},
+		{"unknown field", strings.Replace(good, `"type":"bet"`, `"type":"bet","chips":3`, 1)},
+		{"not json", good + "seq 10 bet\n"},
+		{"card not in hand", good + `{"seq":10,"type":"discard","player":0,"cards":["Red Joker"]}` + "\n"},
+	}
+	for _, tt := range tests {
+		events, err := readGameLog(strings.NewReader(tt.log))
+		if err == nil {
+			_, err = replayGame(events, len(events))
+		}
+		if !errors.Is(err, ErrBadGameLog) {
+			t.Errorf("%s: expected a bad game log, got %v", tt.name, err)
+		}
+	}
+}
+
+// errWriter fails every write, like a full disk.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+// A failed event leaves the recorder as it was, so its state still matches the log.
+func TestGameRecorderFailedEvents(t *testing.T) {
+	var buf bytes.Buffer
+	g := newGameRecorder(&buf)
+	g.newGame("standard")
+	if _, err := g.draw(-1, 2); err == nil {
+		t.Fatalf("Expected a draw for player -1 to fail")
+	}
+	if len(g.state.Deck) != 52 || g.seq != 1 {
+		t.Errorf("Expected the failed draw to leave the deck alone, got %d cards and seq %d", len(g.state.Deck), g.seq)
+	}
+
+	g.enc = json.NewEncoder(errWriter{})
+	if _, err := g.draw(0, 2); err == nil {
+		t.Fatalf("Expected the write to fail")
+	}
+	if len(g.state.Deck) != 52 || len(g.state.Hands) != 0 || g.seq != 1 {
+		t.Errorf("Expected an event that wasn't written to leave the state alone, got %d cards", len(g.state.Deck))
+	}
+
+	events, _ := readGameLog(&buf)
+	if state, err := replayGame(events, len(events)); err != nil || !sameCards(state.Deck, g.state.Deck) {
+		t.Errorf("Expected the log to give the recorder's state, got %v", err)
+	}
+}
+
+func TestDiffGames(t *testing.T) {
+	a, _ := readGameLog(bytes.NewReader(recordGame(t)))
+
+	if d, err := diffGames(a, a); err != nil || d.After != -1 {
+		t.Errorf("Expected no difference, got %+v ( %v )", d, err)
+	}
+
+	b := append([]gameEvent{}, a...)
+	b[4].Amount = 20
+	d, err := diffGames(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.After != 5 || strings.Join(d.Changes, "\n") != "player 2 bet: 10 vs 20\npot: 20 vs 30" {
+		t.Errorf("Expected the bet of player 2 to differ after 5 events, got %+v", d)
+	}
+
+	// a log that stops early differs once the longer one goes on
+	d, _ = diffGames(a, a[:7])
+	if d.After != 8 || !strings.HasPrefix(d.Changes[0], "deck: 35 vs 37 cards") {
+		t.Errorf("Expected the draw to make the difference, got %+v", d)
+	}
+}
+
+func TestDealLogCommands(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "game.jsonl")
+	for _, seed := range []string{"5", "6"} {
+		if code, _, stderr := runCLI(t, "", "deal", "-seed", seed, "-hands", "2", "-size", "2", "-log", log); code != exitOK {
+			t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+		}
+	}
+	events, err := loadGameLog(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second deal is appended after the first, a new deck each
+	if len(events) != 6 || events[3].Seq != 3 || events[3].Type != eventNew {
+		t.Fatalf("Expected 2 deals of 3 events, got %+v", events)
+	}
+
+	code, stdout, _ := runCLI(t, "", "replay", "-at", "3", log)
+	if code != exitOK || !strings.Contains(stdout, "After 3 of 6 events") || !strings.Contains(stdout, "Deck: 48 cards") {
+		t.Errorf("Expected the state after the first deal, got %v ( %s )", code, stdout)
+	}
+	if code, _, _ := runCLI(t, "", "replay", "-at", "7", log); code != exitUsage {
+		t.Errorf("Expected a usage error past the end, got %v", code)
+	}
+
+	other := filepath.Join(dir, "other.jsonl")
+	runCLI(t, "", "deal", "-seed", "5", "-hands", "2", "-size", "2", "-log", other)
+	code, stdout, _ = runCLI(t, "", "diff", log, other)
+	if code != exitError || !strings.Contains(stdout, "differ after 4 events") {
+		t.Errorf("Expected the logs to differ after the second new deck, got %v ( %s )", code, stdout)
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+// Go Fish: every player gets 7 cards ( 5 with four players or more ) and the rest is the pool.
+// On its turn a player asks another one for a rank it holds itself. If the other player has cards
+// of that rank it gives all of them and the asker goes again, otherwise the asker "goes fishing"
+// and draws a card from the pool - it goes again only when it fished the rank it asked for.
+// Four cards of a rank are a book and are laid down at once. A player without cards draws one
+// before asking, and a player with nobody left to ask ( no other player holds cards ) fishes
+// and ends its turn. The game ends when the 13 books are down and the most books win.
+// See referee.go for how the players and the referee talk.
+
+// fishMessage is what the referee sends to a player goroutine. Only one of Take, Give or Turn is used.
+type fishMessage struct {
+	// Take are cards for the player: dealt, given by another player or fished from the pool.
+	Take deck
+	// Give asks for every card of the rank, sent on Cards - none when the player has none.
+	Give  Rank
+	Cards chan<- deck
+	// Turn asks the player who to ask for what, the answer goes to Ask.
+	Turn *fishView
+	Ask  chan<- fishAsk
+}
+
+// fishView is the table as every player can see it.
+type fishView struct {
+	Seat int
+	// Cards and Books are the number of cards in the hand and the books laid down, by seat.
+	Cards []int
+	Books []int
+	// Out are the players that forfeited, they can't be asked.
+	Out []bool
+	// Pool is the number of cards left to fish.
+	Pool int
+}
+
+// fishAsk is the question of a turn: does Player have any cards of Rank?
+type fishAsk struct {
+	Player int
+	Rank   Rank
+}
+
+// fishPlayer runs one player: it reads messages until the channel is closed.
+// r is the player's own random generator, to keep a game the same for the same seed.
+type fishPlayer func(seat int, r *rand.Rand, in <-chan fishMessage)
+
+// honestFishPlayer asks for the rank it holds the most of, from a player picked at random.
+func honestFishPlayer(seat int, r *rand.Rand, in <-chan fishMessage) {
+	var hand deck
+	for msg := range in {
+		hand = append(hand, msg.Take...)
+		switch {
+		case msg.Cards != nil:
+			var given deck
+			hand = slices.DeleteFunc(hand, func(c Card) bool {
+				if c.Rank == msg.Give {
+					given = append(given, c)
+					return true
+				}
+				return false
+			})
+			msg.Cards <- given
+		case msg.Ask != nil:
+			msg.Ask <- chooseFishAsk(hand, *msg.Turn, r)
+		}
+	}
+}
+
+func chooseFishAsk(hand deck, v fishView, r *rand.Rand) fishAsk {
+	counts := map[Rank]int{}
+	var ask fishAsk
+	for _, card := range hand {
+		counts[card.Rank]++
+		if n := counts[card.Rank]; n > counts[ask.Rank] || n == counts[ask.Rank] && card.Rank < ask.Rank {
+			ask.Rank = card.Rank
+		}
+	}
+	var targets []int
+	for p, n := range v.Cards {
+		if p != v.Seat && n > 0 && !v.Out[p] {
+			targets = append(targets, p)
+		}
+	}
+	if len(targets) > 0 {
+		ask.Player = targets[r.Intn(len(targets))]
+	}
+	return ask
+}
+
+// defaultFishTurns stops a game that goes on too long, without a winner.
+const defaultFishTurns = 10000
+
+// fishConfig sets up one game of Go Fish.
+type fishConfig struct {
+	// Players is the number of players, 2 to 6.
+	Players int
+	Seed    int64
+	// Strategies are the players by seat, honestFishPlayer for the missing ones.
+	Strategies []fishPlayer
+	// Timeout is how long the referee waits for a player, defaultMoveTimeout when 0.
+	Timeout time.Duration
+	// MaxTurns is the questions before the game is stopped, defaultFishTurns when 0.
+	MaxTurns int
+}
+
+// fishGame is the referee's side of the game: the channels and the ledger of every hand.
+type fishGame struct {
+	in      []chan fishMessage
+	hands   []deck
+	books   []int
+	out     []bool
+	pool    deck
+	timeout time.Duration
+	res     refereeResult
+}
+
+// playGoFish deals the cards and referees the game to the end.
+func playGoFish(cfg fishConfig) (refereeResult, error) {
+	n := cfg.Players
+	if n < 2 || n > 6 {
+		return refereeResult{}, fmt.Errorf("go fish is played by 2 to 6 players, got %d", n)
+	}
+	if len(cfg.Strategies) > n {
+		return refereeResult{}, fmt.Errorf("%d strategies for %d players", len(cfg.Strategies), n)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultMoveTimeout
+	}
+	if cfg.MaxTurns <= 0 {
+		cfg.MaxTurns = defaultFishTurns
+	}
+	size := 7
+	if n >= 4 {
+		size = 5
+	}
+
+	r := rand.New(rand.NewSource(cfg.Seed))
+	d := newDeck()
+	d.shuffleWith(r)
+	dl := newDealer(d)
+	hands, err := dl.dealHands(n, size, dealRoundRobin)
+	if err != nil {
+		return refereeResult{}, err
+	}
+	g := &fishGame{
+		in:      make([]chan fishMessage, n),
+		hands:   make([]deck, n),
+		books:   make([]int, n),
+		out:     make([]bool, n),
+		pool:    dl.cards,
+		timeout: cfg.Timeout,
+	}
+	for p := range n {
+		player := honestFishPlayer
+		if p < len(cfg.Strategies) && cfg.Strategies[p] != nil {
+			player = cfg.Strategies[p]
+		}
+		g.in[p] = make(chan fishMessage)
+		go player(p, rand.New(rand.NewSource(r.Int63())), g.in[p])
+	}
+	defer func() {
+		for _, in := range g.in {
+			close(in)
+		}
+	}()
+
+	for p, hand := range hands {
+		g.take(p, hand)
+	}
+	turn := 0
+	for g.res.Turns < cfg.MaxTurns && g.booksLeft() > 0 && g.playing() >= 2 {
+		p := turn
+		if g.out[p] {
+			turn = (turn + 1) % n
+			continue
+		}
+		if len(g.hands[p]) == 0 {
+			if len(g.pool) == 0 {
+				turn = (turn + 1) % n
+				continue
+			}
+			g.fish(p)
+			if g.out[p] {
+				continue
+			}
+		}
+		if !g.canAsk(p) {
+			// the referee doesn't make a player ask nobody - it fishes and the turn goes on
+			if len(g.pool) > 0 {
+				g.fish(p)
+			}
+			turn = (turn + 1) % n
+			continue
+		}
+
+		ask, ok := g.ask(p)
+		if !ok {
+			continue
+		}
+		g.res.Turns++
+		given, ok := g.give(ask.Player, ask.Rank)
+		if !ok {
+			// the player asked forfeited, the asker goes again
+			continue
+		}
+		if len(given) > 0 {
+			g.take(p, given)
+			continue
+		}
+		if len(g.pool) > 0 {
+			if fished := g.fish(p); fished.Rank == ask.Rank && !g.out[p] {
+				continue
+			}
+		}
+		turn = (turn + 1) % n
+	}
+
+	if g.booksLeft() > 0 && g.playing() >= 2 {
+		// stopped after MaxTurns
+		return g.res, nil
+	}
+	most := -1
+	for p, books := range g.books {
+		switch {
+		case g.out[p]:
+		case books > most:
+			most, g.res.Winners = books, []int{p}
+		case books == most:
+			g.res.Winners = append(g.res.Winners, p)
+		}
+	}
+	return g.res, nil
+}
+
+func (g *fishGame) booksLeft() int {
+	left := 13
+	for _, books := range g.books {
+		left -= books
+	}
+	return left
+}
+
+// canAsk reports whether another player that didn't forfeit holds cards.
+func (g *fishGame) canAsk(p int) bool {
+	for other, hand := range g.hands {
+		if other != p && !g.out[other] && len(hand) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// playing is the number of players that didn't forfeit.
+func (g *fishGame) playing() int {
+	n := 0
+	for _, out := range g.out {
+		if !out {
+			n++
+		}
+	}
+	return n
+}
+
+// forfeit takes the player out of the game. Its cards go back to the bottom of the pool.
+func (g *fishGame) forfeit(p int, format string, args ...any) {
+	g.out[p] = true
+	g.res.Forfeits = append(g.res.Forfeits, p)
+	g.res.Reason = fmt.Sprintf("player %d ", p+1) + fmt.Sprintf(format, args...)
+	g.pool = append(g.pool, g.hands[p]...)
+	g.hands[p] = nil
+}
+
+func (g *fishGame) send(p int, msg fishMessage) bool {
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+	select {
+	case g.in[p] <- msg:
+		return true
+	case <-timer.C:
+		g.forfeit(p, "doesn't listen")
+		return false
+	}
+}
+
+// take gives the cards to the player and lays down the books it completed.
+func (g *fishGame) take(p int, cards deck) {
+	if !g.send(p, fishMessage{Take: slices.Clone(cards)}) {
+		g.pool = append(g.pool, cards...)
+		return
+	}
+	g.hands[p] = append(g.hands[p], cards...)
+	for _, card := range cards {
+		if g.out[p] || countRank(g.hands[p], card.Rank) < 4 {
+			continue
+		}
+		if book, ok := g.give(p, card.Rank); ok && len(book) == 4 {
+			g.books[p]++
+		}
+	}
+}
+
+// fish draws the top card of the pool for the player.
+func (g *fishGame) fish(p int) Card {
+	card := g.pool[0]
+	g.pool = g.pool[1:]
+	g.take(p, deck{card})
+	return card
+}
+
+// give asks the player for every card of the rank and checks the answer against the ledger.
+func (g *fishGame) give(p int, rank Rank) (deck, bool) {
+	reply := make(chan deck, 1)
+	if !g.send(p, fishMessage{Give: rank, Cards: reply}) {
+		return nil, false
+	}
+	cards, ok := awaitCards(reply, g.timeout)
+	if !ok {
+		g.forfeit(p, "didn't answer in %v", g.timeout)
+		return nil, false
+	}
+	var want deck
+	for _, card := range g.hands[p] {
+		if card.Rank == rank {
+			want = append(want, card)
+		}
+	}
+	if !sameHand(cards, want) {
+		g.forfeit(p, "gave %v for %v, it holds %v", cards, rank, want)
+		return nil, false
+	}
+	g.hands[p] = slices.DeleteFunc(g.hands[p], func(c Card) bool { return c.Rank == rank })
+	return cards, true
+}
+
+// ask gets the question of the player's turn. A question against the rules is a forfeit.
+func (g *fishGame) ask(p int) (fishAsk, bool) {
+	v := &fishView{Seat: p, Books: slices.Clone(g.books), Out: slices.Clone(g.out), Pool: len(g.pool)}
+	for _, hand := range g.hands {
+		v.Cards = append(v.Cards, len(hand))
+	}
+	reply := make(chan fishAsk, 1)
+	if !g.send(p, fishMessage{Turn: v, Ask: reply}) {
+		return fishAsk{}, false
+	}
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+	var ask fishAsk
+	select {
+	case ask = <-reply:
+	case <-timer.C:
+		g.forfeit(p, "didn't answer in %v", g.timeout)
+		return fishAsk{}, false
+	}
+
+	switch {
+	case ask.Player < 0 || ask.Player >= len(g.hands) || ask.Player == p || g.out[ask.Player]:
+		g.forfeit(p, "asked player %d", ask.Player+1)
+	case len(g.hands[ask.Player]) == 0:
+		g.forfeit(p, "asked player %d who has no cards", ask.Player+1)
+	case countRank(g.hands[p], ask.Rank) == 0:
+		g.forfeit(p, "asked for %v without holding one", ask.Rank)
+	default:
+		return ask, true
+	}
+	return fishAsk{}, false
+}
+
+func countRank(d deck, rank Rank) int {
+	n := 0
+	for _, card := range d {
+		if card.Rank == rank {
+			n++
+		}
+	}
+	return n
+}
+
+// runGoFish is the "cards gofish" command: it plays many games and shows how long they take.
+func runGoFish(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "gofish")
+	players := fs.Int("players", 3, "number of players, 2 to 6")
+	games := fs.Int("games", 1000, "number of games")
+	seed := fs.Int64("seed", 1, "seed of the first game, the next games use the next seeds")
+	timeout := fs.Duration("timeout", defaultMoveTimeout, "how long the referee waits for a player")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *players < 2 || *players > 6 {
+		return usagef("go fish is played by 2 to 6 players, got %d", *players)
+	}
+	if *games < 1 || *timeout <= 0 {
+		return usagef("-games and -timeout must be positive")
+	}
+	stats, err := simulateGames(*games, *players, *seed, func(seed int64) (refereeResult, error) {
+		return playGoFish(fishConfig{Players: *players, Seed: seed, Timeout: *timeout})
+	})
+	if err != nil {
+		return err
+	}
+	stats.print(env.stdout, "go fish", "questions")
+	return nil
+}
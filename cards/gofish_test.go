@@ -0,0 +1,133 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlayGoFish(t *testing.T) {
+	for players := 2; players <= 6; players++ {
+		res, err := playGoFish(fishConfig{Players: players, Seed: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Winners) == 0 || res.Turns < 1 || len(res.Forfeits) != 0 {
+			t.Errorf("%d players: Expected a finished game without forfeits, got %+v", players, res)
+		}
+		again, err := playGoFish(fishConfig{Players: players, Seed: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(again.Winners, res.Winners) || again.Turns != res.Turns {
+			t.Errorf("%d players: Expected the same game for the same seed, got %+v and %+v", players, res, again)
+		}
+	}
+}
+
+// Honest players only break a rule when the referee makes them, e.g. by asking for a question
+// when nobody else holds cards - over many games there must be no forfeit at all.
+func TestPlayGoFishHonestPlayersNeverForfeit(t *testing.T) {
+	for players := 2; players <= 6; players++ {
+		for seed := range int64(300) {
+			res, err := playGoFish(fishConfig{Players: players, Seed: seed})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Forfeits) != 0 || len(res.Winners) == 0 {
+				t.Fatalf("%d players, seed %d: Expected a finished game without forfeits, got %+v", players, seed, res)
+			}
+		}
+	}
+}
+
+func TestPlayGoFishErrors(t *testing.T) {
+	for _, cfg := range []fishConfig{
+		{Players: 1},
+		{Players: 7},
+		{Players: 2, Strategies: make([]fishPlayer, 3)},
+	} {
+		if _, err := playGoFish(cfg); err == nil {
+			t.Errorf("Expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestPlayGoFishMaxTurns(t *testing.T) {
+	res, err := playGoFish(fishConfig{Players: 3, Seed: 3, MaxTurns: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Winners) != 0 || res.Turns != 2 {
+		t.Errorf("Expected a draw after 2 questions, got %+v", res)
+	}
+}
+
+func TestPlayGoFishForfeits(t *testing.T) {
+	silent := func(seat int, r *rand.Rand, in <-chan fishMessage) {
+		for range in {
+		}
+	}
+	// liar never gives a card away, even when it holds the rank
+	liar := func(seat int, r *rand.Rand, in <-chan fishMessage) {
+		var hand deck
+		for msg := range in {
+			hand = append(hand, msg.Take...)
+			switch {
+			case msg.Cards != nil:
+				msg.Cards <- nil
+			case msg.Ask != nil:
+				msg.Ask <- chooseFishAsk(hand, *msg.Turn, r)
+			}
+		}
+	}
+	for _, tc := range []struct {
+		name   string
+		player fishPlayer
+		reason string
+	}{
+		{"silent", silent, "player 2 didn't answer"},
+		{"liar", liar, "player 2 gave []"},
+	} {
+		cfg := fishConfig{Players: 3, Seed: 1, Strategies: []fishPlayer{nil, tc.player}, Timeout: 20 * time.Millisecond}
+		res, err := playGoFish(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(res.Forfeits, []int{1}) || slices.Contains(res.Winners, 1) || len(res.Winners) == 0 {
+			t.Errorf("%s: Expected player 2 to forfeit and the others to finish, got %+v", tc.name, res)
+		}
+		if !strings.Contains(res.Reason, tc.reason) {
+			t.Errorf("%s: Expected the reason %q, got %q", tc.name, tc.reason, res.Reason)
+		}
+	}
+}
+
+func TestChooseFishAsk(t *testing.T) {
+	hand := cardsFromCodes(t, "As 7h 7d Kc Ks")
+	v := fishView{Seat: 0, Cards: []int{5, 0, 4, 3}, Out: []bool{false, false, false, true}}
+	ask := chooseFishAsk(hand, v, rand.New(rand.NewSource(1)))
+	if ask.Rank != Seven {
+		t.Errorf("Expected the most held rank, the lower one on a tie, got %v", ask.Rank)
+	}
+	if ask.Player != 2 {
+		t.Errorf("Expected the only player with cards still in, got %d", ask.Player)
+	}
+}
+
+func TestGoFishCommand(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "gofish", "-players", "4", "-games", "20")
+	if code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+	for _, want := range []string{"20 games of go fish, 4 players", "questions", "Player 4:", "Draws:"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in the output, got\n%s", want, stdout)
+		}
+	}
+	if code, _, _ := runCLI(t, "", "gofish", "-players", "8"); code != exitUsage {
+		t.Errorf("Expected a usage error for 8 players, got %v", code)
+	}
+}
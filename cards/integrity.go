@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Loading a deck only checks that every card exists and isn't there too often, because a
+// file saved after dealing is still a valid deck. The checker below compares a deck with the
+// full deck of its card set instead and lists everything that is wrong with it, and a signed
+// file makes sure nobody changed the deck or its metadata since it was saved.
+
+var (
+	// ErrIncompleteDeck is returned for a deck that misses cards of its set.
+	ErrIncompleteDeck = errors.New("incomplete deck")
+	// ErrBadSignature is returned for a signed deck file that was changed after it was signed,
+	// or that is loaded with another key.
+	ErrBadSignature = errors.New("bad deck signature")
+)
+
+// deckReport is the result of checking a deck against the full deck of its set.
+type deckReport struct {
+	Set   string
+	Cards int
+	// Missing are the cards of the set that aren't in the deck, one entry for every missing copy.
+	Missing deck
+	// Duplicates are the copies of a card above what the set has, in deck order.
+	Duplicates deck
+	// Unknown are the cards, by name, that don't belong to the set.
+	Unknown []string
+}
+
+// checkDeck compares the deck with the full deck of the named set. Without a set the deck is
+// checked against a standard deck, or against the deck with jokers when it holds a joker.
+func checkDeck(d deck, set string) (deckReport, error) {
+	if set == "" {
+		set = "standard"
+		for _, card := range d {
+			if card.Rank == BlackJoker || card.Rank == RedJoker {
+				set = "jokers"
+			}
+		}
+	}
+	cs, err := lookupCardSet(set)
+	if err != nil {
+		return deckReport{}, err
+	}
+
+	r := deckReport{Set: set, Cards: len(d)}
+	seen := map[Card]int{}
+	for _, card := range d {
+		n, ok := cs.counts[card]
+		switch {
+		case !ok:
+			r.Unknown = append(r.Unknown, card.String())
+		case seen[card] >= n:
+			r.Duplicates = append(r.Duplicates, card)
+		default:
+			seen[card]++
+		}
+	}
+	for _, card := range cs.order {
+		if seen[card] > 0 {
+			seen[card]--
+			continue
+		}
+		r.Missing = append(r.Missing, card)
+	}
+	return r, nil
+}
+
+// checkDeckFile reads a deck file and checks it with checkDeck. Unlike loading it doesn't stop
+// at the first bad card, names that aren't cards at all end up in Unknown. A signed file is
+// verified with the key first, set is the one stored in the file when empty.
+func checkDeckFile(filename, set string, key []byte) (deckReport, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return deckReport{}, err
+	}
+	format := detectFormat(filename, bs)
+	if key != nil {
+		if format != formatSigned {
+			return deckReport{}, fmt.Errorf("%w: %s is not a signed deck file", ErrBadSignature, filename)
+		}
+		if bs, err = openSignedDeck(bs, key); err != nil {
+			return deckReport{}, fmt.Errorf("checking %s: %w", filename, err)
+		}
+		format = formatJSON
+	}
+
+	var unknown []string
+	d, info, err := parseDeckFile(format, bs, &unknown)
+	if err != nil {
+		return deckReport{}, fmt.Errorf("checking %s: %w", filename, err)
+	}
+	switch {
+	case set == "":
+		set = info.Set
+	case info.Set != "" && info.Set != set:
+		return deckReport{}, fmt.Errorf("%w: a %s deck, not %s", ErrBadDeckFile, info.Set, set)
+	}
+	r, err := checkDeck(d, set)
+	if err != nil {
+		return deckReport{}, err
+	}
+	r.Cards += len(unknown)
+	r.Unknown = append(r.Unknown, unknown...)
+	return r, nil
+}
+
+func (r deckReport) complete() bool {
+	return len(r.Missing) == 0 && len(r.Duplicates) == 0 && len(r.Unknown) == 0
+}
+
+// err describes everything that is wrong with the deck, nil for a complete deck.
+// It wraps ErrIncompleteDeck, ErrDuplicateCard and ErrUnknownCard for the problems found.
+func (r deckReport) err() error {
+	if r.complete() {
+		return nil
+	}
+	var (
+		msgs []string
+		errs []any
+	)
+	if len(r.Missing) > 0 {
+		msgs = append(msgs, "%w: %d missing")
+		errs = append(errs, ErrIncompleteDeck, len(r.Missing))
+	}
+	if len(r.Duplicates) > 0 {
+		msgs = append(msgs, "%w: %d extra")
+		errs = append(errs, ErrDuplicateCard, len(r.Duplicates))
+	}
+	if len(r.Unknown) > 0 {
+		msgs = append(msgs, "%w: %d not in the set")
+		errs = append(errs, ErrUnknownCard, len(r.Unknown))
+	}
+	return fmt.Errorf("%s deck: "+strings.Join(msgs, ", "), append([]any{r.Set}, errs...)...)
+}
+
+func (r deckReport) print(w io.Writer) {
+	size := r.Cards - len(r.Duplicates) - len(r.Unknown) + len(r.Missing)
+	if r.complete() {
+		fmt.Fprintf(w, "Complete %s deck, %d cards\n", r.Set, r.Cards)
+		return
+	}
+	fmt.Fprintf(w, "%d cards, a full %s deck has %d\n", r.Cards, r.Set, size)
+	if len(r.Missing) > 0 {
+		fmt.Fprintf(w, "Missing: %s\n", strings.Join(cardNames(r.Missing), ", "))
+	}
+	if len(r.Duplicates) > 0 {
+		fmt.Fprintf(w, "Duplicates: %s\n", strings.Join(cardNames(r.Duplicates), ", "))
+	}
+	if len(r.Unknown) > 0 {
+		fmt.Fprintf(w, "Unknown: %s\n", strings.Join(r.Unknown, ", "))
+	}
+}
+
+func cardNames(d deck) []string {
+	names := make([]string, len(d))
+	for i, card := range d {
+		names[i] = card.String()
+	}
+	return names
+}
+
+// A signed deck file is the JSON document ( see persist.go ) behind one line with its
+// HMAC-SHA256 in hex:
+//
+//	DECK-HMAC-SHA256 5f1c...
+//	{"version": 1, "created": ..., "cards": [...]}
+//
+// The HMAC covers every byte of the document, so changing a card, the order, the seed or the
+// creation time makes the file fail to load. The key is a secret shared by whoever saves and
+// loads the decks - without it the file can still be read, but not changed unnoticed.
+const signedMagic = "DECK-HMAC-SHA256 "
+
+// SaveSignedDeck writes the deck and its metadata to a signed file, whatever the extension.
+func SaveSignedDeck(filename string, d deck, info deckInfo, key []byte) error {
+	bs, err := signDeck(d, info, key)
+	if err != nil {
+		return fmt.Errorf("saving %s: %w", filename, err)
+	}
+	return os.WriteFile(filename, bs, 0666)
+}
+
+// LoadSignedDeck reads a file written by SaveSignedDeck. A file that was changed since, or
+// that was signed with another key, fails with ErrBadSignature. set works as in LoadDeckAs.
+func LoadSignedDeck(filename, set string, key []byte) (deck, deckInfo, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, deckInfo{}, err
+	}
+	doc, err := openSignedDeck(bs, key)
+	if err == nil {
+		var d deck
+		var info deckInfo
+		if d, info, err = decodeDeckAs(formatJSON, doc, set); err == nil {
+			return d, info, nil
+		}
+	}
+	return nil, deckInfo{}, fmt.Errorf("loading %s: %w", filename, err)
+}
+
+func signDeck(d deck, info deckInfo, key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errors.New("can't sign a deck with an empty key")
+	}
+	doc, err := encodeDeck(formatJSON, d, info)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Appendf(nil, "%s%x\n%s\n", signedMagic, deckMAC(doc, key), doc), nil
+}
+
+// openSignedDeck checks the signature of the file and returns the JSON document it signs.
+func openSignedDeck(bs, key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%w: no key to check it with", ErrBadSignature)
+	}
+	line, doc, ok := bytes.Cut(bs, []byte("\n"))
+	if !ok || !bytes.HasPrefix(line, []byte(signedMagic)) {
+		return nil, fmt.Errorf("%w: missing %q header", ErrBadDeckFile, strings.TrimSpace(signedMagic))
+	}
+	sum, err := hex.DecodeString(string(bytes.TrimSpace(line[len(signedMagic):])))
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("%w: the signature is not a hex HMAC-SHA256", ErrBadSignature)
+	}
+	// the newline after the document is written by signDeck, it isn't signed
+	doc = bytes.TrimSuffix(doc, []byte("\n"))
+	if !hmac.Equal(sum, deckMAC(doc, key)) {
+		return nil, fmt.Errorf("%w: the deck was changed after it was signed, or the key is wrong", ErrBadSignature)
+	}
+	return doc, nil
+}
+
+func deckMAC(doc, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(doc)
+	return mac.Sum(nil)
+}
+
+// readKey reads the signing key from a file, without the line break editors add at the end.
+func readKey(filename string) ([]byte, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	key := bytes.TrimSpace(bs)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("the key file %s is empty", filename)
+	}
+	return key, nil
+}
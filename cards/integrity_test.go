@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckDeck(t *testing.T) {
+	partial := newDeck()[2:]
+	doubled := append(newDeck(), Card{Rank: Ace, Suit: Spades})
+	pinochle, _ := lookupCardSet("pinochle")
+
+	tests := []struct {
+		name       string
+		d          deck
+		set        string
+		wantSet    string
+		missing    int
+		duplicates deck
+		unknown    []string
+	}{
+		{"complete", newDeck(), "", "standard", 0, nil, nil},
+		{"with jokers", newDeckWithJokers(), "", "jokers", 0, nil, nil},
+		{"dealt", partial, "", "standard", 2, nil, nil},
+		{"duplicate", doubled, "", "standard", 0, deck{{Rank: Ace, Suit: Spades}}, nil},
+		{"pinochle", pinochle.newDeck(), "pinochle", "pinochle", 0, nil, nil},
+		{"not pinochle", newDeck(), "pinochle", "pinochle", 24, nil, []string{"Two of Spades"}},
+	}
+	for _, tt := range tests {
+		r, err := checkDeck(tt.d, tt.set)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if r.Set != tt.wantSet || r.Cards != len(tt.d) || len(r.Missing) != tt.missing || !slices.Equal(r.Duplicates, tt.duplicates) {
+			t.Errorf("%s: Expected the %s set, %d missing and duplicates %v, got %+v", tt.name, tt.wantSet, tt.missing, tt.duplicates, r)
+		}
+		if len(tt.unknown) > 0 && (len(r.Unknown) == 0 || r.Unknown[0] != tt.unknown[0]) {
+			t.Errorf("%s: Expected %v first among the unknown cards, got %v", tt.name, tt.unknown[0], r.Unknown)
+		}
+		if complete := tt.missing == 0 && tt.duplicates == nil && tt.unknown == nil; r.complete() != complete || (r.err() == nil) != complete {
+			t.Errorf("%s: Expected complete to be %v, got %v ( %v )", tt.name, complete, r.complete(), r.err())
+		}
+	}
+
+	r, _ := checkDeck(partial, "")
+	if !slices.Equal(r.Missing, newDeck()[:2]) {
+		t.Errorf("Expected the first two cards to be missing, got %v", r.Missing)
+	}
+	if _, err := checkDeck(newDeck(), "nope"); !errors.Is(err, ErrUnknownCardSet) {
+		t.Errorf("Expected %v, got %v", ErrUnknownCardSet, err)
+	}
+}
+
+func TestDeckReportErr(t *testing.T) {
+	r, _ := checkDeck(append(newDeck()[1:], Card{Rank: Two, Suit: Spades}), "")
+	err := r.err()
+	if !errors.Is(err, ErrIncompleteDeck) || !errors.Is(err, ErrDuplicateCard) || errors.Is(err, ErrUnknownCard) {
+		t.Errorf("Expected a missing and a duplicate card, got %v", err)
+	}
+	if want := "standard deck: incomplete deck: 1 missing, duplicate card: 1 extra"; err.Error() != want {
+		t.Errorf("Expected %q, got %q", want, err.Error())
+	}
+}
+
+// The checker reports every bad card of a file, loading stops at the first one.
+func TestCheckDeckFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "bad.txt")
+	os.WriteFile(filename, []byte("Ace of Spades,Eleven of Spades,Ace of Spades,Two of Stars"), 0666)
+
+	r, err := checkDeckFile(filename, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Cards != 4 || len(r.Missing) != 51 || len(r.Duplicates) != 1 || !slices.Equal(r.Unknown, []string{"Eleven of Spades", "Two of Stars"}) {
+		t.Errorf("Expected 51 missing, 1 duplicate and 2 unknown cards, got %+v", r)
+	}
+
+	doc := filepath.Join(dir, "pinochle.json")
+	pinochle, _ := lookupCardSet("pinochle")
+	if err := SaveDeckInfo(doc, pinochle.newDeck(), deckInfo{Set: "pinochle"}); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := checkDeckFile(doc, "", nil); err != nil || !r.complete() || r.Set != "pinochle" {
+		t.Errorf("Expected a complete pinochle deck, got %+v, %v", r, err)
+	}
+	if _, err := checkDeckFile(doc, "uno", nil); !errors.Is(err, ErrBadDeckFile) {
+		t.Errorf("Expected %v for another set, got %v", ErrBadDeckFile, err)
+	}
+}
+
+func TestSignedDeck(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "deck.sdeck")
+	key := []byte("secret")
+	seed := int64(7)
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	d := newDeckWithJokers()
+	d.shuffleSeed(seed)
+
+	if err := SaveSignedDeck(filename, d, deckInfo{Created: created, Seed: &seed}, key); err != nil {
+		t.Fatal(err)
+	}
+	loaded, info, err := LoadSignedDeck(filename, "", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(loaded, d) || info.Seed == nil || *info.Seed != seed || !info.Created.Equal(created) {
+		t.Errorf("Expected the same deck and metadata after the round trip, got %v %+v", loaded, info)
+	}
+	bs, _ := os.ReadFile(filename)
+	if got := sniffFormat(bs); got != formatSigned {
+		t.Errorf("Expected the header to be detected as %v, got %v", formatSigned, got)
+	}
+	if _, err := LoadDeck(filename); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected %v without the key, got %v", ErrBadSignature, err)
+	}
+	if _, _, err := LoadSignedDeck(filename, "", []byte("other")); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected %v with another key, got %v", ErrBadSignature, err)
+	}
+
+	// swapping two cards or changing the metadata breaks the signature
+	first, second := []byte(d[0].String()), []byte(d[1].String())
+	tampered := [][]byte{
+		bytes.Replace(bytes.Replace(bs, first, []byte("#"), 1), second, first, 1),
+		bytes.Replace(bs, []byte(`"seed": 7`), []byte(`"seed": 8`), 1),
+		bytes.Replace(bs, []byte("2024-05-01"), []byte("2024-05-02"), 1),
+		bs[:len(bs)-20],
+	}
+	tampered[0] = bytes.Replace(tampered[0], []byte("#"), second, 1)
+	for i, bad := range tampered {
+		if bytes.Equal(bad, bs) {
+			t.Fatalf("tampered file %d is unchanged", i)
+		}
+		os.WriteFile(filename, bad, 0666)
+		if _, _, err := LoadSignedDeck(filename, "", key); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("tampered file %d: Expected %v, got %v", i, ErrBadSignature, err)
+		}
+	}
+
+	if err := SaveDeck(filepath.Join(dir, "nokey.sdeck"), d); err == nil {
+		t.Errorf("Expected an error saving a signed file without a key")
+	}
+	if err := SaveSignedDeck(filename, d, deckInfo{}, nil); err == nil {
+		t.Errorf("Expected an error signing with an empty key")
+	}
+	if err := SaveSignedDeck(filename, append(d, d[0]), deckInfo{}, key); !errors.Is(err, ErrDuplicateCard) {
+		t.Errorf("Expected %v, got %v", ErrDuplicateCard, err)
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	os.WriteFile(keyFile, []byte("secret\n"), 0600)
+	signed := filepath.Join(dir, "deck.sdeck")
+	if code, _, stderr := runCLI(t, "", "save", "-key", keyFile, signed); code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+
+	code, stdout, _ := runCLI(t, "", "check", "-key", keyFile, signed)
+	if code != exitOK || !strings.Contains(stdout, "Complete standard deck, 52 cards") {
+		t.Errorf("Expected a complete deck, got %v\n%s", code, stdout)
+	}
+	if code, stdout, _ := runCLI(t, "", "load", "-key", keyFile, signed); code != exitOK || !strings.HasPrefix(stdout, "52 cards") {
+		t.Errorf("Expected the signed deck to load, got %v\n%s", code, stdout)
+	}
+
+	partial := filepath.Join(dir, "partial.txt")
+	newDeck()[1:].saveToFile(partial)
+	code, stdout, stderr := runCLI(t, "", "check", partial)
+	if code != exitError || !strings.Contains(stdout, "Missing: Ace of Spades\n") || !strings.Contains(stderr, "1 missing") {
+		t.Errorf("Expected the missing Ace, got %v\n%s%s", code, stdout, stderr)
+	}
+	if code, _, _ := runCLI(t, "", "check", "-key", keyFile, partial); code != exitError {
+		t.Errorf("Expected an error checking an unsigned file with a key, got %v", code)
+	}
+	if code, _, _ := runCLI(t, "", "check"); code != exitUsage {
+		t.Errorf("Expected a usage error without a file, got %v", code)
+	}
+}
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Klondike is the solitaire everybody knows: 7 columns on the tableau, 4 foundations built up
+// by suit from the Ace, and a stock that is drawn to the waste 1 or 3 cards at a time.
+// Unlike a deck, the top of every Klondike pile is its last card - cards are put on and
+// taken off the piles all the time, and appending at the end is what slices are good at.
+
+const klondikeColumns = 7
+
+// klondikePile names the kinds of piles on the table.
+type klondikePile int
+
+const (
+	pileStock klondikePile = iota
+	pileWaste
+	pileFoundation
+	pileTableau
+)
+
+// klondikeSpot is one pile of the table. Index is the suit of a foundation or the column of the tableau.
+type klondikeSpot struct {
+	Pile  klondikePile
+	Index int
+}
+
+func (s klondikeSpot) String() string {
+	switch s.Pile {
+	case pileStock:
+		return "stock"
+	case pileWaste:
+		return "waste"
+	case pileFoundation:
+		return Suit(s.Index).String()
+	}
+	return fmt.Sprintf("column %d", s.Index+1)
+}
+
+// klondikeMove moves Count cards. A draw is a move from the stock to the waste,
+// turning the waste over when the stock is empty is a move from the waste to the stock.
+type klondikeMove struct {
+	From  klondikeSpot
+	To    klondikeSpot
+	Count int
+}
+
+func (m klondikeMove) String() string {
+	switch {
+	case m.From.Pile == pileStock:
+		return "draw"
+	case m.To.Pile == pileStock:
+		return "turn the waste over"
+	case m.Count > 1:
+		return fmt.Sprintf("%v to %v ( %d cards )", m.From, m.To, m.Count)
+	}
+	return fmt.Sprintf("%v to %v", m.From, m.To)
+}
+
+// oneByOne tells if the cards of the move go over one at a time, which reverses their order.
+// That's how the stock is drawn and turned over, everything else moves as one packet.
+func (m klondikeMove) oneByOne() bool {
+	return m.From.Pile == pileStock || m.To.Pile == pileStock
+}
+
+// ErrIllegalMove is returned by play for a move the rules don't allow.
+var ErrIllegalMove = errors.New("illegal move")
+
+// klondike is a game in progress.
+type klondike struct {
+	// draw is the number of cards drawn from the stock at a time, 1 or 3.
+	draw        int
+	stock       deck
+	waste       deck
+	foundations [4]deck
+	tableau     [klondikeColumns]deck
+	// hidden is the number of face down cards at the bottom of every column.
+	hidden  [klondikeColumns]int
+	history []klondikeUndo
+}
+
+// klondikeUndo is what undo needs to take a move back.
+type klondikeUndo struct {
+	move klondikeMove
+	// flipped is set when the move turned up the card left on top of a column.
+	flipped bool
+}
+
+// newKlondike deals a game from a new deck shuffled with the seed. The same seed and draw
+// always give the same game.
+func newKlondike(seed int64, draw int) (*klondike, error) {
+	d := newDeck()
+	d.shuffleSeed(seed)
+	return newKlondikeFromDeck(d, draw)
+}
+
+// newKlondikeFromDeck deals the deck row by row, the way people do: one card face up on the
+// first column and one face down on each of the others, then the next row from the second
+// column on, and so on. The 24 cards left are the stock, the top card of the deck drawn first.
+func newKlondikeFromDeck(d deck, draw int) (*klondike, error) {
+	if draw != 1 && draw != 3 {
+		return nil, fmt.Errorf("can draw 1 or 3 cards, not %d", draw)
+	}
+	if len(d) != 52 {
+		return nil, fmt.Errorf("klondike needs a deck of 52 cards, got %d", len(d))
+	}
+	if err := validateDeck(d); err != nil {
+		return nil, err
+	}
+	for _, card := range d {
+		if !card.valid() || card.isJoker() {
+			return nil, fmt.Errorf("%w: %v is not a card of the standard deck", ErrUnknownCard, card)
+		}
+	}
+
+	g := &klondike{draw: draw}
+	next := 0
+	for row := range klondikeColumns {
+		for col := row; col < klondikeColumns; col++ {
+			g.tableau[col] = append(g.tableau[col], d[next])
+			next++
+		}
+	}
+	for col := range klondikeColumns {
+		g.hidden[col] = col
+	}
+	g.stock = slices.Clone(d[next:])
+	slices.Reverse(g.stock)
+	return g, nil
+}
+
+func (g *klondike) pile(s klondikeSpot) *deck {
+	switch s.Pile {
+	case pileStock:
+		return &g.stock
+	case pileWaste:
+		return &g.waste
+	case pileFoundation:
+		return &g.foundations[s.Index]
+	}
+	return &g.tableau[s.Index]
+}
+
+// topCard returns the last card of the pile.
+func topCard(d deck) (Card, bool) {
+	if len(d) == 0 {
+		return Card{}, false
+	}
+	return d[len(d)-1], true
+}
+
+// stacks tells if card can go on onto on the tableau: one rank lower and the other colour.
+func stacks(card, onto Card) bool {
+	return card.Rank+1 == onto.Rank && card.Suit.red() != onto.Suit.red()
+}
+
+// accepts tells if the card is the next one of its foundation.
+func (g *klondike) accepts(card Card) bool {
+	return len(g.foundations[card.Suit]) == int(card.Rank)-1
+}
+
+// fits tells if a run starting with card can go on the column.
+func (g *klondike) fits(card Card, col int) bool {
+	top, ok := topCard(g.tableau[col])
+	if !ok {
+		return card.Rank == King
+	}
+	return stacks(card, top)
+}
+
+func foundationOf(card Card) klondikeSpot {
+	return klondikeSpot{Pile: pileFoundation, Index: int(card.Suit)}
+}
+
+func column(col int) klondikeSpot {
+	return klondikeSpot{Pile: pileTableau, Index: col}
+}
+
+var (
+	stockSpot = klondikeSpot{Pile: pileStock}
+	wasteSpot = klondikeSpot{Pile: pileWaste}
+)
+
+// legalMoves lists every move the rules allow, in no particular order of merit.
+func (g *klondike) legalMoves() []klondikeMove {
+	var moves []klondikeMove
+	if top, ok := topCard(g.waste); ok {
+		if g.accepts(top) {
+			moves = append(moves, klondikeMove{From: wasteSpot, To: foundationOf(top), Count: 1})
+		}
+		for to := range klondikeColumns {
+			if g.fits(top, to) {
+				moves = append(moves, klondikeMove{From: wasteSpot, To: column(to), Count: 1})
+			}
+		}
+	}
+
+	for from := range klondikeColumns {
+		cards := g.tableau[from]
+		if top, ok := topCard(cards); ok && g.accepts(top) {
+			moves = append(moves, klondikeMove{From: column(from), To: foundationOf(top), Count: 1})
+		}
+		// any face up card can be moved together with the cards on it
+		for i := g.hidden[from]; i < len(cards); i++ {
+			for to := range klondikeColumns {
+				if to != from && g.fits(cards[i], to) {
+					moves = append(moves, klondikeMove{From: column(from), To: column(to), Count: len(cards) - i})
+				}
+			}
+		}
+	}
+
+	for suit := range g.foundations {
+		if top, ok := topCard(g.foundations[suit]); ok {
+			for to := range klondikeColumns {
+				if g.fits(top, to) {
+					moves = append(moves, klondikeMove{From: klondikeSpot{Pile: pileFoundation, Index: suit}, To: column(to), Count: 1})
+				}
+			}
+		}
+	}
+
+	if len(g.stock) > 0 {
+		moves = append(moves, klondikeMove{From: stockSpot, To: wasteSpot, Count: min(g.draw, len(g.stock))})
+	} else if len(g.waste) > 0 {
+		moves = append(moves, klondikeMove{From: wasteSpot, To: stockSpot, Count: len(g.waste)})
+	}
+	return moves
+}
+
+// play makes the move when it's legal.
+func (g *klondike) play(m klondikeMove) error {
+	if !slices.Contains(g.legalMoves(), m) {
+		return fmt.Errorf("%w: %v", ErrIllegalMove, m)
+	}
+	g.apply(m)
+	return nil
+}
+
+// apply makes a move without checking it, for moves that come from legalMoves.
+func (g *klondike) apply(m klondikeMove) {
+	g.transfer(m.From, m.To, m.Count, m.oneByOne())
+	u := klondikeUndo{move: m}
+	if m.From.Pile == pileTableau {
+		col := m.From.Index
+		if n := len(g.tableau[col]); n > 0 && g.hidden[col] == n {
+			g.hidden[col]--
+			u.flipped = true
+		}
+	}
+	g.history = append(g.history, u)
+}
+
+// undo takes the last move back. It returns false when there is nothing to undo.
+func (g *klondike) undo() bool {
+	if len(g.history) == 0 {
+		return false
+	}
+	u := g.history[len(g.history)-1]
+	g.history = g.history[:len(g.history)-1]
+	if u.flipped {
+		g.hidden[u.move.From.Index]++
+	}
+	g.transfer(u.move.To, u.move.From, u.move.Count, u.move.oneByOne())
+	return true
+}
+
+// transfer moves n cards from the top of one pile to the other.
+func (g *klondike) transfer(from, to klondikeSpot, n int, oneByOne bool) {
+	src, dst := g.pile(from), g.pile(to)
+	cards := (*src)[len(*src)-n:]
+	if oneByOne {
+		for i := len(cards) - 1; i >= 0; i-- {
+			*dst = append(*dst, cards[i])
+		}
+	} else {
+		*dst = append(*dst, cards...)
+	}
+	*src = (*src)[:len(*src)-n]
+}
+
+// won tells if every card is on the foundations.
+func (g *klondike) won() bool {
+	for _, f := range g.foundations {
+		if len(f) != 13 {
+			return false
+		}
+	}
+	return true
+}
+
+// key is a compact description of the position - two games with the same key
+// have the same future. The order of the columns doesn't change what can be done with them,
+// so they're sorted: positions that only differ by where a run was moved are searched once.
+// The foundations are left out, they hold every card that isn't anywhere else.
+func (g *klondike) key() string {
+	encode := func(b []byte, cards deck) []byte {
+		for _, card := range cards {
+			b = append(b, byte(card.Suit)*13+byte(card.Rank))
+		}
+		return append(b, 0xFF)
+	}
+	var columns [klondikeColumns]string
+	for col := range klondikeColumns {
+		columns[col] = string(encode([]byte{byte(g.hidden[col])}, g.tableau[col]))
+	}
+	slices.Sort(columns[:])
+
+	b := encode(nil, g.stock)
+	b = encode(b, g.waste)
+	for _, c := range columns {
+		b = append(b, c...)
+	}
+	return string(b)
+}
+
+// print shows the table: the stock, the top of the waste, the foundations and the columns
+// with ## for face down cards.
+func (g *klondike) print(w io.Writer) {
+	waste := "--"
+	if n := len(g.waste); n > 0 {
+		var shown []string
+		for _, card := range g.waste[max(0, n-g.draw):] {
+			shown = append(shown, card.code())
+		}
+		waste = strings.Join(shown, " ")
+	}
+	fmt.Fprintf(w, "Stock %2d  Waste %s\n", len(g.stock), waste)
+
+	fmt.Fprint(w, "Foundations")
+	for _, f := range g.foundations {
+		if top, ok := topCard(f); ok {
+			fmt.Fprint(w, " ", top.code())
+		} else {
+			fmt.Fprint(w, " --")
+		}
+	}
+	fmt.Fprintln(w)
+
+	for col, cards := range g.tableau {
+		fmt.Fprintf(w, "%d:", col+1)
+		for i, card := range cards {
+			if i < g.hidden[col] {
+				fmt.Fprint(w, " ##")
+			} else {
+				fmt.Fprint(w, " ", card.code())
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// solveStatus is the answer of the solver.
+type solveStatus int
+
+const (
+	// solveWon means a winning line was found.
+	solveWon solveStatus = iota
+	// solveNoWin means every position the solver tries was searched without a win. The solver
+	// leaves some moves out ( see solveKlondike ), so a win that needs them can still exist.
+	solveNoWin
+	// solveUnknown means the budget ran out first.
+	solveUnknown
+)
+
+func (s solveStatus) String() string {
+	switch s {
+	case solveWon:
+		return "winnable"
+	case solveNoWin:
+		return "no win found"
+	case solveUnknown:
+		return "unknown"
+	}
+	return fmt.Sprintf("solveStatus(%d)", int(s))
+}
+
+// solveBudget limits the search. A zero field means no limit on it.
+type solveBudget struct {
+	Nodes int
+	Time  time.Duration
+}
+
+// solveResult is the outcome of solveKlondike. Moves is the winning line when the status is solveWon.
+type solveResult struct {
+	Status  solveStatus
+	Moves   []klondikeMove
+	Nodes   int
+	Elapsed time.Duration
+}
+
+// solveKlondike searches for a winning line from the current position, depth first and never
+// visiting a position twice. The game is left as it was found.
+//
+// To keep the search small it only tries moves that can lead somewhere: a card that can't
+// be needed on the tableau any more goes straight to its foundation, runs are only moved to
+// turn up a card, empty a column or free a card for a foundation, and cards never come back
+// off the foundations. That's why running out of moves is "no win found" and not "not winnable":
+// a deal that can only be won with one of the moves left out, like taking a card back off a
+// foundation, is missed.
+func solveKlondike(g *klondike, budget solveBudget) solveResult {
+	s := &klondikeSolver{g: g, seen: map[string]bool{}, budget: budget, start: time.Now()}
+	start := len(g.history)
+	won := s.search()
+
+	res := solveResult{Nodes: s.nodes, Elapsed: time.Since(s.start)}
+	switch {
+	case won:
+		res.Status = solveWon
+		for _, u := range g.history[start:] {
+			res.Moves = append(res.Moves, u.move)
+		}
+	case s.outOfBudget:
+		res.Status = solveUnknown
+	default:
+		res.Status = solveNoWin
+	}
+	for len(g.history) > start {
+		g.undo()
+	}
+	return res
+}
+
+type klondikeSolver struct {
+	g           *klondike
+	seen        map[string]bool
+	budget      solveBudget
+	start       time.Time
+	nodes       int
+	outOfBudget bool
+}
+
+func (s *klondikeSolver) search() bool {
+	if s.g.won() {
+		return true
+	}
+	if s.budget.Nodes > 0 && s.nodes >= s.budget.Nodes {
+		s.outOfBudget = true
+		return false
+	}
+	// looking at the clock is slow, do it every 1024 positions
+	if s.budget.Time > 0 && s.nodes%1024 == 0 && time.Since(s.start) > s.budget.Time {
+		s.outOfBudget = true
+		return false
+	}
+	key := s.g.key()
+	if s.seen[key] {
+		return false
+	}
+	s.seen[key] = true
+	s.nodes++
+
+	for _, m := range s.g.solverMoves() {
+		s.g.apply(m)
+		if s.search() {
+			return true
+		}
+		s.g.undo()
+		if s.outOfBudget {
+			return false
+		}
+	}
+	return false
+}
+
+// safeForFoundation tells if the card can't be useful on the tableau any more: nothing can
+// be put on an Ace or a Two, and any other card is only needed to hold the cards one rank lower
+// of the other colour - once both of those are on their foundations it can go too.
+func (g *klondike) safeForFoundation(card Card) bool {
+	if card.Rank <= Two {
+		return true
+	}
+	for suit, f := range g.foundations {
+		if Suit(suit).red() != card.Suit.red() && len(f) < int(card.Rank)-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// solverMoves are the moves worth searching, the most promising first ( see solveKlondike ).
+func (g *klondike) solverMoves() []klondikeMove {
+	var foundation, flips, others []klondikeMove
+	emptyTarget := -1
+	for col := range klondikeColumns {
+		if len(g.tableau[col]) == 0 {
+			emptyTarget = col
+			break
+		}
+	}
+
+	for _, m := range g.legalMoves() {
+		switch {
+		case m.To.Pile == pileFoundation:
+			card, _ := topCard(*g.pile(m.From))
+			if g.safeForFoundation(card) {
+				return []klondikeMove{m}
+			}
+			foundation = append(foundation, m)
+
+		case m.From.Pile == pileFoundation:
+			// never taken back
+
+		case m.From.Pile == pileTableau && m.To.Pile == pileTableau:
+			from := g.tableau[m.From.Index]
+			first := len(from) - m.Count
+			// all the empty columns are the same, only try the first one
+			if len(g.tableau[m.To.Index]) == 0 && m.To.Index != emptyTarget {
+				continue
+			}
+			switch {
+			case first == g.hidden[m.From.Index] && first > 0:
+				flips = append(flips, m)
+			case first == 0:
+				// moving a whole column to an empty one changes nothing
+				if len(g.tableau[m.To.Index]) > 0 {
+					others = append(others, m)
+				}
+			case first > g.hidden[m.From.Index] && g.accepts(from[first-1]):
+				others = append(others, m)
+			}
+
+		case m.To.Pile == pileTableau:
+			// a waste card to the tableau
+			others = append(others, m)
+
+		default:
+			// draw or turn the waste over, legalMoves lists them last
+			others = append(others, m)
+		}
+	}
+	return slices.Concat(foundation, flips, others)
+}
+
+// runKlondike is the "cards klondike" command: deal a game from a seed and look for a win.
+func runKlondike(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "klondike")
+	seed := fs.Int64("seed", 0, "seed for the deal, a random one when not set")
+	draw := fs.Int("draw", 1, "cards drawn from the stock at a time, 1 or 3")
+	nodes := fs.Int("nodes", 1000000, "stop the search after this many positions, 0 for no limit")
+	timeout := fs.Duration("timeout", 10*time.Second, "stop the search after this long, 0 for no limit")
+	showMoves := fs.Bool("moves", false, "list the moves of the win")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if !flagSet(fs, "seed") {
+		*seed = time.Now().UnixNano()
+	}
+	g, err := newKlondike(*seed, *draw)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+
+	fmt.Fprintf(env.stdout, "Klondike, draw %d, seed %d\n", *draw, *seed)
+	g.print(env.stdout)
+	res := solveKlondike(g, solveBudget{Nodes: *nodes, Time: *timeout})
+	fmt.Fprintf(env.stdout, "\n%v: %d positions searched in %v\n", res.Status, res.Nodes, res.Elapsed.Round(time.Millisecond))
+	if res.Status == solveWon {
+		fmt.Fprintf(env.stdout, "won in %d moves\n", len(res.Moves))
+		if *showMoves {
+			for i, m := range res.Moves {
+				fmt.Fprintf(env.stdout, "%3d. %v\n", i+1, m)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestNewKlondike(t *testing.T) {
+	g, err := newKlondike(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cards := len(g.stock)
+	for col := range klondikeColumns {
+		if len(g.tableau[col]) != col+1 || g.hidden[col] != col {
+			t.Errorf("Expected column %d to have %d cards with the top one face up, got %d with %d hidden",
+				col+1, col+1, len(g.tableau[col]), g.hidden[col])
+		}
+		cards += len(g.tableau[col])
+	}
+	if len(g.stock) != 24 || cards != 52 {
+		t.Errorf("Expected 24 cards in the stock and 52 on the table, got %v and %v", len(g.stock), cards)
+	}
+
+	// the same seed deals the same game
+	again, _ := newKlondike(1, 1)
+	if g.key() != again.key() {
+		t.Errorf("Expected the same seed to deal the same game")
+	}
+
+	if _, err := newKlondike(1, 2); err == nil {
+		t.Errorf("Expected an error for drawing 2 cards")
+	}
+	if _, err := newKlondikeFromDeck(newDeckWithJokers(), 1); err == nil {
+		t.Errorf("Expected an error for a deck with jokers")
+	}
+}
+
+func TestKlondikeDeal(t *testing.T) {
+	d := newDeck()
+	g, _ := newKlondikeFromDeck(d, 3)
+	// the first row goes across all the columns, the second starts on column 2
+	if g.tableau[0][0] != d[0] || g.tableau[6][0] != d[6] || g.tableau[1][1] != d[7] {
+		t.Errorf("Expected the cards to be dealt row by row")
+	}
+	if top, _ := topCard(g.stock); top != d[28] {
+		t.Errorf("Expected the card after the deal on top of the stock, got %v", top)
+	}
+}
+
+func TestKlondikeDrawThree(t *testing.T) {
+	g, _ := newKlondike(2, 3)
+	draw := klondikeMove{From: stockSpot, To: wasteSpot, Count: 3}
+	first, _ := topCard(g.stock)
+	if err := g.play(draw); err != nil {
+		t.Fatal(err)
+	}
+	// the third card drawn is the one that can be played
+	if len(g.waste) != 3 || g.waste[0] != first {
+		t.Errorf("Expected 3 cards on the waste with the first drawn at the bottom")
+	}
+	for range 7 {
+		g.play(draw)
+	}
+	if len(g.stock) != 0 || len(g.waste) != 24 {
+		t.Fatalf("Expected the whole stock on the waste, got %v and %v", len(g.stock), len(g.waste))
+	}
+	if err := g.play(draw); !errors.Is(err, ErrIllegalMove) {
+		t.Errorf("Expected ErrIllegalMove for drawing from an empty stock, got %v", err)
+	}
+	// turning the waste over gives the stock back in its first order
+	if err := g.play(klondikeMove{From: wasteSpot, To: stockSpot, Count: 24}); err != nil {
+		t.Fatal(err)
+	}
+	fresh, _ := newKlondike(2, 3)
+	if g.key() != fresh.key() {
+		t.Errorf("Expected the stock to be back as dealt")
+	}
+}
+
+func TestKlondikeLegalMoves(t *testing.T) {
+	// stack the deck: the Ace of Spades on column 1 and the Two of Spades on top of column 2
+	d := newDeck()
+	d[1], d[7] = d[7], d[1]
+	g, err := newKlondikeFromDeck(d, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.tableau[1][1] != (Card{Two, Spades}) {
+		t.Fatalf("Expected the Two of Spades face up on column 2, got %v", g.tableau[1][1])
+	}
+
+	toFoundation := klondikeMove{From: column(0), To: foundationOf(Card{Ace, Spades}), Count: 1}
+	if err := g.play(klondikeMove{From: column(1), To: foundationOf(Card{Two, Spades}), Count: 1}); !errors.Is(err, ErrIllegalMove) {
+		t.Errorf("Expected the Two before the Ace to be illegal, got %v", err)
+	}
+	if err := g.play(toFoundation); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.tableau[0]) != 0 {
+		t.Errorf("Expected column 1 to be empty")
+	}
+	if err := g.play(klondikeMove{From: column(1), To: foundationOf(Card{Two, Spades}), Count: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// the card under the Two was turned up
+	if g.hidden[1] != 0 {
+		t.Errorf("Expected the card under the Two to be face up")
+	}
+
+	// only Kings go to the empty column
+	for _, m := range g.legalMoves() {
+		if m.To == column(0) {
+			card := (*g.pile(m.From))[len(*g.pile(m.From))-m.Count]
+			if card.Rank != King {
+				t.Errorf("Expected only Kings on the empty column, got %v", card)
+			}
+		}
+		if m.From.Pile == pileTableau && m.To.Pile == pileTableau {
+			from := *g.pile(m.From)
+			card := from[len(from)-m.Count]
+			if top, ok := topCard(g.tableau[m.To.Index]); ok && !stacks(card, top) {
+				t.Errorf("Expected %v to stack on %v", card, top)
+			}
+		}
+	}
+}
+
+func TestKlondikeUndo(t *testing.T) {
+	g, _ := newKlondike(7, 1)
+	start := g.key()
+	hidden := g.hidden
+	r := rand.New(rand.NewSource(1))
+	keys := []string{start}
+	for range 300 {
+		moves := g.legalMoves()
+		g.apply(moves[r.Intn(len(moves))])
+		keys = append(keys, g.key())
+	}
+	for i := len(keys) - 2; i >= 0; i-- {
+		if !g.undo() {
+			t.Fatalf("Expected a move to undo")
+		}
+		if g.key() != keys[i] {
+			t.Fatalf("Expected undo to go back to position %d", i)
+		}
+	}
+	if g.undo() {
+		t.Errorf("Expected nothing left to undo")
+	}
+	if g.hidden != hidden {
+		t.Errorf("Expected the face down cards back as dealt, got %v", g.hidden)
+	}
+}
+
+func TestSolveKlondike(t *testing.T) {
+	g, _ := newKlondike(2, 1)
+	start := g.key()
+	res := solveKlondike(g, solveBudget{Nodes: 100000})
+	if res.Status != solveWon {
+		t.Fatalf("Expected seed 2 to be winnable, got %v", res.Status)
+	}
+	if g.key() != start || len(g.history) != 0 {
+		t.Errorf("Expected the solver to leave the game as it was")
+	}
+	// the moves win when played by the rules
+	for i, m := range res.Moves {
+		if err := g.play(m); err != nil {
+			t.Fatalf("move %d: %v", i+1, err)
+		}
+	}
+	if !g.won() {
+		t.Errorf("Expected the moves to win the game")
+	}
+
+	lost, _ := newKlondike(25, 3)
+	if res := solveKlondike(lost, solveBudget{Nodes: 100000}); res.Status != solveNoWin {
+		t.Errorf("Expected no win for seed 25 with draw 3, got %v", res.Status)
+	}
+
+	hard, _ := newKlondike(5, 1)
+	if res := solveKlondike(hard, solveBudget{Nodes: 100}); res.Status != solveUnknown || res.Nodes != 100 {
+		t.Errorf("Expected the search to stop after 100 positions, got %v after %v", res.Status, res.Nodes)
+	}
+}
+
+func TestKlondikeCommand(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "klondike", "-seed", "2", "-moves")
+	if code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+	if !strings.Contains(stdout, "winnable") || !strings.Contains(stdout, "  1. ") || !strings.Contains(stdout, "7: ## ## ## ## ## ##") {
+		t.Errorf("Expected the table, the answer and the moves, got\n%s", stdout)
+	}
+	if code, _, _ := runCLI(t, "", "klondike", "-draw", "2"); code != exitUsage {
+		t.Errorf("Expected a usage error for -draw 2, got %v", code)
+	}
+}
+
+// The solver is the heaviest user of the deck code: every position is a move, a key and an undo.
+func BenchmarkSolveKlondike(b *testing.B) {
+	for i := range b.N {
+		g, _ := newKlondike(int64(i%20), 1)
+		solveKlondike(g, solveBudget{Nodes: 20000})
+	}
+}
+
+func BenchmarkKlondikeMoves(b *testing.B) {
+	g, _ := newKlondike(1, 1)
+	for range b.N {
+		for _, m := range g.legalMoves() {
+			g.apply(m)
+			g.key()
+			g.undo()
+		}
+	}
+}
@@ -8,8 +8,11 @@
 
 package main
 
-func main() {
+import (
+	"os"
+)
 
+func main() {
 	// var card string = "Ace of Spades"
 	// the notation underneath is equivalent to the one above !
 	// card := "Ace of Spades" // := - this operator will do the typing association and its used the first time its initializing a variable with a type  !
@@ -56,7 +59,7 @@ func main() {
 	//fmt.Println(cards.toString())
 
 	// The deal function can be called without explicit imports because its part of the same package - main
-	// hand, remainingCards := deal(cards, 5)
+	// hand, remainingCards, err := deal(cards, 5)
 
 	// we can call the print() function on both of the following vars because they are of type deck
 	// hand.print()
@@ -67,8 +70,13 @@ func main() {
 	// cards.print()
 
 	// Generate a new deck and shuffle it
-	cards := newDeck()
-	cards.shuffle()
-	cards.print()
+	// cards := newDeck()
+	// cards.shuffle()
+	// cards.print()
+
+	// The program is now a command line tool with subcommands - see cli.go.
+	// os.Args[0] is the name of the program, the arguments start at os.Args[1]
+	// run returns the exit code and main is the only place that calls os.Exit.
+	os.Exit(run(os.Args[1:], cliEnv{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}))
 
 }
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The notations other card tools understand, next to the deck files of persist.go:
+//
+//	codes   - two characters a card, rank and suit: "Ah Kd Tc 2s" ( the jokers are BJ and RJ )
+//	pbn     - the deal of a bridge hand as in the Portable Bridge Notation: [Deal "N:AKQ.JT9.876.5432 ..."]
+//	history - a plain poker hand history, one block of lines for every hand:
+//
+//	Hand #1: Hold'em
+//	Seat 1: Alice [Ah Kd]
+//	Seat 2: Bob [7c 7s]
+//	Board [Qh Jh 2c 5d 9s]
+//
+// Every notation reads back what it writes, so decks and deals can go to other tools and come back.
+
+// ErrBadNotation is returned for text that doesn't follow the notation it's read as.
+var ErrBadNotation = errors.New("bad card notation")
+
+var notationNames = []string{"codes", "pbn", "history"}
+
+// formatCodes writes the cards as two character codes, the rank in capitals and the suit in
+// small letters like poker tools do: "Ah Kd Tc". Cards without a code ( see Card.code ) are refused.
+func formatCodes(d deck) (string, error) {
+	codes := make([]string, len(d))
+	for i, card := range d {
+		code := card.code()
+		if code == "??" {
+			return "", fmt.Errorf("%w: %v has no code", ErrBadNotation, card)
+		}
+		if card.Suit != noSuit {
+			code = code[:1] + strings.ToLower(code[1:])
+		}
+		codes[i] = code
+	}
+	return strings.Join(codes, " "), nil
+}
+
+// parseCodes is parseCompact - the codes are read in any case.
+func parseCodes(s string) (deck, error) {
+	d, err := parseCompact(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadNotation, err)
+	}
+	return d, nil
+}
+
+// pbnSuits is the order of the suits in a PBN hand.
+var pbnSuits = []Suit{Spades, Hearts, Diamonds, Clubs}
+
+// formatPBN writes the deal as the value of the PBN Deal tag, e.g. "N:AKQ.JT9.876.5432 - - -".
+// The cards of every suit are sorted from the Ace down, the way PBN wants them.
+func formatPBN(deal bridgeDeal) (string, error) {
+	var b strings.Builder
+	b.WriteString(deal.First.letter() + ":")
+	seat := deal.First
+	for i := range deal.Hands {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		hand := deal.Hands[seat]
+		seat = seat.next()
+		if len(hand) == 0 {
+			b.WriteByte('-')
+			continue
+		}
+		text, err := formatPBNHand(hand)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(text)
+	}
+	return b.String(), nil
+}
+
+// formatPBNHand writes one hand of a PBN deal: the suits in the order of pbnSuits separated by
+// dots, the cards of every suit from the Ace down, e.g. "AKT7.J6.T953.AJ3".
+func formatPBNHand(hand deck) (string, error) {
+	for _, card := range hand {
+		if !card.valid() || card.Suit == noSuit {
+			return "", fmt.Errorf("%w: %v isn't a bridge card", ErrBadNotation, card)
+		}
+	}
+	holdings := make([]string, len(pbnSuits))
+	for j, suit := range pbnSuits {
+		var ranks []Rank
+		for _, card := range hand {
+			if card.Suit == suit {
+				ranks = append(ranks, card.Rank)
+			}
+		}
+		slices.SortFunc(ranks, func(a, b Rank) int { return b.high() - a.high() })
+		for _, r := range ranks {
+			holdings[j] += Card{Rank: r, Suit: suit}.code()[:1]
+		}
+	}
+	return strings.Join(holdings, "."), nil
+}
+
+// parsePBN reads a deal written by formatPBN. The whole tag, [Deal "..."], is accepted too.
+// The hands come back sorted like formatPBN writes them, and no card can be in two hands.
+func parsePBN(s string) (bridgeDeal, error) {
+	var deal bridgeDeal
+	s = strings.TrimSpace(s)
+	if tag, ok := strings.CutPrefix(s, "["); ok {
+		value, ok := strings.CutPrefix(strings.TrimSpace(tag), "Deal ")
+		value = strings.TrimSpace(value)
+		if !ok || !strings.HasSuffix(value, `"]`) || !strings.HasPrefix(value, `"`) || len(value) < 3 {
+			return deal, fmt.Errorf("%w: expected [Deal \"...\"], got %q", ErrBadNotation, s)
+		}
+		s = value[1 : len(value)-2]
+	}
+
+	first, hands, ok := strings.Cut(s, ":")
+	seat, okSeat := parseBridgeSeat(first)
+	if !ok || !okSeat || len(first) != 1 {
+		return deal, fmt.Errorf("%w: a deal starts with the seat, e.g. N:, got %q", ErrBadNotation, s)
+	}
+	deal.First = seat
+	fields := strings.Fields(hands)
+	if len(fields) != 4 {
+		return deal, fmt.Errorf("%w: expected 4 hands, got %d", ErrBadNotation, len(fields))
+	}
+
+	seen := map[Card]bridgeSeat{}
+	for _, field := range fields {
+		hand, err := parsePBNHand(field)
+		if err != nil {
+			return deal, fmt.Errorf("%v hand: %w", seat, err)
+		}
+		for _, card := range hand {
+			if other, ok := seen[card]; ok {
+				return deal, fmt.Errorf("%w: %v in the hands of %v and %v", ErrDuplicateCard, card, other, seat)
+			}
+			seen[card] = seat
+		}
+		deal.Hands[seat] = hand
+		seat = seat.next()
+	}
+	return deal, nil
+}
+
+func parsePBNHand(s string) (deck, error) {
+	if s == "-" {
+		return nil, nil
+	}
+	holdings := strings.Split(s, ".")
+	if len(holdings) != len(pbnSuits) {
+		return nil, fmt.Errorf("%w: expected 4 suits separated by dots, got %q", ErrBadNotation, s)
+	}
+	var hand deck
+	for i, holding := range holdings {
+		last := 15
+		for _, ch := range strings.ToUpper(holding) {
+			r := strings.IndexRune("A23456789TJQK", ch)
+			if r < 0 {
+				return nil, fmt.Errorf("%w: unknown rank %q in %q", ErrBadNotation, ch, s)
+			}
+			card := Card{Rank: Rank(r + 1), Suit: pbnSuits[i]}
+			if card.Rank.high() >= last {
+				return nil, fmt.Errorf("%w: the %v are not from the Ace down in %q", ErrBadNotation, pbnSuits[i], s)
+			}
+			last = card.Rank.high()
+			hand = append(hand, card)
+		}
+	}
+	if len(hand) > 13 {
+		return nil, fmt.Errorf("%w: %d cards in %q", ErrBadNotation, len(hand), s)
+	}
+	return hand, nil
+}
+
+// handHistory is one hand of a poker hand history.
+type handHistory struct {
+	ID int
+	// Game is the name of the game, e.g. "Hold'em".
+	Game  string
+	Seats []historySeat
+	Board deck
+}
+
+// historySeat is a player of the hand. Cards is empty when they weren't shown.
+type historySeat struct {
+	Seat  int
+	Name  string
+	Cards deck
+}
+
+// cards are the cards of the hand, the seats in order and then the board.
+func (h handHistory) cards() deck {
+	var d deck
+	for _, s := range h.Seats {
+		d = append(d, s.Cards...)
+	}
+	return append(d, h.Board...)
+}
+
+// writeHandHistories writes the hands, a blank line between them.
+func writeHandHistories(w io.Writer, hands []handHistory) error {
+	bw := bufio.NewWriter(w)
+	for i, h := range hands {
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "Hand #%d: %s\n", h.ID, h.Game)
+		for _, s := range h.Seats {
+			fmt.Fprintf(bw, "Seat %d: %s", s.Seat, s.Name)
+			if len(s.Cards) > 0 {
+				codes, err := formatCodes(s.Cards)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(bw, " [%s]", codes)
+			}
+			fmt.Fprintln(bw)
+		}
+		if len(h.Board) > 0 {
+			codes, err := formatCodes(h.Board)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(bw, "Board [%s]\n", codes)
+		}
+	}
+	return bw.Flush()
+}
+
+// readHandHistories reads what writeHandHistories writes. Blank lines separate the hands.
+// A card can only be dealt once in a hand.
+func readHandHistories(r io.Reader) ([]handHistory, error) {
+	var hands []handHistory
+	var h *handHistory
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		bad := func(format string, args ...any) error {
+			return fmt.Errorf("%w: line %d: %s", ErrBadNotation, line, fmt.Sprintf(format, args...))
+		}
+		switch {
+		case text == "":
+			h = nil
+
+		case strings.HasPrefix(text, "Hand #"):
+			if h != nil {
+				return nil, bad("a new hand without a blank line before it")
+			}
+			id, game, _ := strings.Cut(strings.TrimPrefix(text, "Hand #"), ":")
+			n, err := strconv.Atoi(id)
+			if err != nil {
+				return nil, bad("bad hand number %q", id)
+			}
+			hands = append(hands, handHistory{ID: n, Game: strings.TrimSpace(game)})
+			h = &hands[len(hands)-1]
+
+		case h == nil:
+			return nil, bad("expected Hand #, got %q", text)
+
+		case strings.HasPrefix(text, "Seat "):
+			number, player, ok := strings.Cut(strings.TrimPrefix(text, "Seat "), ":")
+			n, err := strconv.Atoi(number)
+			if !ok || err != nil {
+				return nil, bad("expected Seat <number>: <name>, got %q", text)
+			}
+			if len(h.Board) > 0 {
+				return nil, bad("seat %d after the board", n)
+			}
+			s := historySeat{Seat: n, Name: strings.TrimSpace(player)}
+			if name, codes, ok := cutBrackets(s.Name); ok {
+				s.Name = name
+				if s.Cards, err = parseCodes(codes); err != nil {
+					return nil, bad("seat %d: %v", n, err)
+				}
+			}
+			if s.Name == "" {
+				return nil, bad("seat %d has no name", n)
+			}
+			h.Seats = append(h.Seats, s)
+
+		case strings.HasPrefix(text, "Board"):
+			rest, codes, ok := cutBrackets(strings.TrimPrefix(text, "Board"))
+			if !ok || rest != "" || len(h.Board) > 0 {
+				return nil, bad("expected one Board [cards], got %q", text)
+			}
+			board, err := parseCodes(codes)
+			if err != nil {
+				return nil, bad("board: %v", err)
+			}
+			h.Board = board
+
+		default:
+			return nil, bad("unexpected %q", text)
+		}
+
+		if h != nil {
+			if err := validateDeck(h.cards()); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return hands, nil
+}
+
+// cutBrackets splits "Alice [Ah Kd]" into "Alice" and "Ah Kd".
+func cutBrackets(s string) (before, inside string, found bool) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndex(s, "[")
+	if i < 0 || !strings.HasSuffix(s, "]") {
+		return s, "", false
+	}
+	return strings.TrimSpace(s[:i]), s[i+1 : len(s)-1], true
+}
+
+// dealHoldem deals a hand of hold'em from the dealer: two cards to every player and the five
+// cards of the board, burning a card before the flop, the turn and the river.
+func dealHoldem(dl *dealer, id, players int) (handHistory, error) {
+	h := handHistory{ID: id, Game: "Hold'em"}
+	hands, err := dl.dealHands(players, 2, dealRoundRobin)
+	if err != nil {
+		return h, err
+	}
+	for _, deal := range []func() (deck, error){dl.flop, dl.turn, dl.river} {
+		cards, err := deal()
+		if err != nil {
+			return h, err
+		}
+		h.Board = append(h.Board, cards...)
+	}
+	for i, hand := range hands {
+		h.Seats = append(h.Seats, historySeat{Seat: i + 1, Name: fmt.Sprintf("Player %d", i+1), Cards: hand})
+	}
+	return h, nil
+}
+
+// runExport is the "cards export" command: it writes a deck, or the deal of it, in a notation of other tools.
+func runExport(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "export")
+	notation := fs.String("notation", "codes", "the notation: "+strings.Join(notationNames, ", "))
+	in := fs.String("in", "", "export the deck in this file instead of a new shuffled deck")
+	seed := fs.Int64("seed", 0, "seed for shuffling the new deck, a random one when not set")
+	dealerSeat := fs.String("dealer", "N", "pbn: the seat of the dealer, the first to get a card is on the left")
+	players := fs.Int("players", 2, "history: the number of players")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var dl *dealer
+	if *in != "" {
+		d, err := LoadDeck(*in)
+		if err != nil {
+			return err
+		}
+		dl = newDealer(d)
+	} else {
+		if !flagSet(fs, "seed") {
+			*seed = time.Now().UnixNano()
+		}
+		dl = newSeededDealer(*seed)
+	}
+
+	switch *notation {
+	case "codes":
+		codes, err := formatCodes(dl.cards)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(env.stdout, codes)
+		return nil
+
+	case "pbn":
+		first, ok := parseBridgeSeat(*dealerSeat)
+		if !ok {
+			return usagef("unknown seat %q, expected N, E, S or W", *dealerSeat)
+		}
+		deal, err := dealBridge(dl, first)
+		if err != nil {
+			return err
+		}
+		value, err := formatPBN(deal)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(env.stdout, "[Dealer %q]\n[Deal %q]\n", first.letter(), value)
+		return nil
+
+	case "history":
+		if *players < 2 || *players > 23 {
+			return usagef("hold'em is played by 2 to 23 players, got %d", *players)
+		}
+		h, err := dealHoldem(dl, 1, *players)
+		if err != nil {
+			return err
+		}
+		return writeHandHistories(env.stdout, []handHistory{h})
+	}
+	return usagef("unknown notation %q, expected %s", *notation, strings.Join(notationNames, ", "))
+}
+
+// runImport is the "cards import" command: it reads a notation of other tools back into a deck.
+func runImport(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "import")
+	notation := fs.String("notation", "codes", "the notation: "+strings.Join(notationNames, ", "))
+	hand := fs.Int("hand", 1, "history: the hand to import, counting from 1")
+	out := fs.String("out", "", "save the deck to this file instead of showing it")
+	format := fs.String("format", "text", "how to show the deck: text, table, json, compact, glyph, box or ascii")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return usagef("expected at most one file, the standard input when none")
+	}
+	var r io.Reader = env.stdin
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var d deck
+	switch *notation {
+	case "codes", "pbn":
+		bs, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if *notation == "codes" {
+			d, err = parseCodes(string(bs))
+		} else {
+			var deal bridgeDeal
+			deal, err = parsePBN(pbnDealTag(string(bs)))
+			d = deal.cards()
+		}
+		if err != nil {
+			return err
+		}
+
+	case "history":
+		hands, err := readHandHistories(r)
+		if err != nil {
+			return err
+		}
+		if *hand < 1 || *hand > len(hands) {
+			return usagef("no hand %d, the history has %d", *hand, len(hands))
+		}
+		d = hands[*hand-1].cards()
+
+	default:
+		return usagef("unknown notation %q, expected %s", *notation, strings.Join(notationNames, ", "))
+	}
+
+	if *out != "" {
+		return SaveDeckInfo(*out, d, deckInfo{Created: time.Now()})
+	}
+	return showDeck(env.stdout, d, *format)
+}
+
+// pbnDealTag finds the Deal tag in a PBN file, the other tags ( [Dealer "N"], ... ) are skipped.
+// Text without tags is returned as it is.
+func pbnDealTag(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "[Deal ") {
+			return line
+		}
+	}
+	return s
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCodesRoundTrip(t *testing.T) {
+	d := newDeckWithJokers()
+	d.shuffleSeed(4)
+	text, err := formatCodes(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := parseCodes(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.toString() != d.toString() {
+		t.Errorf("Expected the same deck back, got %v", back.toString())
+	}
+
+	if text, _ := formatCodes(cardsFromCodes(t, "Ah Kd Tc")); text != "Ah Kd Tc" {
+		t.Errorf("Expected Ah Kd Tc, got %q", text)
+	}
+	cs, err := lookupCardSet("uno")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := formatCodes(cs.newDeck()[:1]); !errors.Is(err, ErrBadNotation) {
+		t.Errorf("Expected an Uno card to have no code, got %v", err)
+	}
+	if _, err := parseCodes("Ah Kx"); !errors.Is(err, ErrBadNotation) || !errors.Is(err, ErrUnknownCard) {
+		t.Errorf("Expected a bad notation, got %v", err)
+	}
+}
+
+func TestPBNRoundTrip(t *testing.T) {
+	const s = "E:AKT7.J6.T953.AJ3 J53.T982.74.T965 - Q862.KQ3.AKJ.Q42"
+	deal, err := parsePBN(`[Deal "` + s + `"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deal.First != east || len(deal.Hands[east]) != 13 || deal.Hands[west] != nil {
+		t.Errorf("Expected East first and West unknown, got %+v", deal)
+	}
+	// the hand after East is South's
+	if got := deal.Hands[south][0]; got != (Card{Jack, Spades}) {
+		t.Errorf("Expected South to hold the Jack of Spades first, got %v", got)
+	}
+	if back, err := formatPBN(deal); err != nil || back != s {
+		t.Errorf("Expected %q back, got %q ( %v )", s, back, err)
+	}
+
+	// a deal of a shuffled deck comes back sorted
+	d := newDeck()
+	d.shuffleSeed(8)
+	dealt := bridgeDeal{First: west, Hands: [4]deck{d[:13], d[13:26], d[26:39], d[39:]}}
+	text, err := formatPBN(dealt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := parsePBN(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seat := range back.Hands {
+		if !sameCards(back.Hands[seat], dealt.Hands[seat]) {
+			t.Errorf("Expected the same cards for %v, got %v", bridgeSeat(seat), back.Hands[seat])
+		}
+	}
+}
+
+func TestParsePBNErrors(t *testing.T) {
+	tests := []struct {
+		s    string
+		want error
+	}{
+		{"X:AKT7.J6.T953.AJ3 - - -", ErrBadNotation},
+		{"N:AKT7.J6.T953.AJ3 - -", ErrBadNotation},
+		{"N:AKT7.J6.T953 - - -", ErrBadNotation},
+		{"N:KAT7.J6.T953.AJ3 - - -", ErrBadNotation},
+		{"N:AKT7.J6.T953.A1 - - -", ErrBadNotation},
+		{"N:AKQJT98765432.A.. - - -", ErrBadNotation},
+		{"N:AKT7.J6.T953.AJ3 A... - -", ErrDuplicateCard},
+		{`[Deal N:- - - -]`, ErrBadNotation},
+	}
+	for _, tt := range tests {
+		if _, err := parsePBN(tt.s); !errors.Is(err, tt.want) {
+			t.Errorf("Expected %v for %q, got %v", tt.want, tt.s, err)
+		}
+	}
+}
+
+const histories = `Hand #7: Hold'em
+Seat 1: Alice [Ah Kd]
+Seat 3: Bob Jr [7c 7s]
+Seat 4: Carol
+Board [Qh Jh 2c 5d 9s]
+
+Hand #8: Hold'em
+Seat 1: Alice [2d 2h]
+Seat 3: Bob Jr [As Ks]
+`
+
+func TestHandHistoryRoundTrip(t *testing.T) {
+	hands, err := readHandHistories(strings.NewReader(histories))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hands) != 2 || hands[0].ID != 7 || hands[0].Seats[1].Name != "Bob Jr" || hands[0].Seats[2].Cards != nil || len(hands[1].Board) != 0 {
+		t.Fatalf("Expected 2 hands, got %+v", hands)
+	}
+	if got := hands[0].cards(); !sameCards(got, cardsFromCodes(t, "Ah Kd 7c 7s Qh Jh 2c 5d 9s")) {
+		t.Errorf("Expected the cards of the first hand, got %v", got)
+	}
+	var buf bytes.Buffer
+	if err := writeHandHistories(&buf, hands); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != histories {
+		t.Errorf("Expected the same history back, got\n%s", buf.String())
+	}
+}
+
+func TestReadHandHistoryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want error
+	}{
+		{"no hand", "Seat 1: Alice [Ah Kd]\n", ErrBadNotation},
+		{"bad number", "Hand #x: Hold'em\n", ErrBadNotation},
+		{"no blank line", "Hand #1: Hold'em\nHand #2: Hold'em\n", ErrBadNotation},
+		{"bad card", "Hand #1: Hold'em\nSeat 1: Alice [Ah Kx]\n", ErrBadNotation},
+		{"no name", "Hand #1: Hold'em\nSeat 1: [Ah Kd]\n", ErrBadNotation},
+		{"two boards", "Hand #1: Hold'em\nBoard [2c 3c 4c]\nBoard [5c]\n", ErrBadNotation},
+		{"seat after board", "Hand #1: Hold'em\nBoard [2c 3c 4c]\nSeat 1: Alice\n", ErrBadNotation},
+		{"unknown line", "Hand #1: Hold'em\nAlice raises 10\n", ErrBadNotation},
+		{"card dealt twice", "Hand #1: Hold'em\nSeat 1: Alice [Ah Kd]\nBoard [Qh Ah 2c]\n", ErrDuplicateCard},
+	}
+	for _, tt := range tests {
+		if _, err := readHandHistories(strings.NewReader(tt.s)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestExportImportCommands(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "export", "-notation", "pbn", "-seed", "3", "-dealer", "S")
+	if code != exitOK || !strings.HasPrefix(stdout, "[Dealer \"S\"]\n[Deal \"S:") {
+		t.Fatalf("Expected a PBN deal, got %v ( %s%s )", code, stdout, stderr)
+	}
+	// West is on the left of South and gets the first card
+	first := newSeededDealer(3).cards[0]
+	code, imported, _ := runCLI(t, stdout, "import", "-notation", "pbn", "-format", "compact")
+	deal, _ := parsePBN(pbnDealTag(stdout))
+	if code != exitOK || len(strings.Fields(imported)) != 52 || !slices.Contains(deal.Hands[west], first) {
+		t.Errorf("Expected the 52 cards of the deal, got %v ( %s )", code, imported)
+	}
+
+	code, stdout, _ = runCLI(t, "", "export", "-notation", "history", "-seed", "3", "-players", "3")
+	if code != exitOK || strings.Count(stdout, "Seat ") != 3 || !strings.Contains(stdout, "Board [") {
+		t.Fatalf("Expected a hand of 3 players, got %v ( %s )", code, stdout)
+	}
+	out := filepath.Join(t.TempDir(), "hand.json")
+	if code, _, stderr := runCLI(t, stdout, "import", "-notation", "history", "-out", out); code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+	if d, err := LoadDeck(out); err != nil || len(d) != 11 {
+		t.Errorf("Expected 6 cards in the hands and 5 on the board, got %v ( %v )", d, err)
+	}
+
+	code, stdout, _ = runCLI(t, "", "export", "-seed", "3")
+	if want, _ := formatCodes(newSeededDealer(3).cards); code != exitOK || stdout != want+"\n" {
+		t.Errorf("Expected the codes of the shuffled deck, got %v ( %s )", code, stdout)
+	}
+	if code, _, _ := runCLI(t, "", "export", "-notation", "morse"); code != exitUsage {
+		t.Errorf("Expected a usage error for an unknown notation, got %v", code)
+	}
+	if code, _, _ := runCLI(t, "Ah Ah", "import", "-out", filepath.Join(t.TempDir(), "d.json")); code != exitError {
+		t.Errorf("Expected a duplicate card to be refused, got %v", code)
+	}
+}
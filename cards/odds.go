@@ -0,0 +1,492 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// Exact odds for teaching and game balancing. Every number here can also be simulated with a
+// seeded random generator, as a check that the maths is right ( and that the deck is ).
+
+// cardMatch picks the cards an odds question is about, e.g. the Aces or the Hearts.
+type cardMatch func(Card) bool
+
+// parseCardMatch parses comma separated ranks, suits or card names, e.g. "Ace,King",
+// "Hearts" or "Queen of Spades,Jack of Diamonds". A card matches when any of them does.
+func parseCardMatch(s string) (cardMatch, error) {
+	var ranks []Rank
+	var suits []Suit
+	var cards deck
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if r, ok := lookupRank(name); ok {
+			ranks = append(ranks, r)
+		} else if st, ok := lookupSuit(name); ok {
+			suits = append(suits, st)
+		} else if card, err := parseCard(name); err == nil {
+			cards = append(cards, card)
+		} else {
+			return nil, fmt.Errorf("%q is not a rank, a suit or a card", name)
+		}
+	}
+	return func(c Card) bool {
+		return slices.Contains(ranks, c.Rank) || slices.Contains(suits, c.Suit) || slices.Contains(cards, c)
+	}, nil
+}
+
+// countMatching is the number of cards of d that match.
+func countMatching(d deck, match cardMatch) int {
+	n := 0
+	for _, card := range d {
+		if match(card) {
+			n++
+		}
+	}
+	return n
+}
+
+// hypergeometric is the chance of exactly k successes when draws cards are taken
+// ( without putting them back ) from population cards of which successes are wanted.
+func hypergeometric(population, successes, draws, k int) float64 {
+	total := combinations(population, draws)
+	if total == 0 {
+		return 0
+	}
+	return combinations(successes, k) * combinations(population-successes, draws-k) / total
+}
+
+// drawOdds is the chance of drawing every number of matching cards, from none to Draws.
+type drawOdds struct {
+	Population int
+	Matching   int
+	Draws      int
+	// Exactly[k] is the chance of exactly k matching cards.
+	Exactly []float64
+	// Trials is the number of simulated draws, 0 when the odds are exact.
+	Trials int
+}
+
+// atLeast is the chance of k or more matching cards.
+func (o drawOdds) atLeast(k int) float64 {
+	p := 0.0
+	for i := max(k, 0); i < len(o.Exactly); i++ {
+		p += o.Exactly[i]
+	}
+	return p
+}
+
+// margin is the half width of the 95% confidence interval of a simulated chance p ( 0 when exact ).
+func (o drawOdds) margin(p float64) float64 {
+	return binomialMargin(p, o.Trials)
+}
+
+// binomialMargin is the half width of the 95% confidence interval of a share p out of trials.
+func binomialMargin(p float64, trials int) float64 {
+	if trials == 0 {
+		return 0
+	}
+	return 1.96 * math.Sqrt(p*(1-p)/float64(trials))
+}
+
+// calculateDrawOdds works out the exact odds of drawing matching cards from the top of a shuffled d.
+func calculateDrawOdds(d deck, match cardMatch, draws int) (drawOdds, error) {
+	if draws < 0 || draws > len(d) {
+		return drawOdds{}, &InsufficientCardsError{Need: draws, Have: len(d)}
+	}
+	o := drawOdds{Population: len(d), Matching: countMatching(d, match), Draws: draws, Exactly: make([]float64, draws+1)}
+	for k := range o.Exactly {
+		o.Exactly[k] = hypergeometric(o.Population, o.Matching, draws, k)
+	}
+	return o, nil
+}
+
+// simulateDrawOdds is calculateDrawOdds by shuffling: it draws trials random hands and counts.
+func simulateDrawOdds(d deck, match cardMatch, draws, trials int, seed int64) (drawOdds, error) {
+	if draws < 0 || draws > len(d) {
+		return drawOdds{}, &InsufficientCardsError{Need: draws, Have: len(d)}
+	}
+	if trials <= 0 {
+		return drawOdds{}, fmt.Errorf("need at least 1 trial, got %d", trials)
+	}
+	o := drawOdds{Population: len(d), Matching: countMatching(d, match), Draws: draws, Exactly: make([]float64, draws+1), Trials: trials}
+	r := rand.New(rand.NewSource(seed))
+	pool := slices.Clone(d)
+	for range trials {
+		// a partial Fisher-Yates shuffle - only the first draws cards are needed
+		k := 0
+		for i := range draws {
+			j := i + r.Intn(len(pool)-i)
+			pool[i], pool[j] = pool[j], pool[i]
+			if match(pool[i]) {
+				k++
+			}
+		}
+		o.Exactly[k]++
+	}
+	for k := range o.Exactly {
+		o.Exactly[k] /= float64(trials)
+	}
+	return o, nil
+}
+
+// outsResult is what can still come to a hold'em hand on the flop or the turn.
+type outsResult struct {
+	Current handValue
+	Target  handCategory
+	// Outs are the unseen cards that make the hand at least Target on the next card.
+	Outs deck
+	// Unseen is the number of cards the next one can be, everything but the hand and the board.
+	Unseen int
+	// ToCome is the number of board cards still to come.
+	ToCome int
+}
+
+// nextCard is the chance of hitting an out with the next card.
+func (o outsResult) nextCard() float64 {
+	return hypergeometric(o.Unseen, len(o.Outs), 1, 1)
+}
+
+// byRiver is the chance of hitting at least one out with all the cards to come. Like the
+// counting at the table it only knows the outs of one card, hands that need two new cards
+// ( runner-runner ) are not counted.
+func (o outsResult) byRiver() float64 {
+	return 1 - hypergeometric(o.Unseen, len(o.Outs), o.ToCome, 0)
+}
+
+// findOuts finds the cards that would make the hand at least target. The other players'
+// cards aren't known, so every card but the hand and the board is counted as unseen.
+func findOuts(hand, board deck, target handCategory) (outsResult, error) {
+	if len(hand) != 2 {
+		return outsResult{}, fmt.Errorf("need 2 hole cards, got %d", len(hand))
+	}
+	if len(board) != 3 && len(board) != 4 {
+		return outsResult{}, fmt.Errorf("outs need the flop or the turn ( 3 or 4 board cards ), got %d", len(board))
+	}
+	known := slices.Concat(hand, board)
+	current, err := evaluateHand(known)
+	if err != nil {
+		return outsResult{}, err
+	}
+
+	o := outsResult{Current: current, Target: target, ToCome: 5 - len(board)}
+	cards := append(slices.Clip(known), Card{})
+	for _, card := range newDeck() {
+		if slices.Contains(known, card) {
+			continue
+		}
+		o.Unseen++
+		cards[len(known)] = card
+		if bestFive(cards).Category >= target {
+			o.Outs = append(o.Outs, card)
+		}
+	}
+	return o, nil
+}
+
+// categoryOdds counts the hand categories over many hands.
+type categoryOdds struct {
+	Counts [royalFlush + 1]int
+	Hands  int
+	// Exact is true when every possible hand was counted instead of a random sample.
+	Exact bool
+}
+
+func (c categoryOdds) probability(cat handCategory) float64 {
+	if c.Hands == 0 {
+		return 0
+	}
+	return float64(c.Counts[cat]) / float64(c.Hands)
+}
+
+// margin is the half width of the 95% confidence interval of the category ( 0 when exact ).
+func (c categoryOdds) margin(cat handCategory) float64 {
+	if c.Exact {
+		return 0
+	}
+	return binomialMargin(c.probability(cat), c.Hands)
+}
+
+// categoryConfig describes a hand category distribution: Size cards of which Known are already held.
+type categoryConfig struct {
+	// Known are the cards already in the hand, none for the odds of a fresh deal.
+	Known deck
+	// Size is the number of cards in the hand, 5 to 7 ( the best 5 count ). 5 when 0.
+	Size int
+	// Trials is the number of random hands when the hands are simulated, 1,000,000 when 0.
+	Trials int
+	Seed   int64
+	// ExactLimit is the most hands that are enumerated instead of simulated, 3,000,000 when 0 -
+	// enough for every 5 card poker hand. Set it to -1 to always simulate.
+	ExactLimit int
+}
+
+const (
+	defaultCategoryTrials     = 1000000
+	defaultCategoryExactLimit = 3000000
+)
+
+// calculateCategoryOdds finds out how often every hand category comes out of a 52 card deck.
+// Like calculateEquity it counts every possible hand when there are few enough, and deals
+// random hands otherwise.
+func calculateCategoryOdds(cfg categoryConfig) (categoryOdds, error) {
+	size := cfg.Size
+	if size == 0 {
+		size = 5
+	}
+	if size < 5 || size > 7 {
+		return categoryOdds{}, fmt.Errorf("a poker hand needs 5 to 7 cards, got %d", size)
+	}
+	if len(cfg.Known) > size {
+		return categoryOdds{}, fmt.Errorf("%d known cards don't fit in a hand of %d", len(cfg.Known), size)
+	}
+	if err := validateDeck(cfg.Known); err != nil {
+		return categoryOdds{}, err
+	}
+	remaining := deck{}
+	for _, card := range newDeck() {
+		if !slices.Contains(cfg.Known, card) {
+			remaining = append(remaining, card)
+		}
+	}
+	if len(remaining)+len(cfg.Known) != 52 {
+		return categoryOdds{}, fmt.Errorf("%w: only the 52 standard cards make poker hands", ErrUnknownCard)
+	}
+
+	// the hands are scored as card masks ( see cardmask.go ), millions of them without an allocation
+	missing := size - len(cfg.Known)
+	known, err := maskOf(cfg.Known)
+	if err != nil {
+		return categoryOdds{}, err
+	}
+	masks := make([]cardMask, len(remaining))
+	for i, card := range remaining {
+		masks[i] = maskOfCard(card)
+	}
+	var c categoryOdds
+
+	limit := cfg.ExactLimit
+	if limit == 0 {
+		limit = defaultCategoryExactLimit
+	}
+	if combinations(len(remaining), missing) <= float64(limit) {
+		c.Exact = true
+		forEachCombination(len(remaining), missing, func(indexes []int) {
+			hand := known
+			for _, idx := range indexes {
+				hand |= masks[idx]
+			}
+			c.Counts[maskValue(hand).Category]++
+			c.Hands++
+		})
+		return c, nil
+	}
+
+	trials := cfg.Trials
+	if trials <= 0 {
+		trials = defaultCategoryTrials
+	}
+	r := rand.New(rand.NewSource(cfg.Seed))
+	for range trials {
+		hand := known
+		for i := range missing {
+			j := i + r.Intn(len(masks)-i)
+			masks[i], masks[j] = masks[j], masks[i]
+			hand |= masks[i]
+		}
+		c.Counts[maskValue(hand).Category]++
+		c.Hands++
+	}
+	return c, nil
+}
+
+// forEachCombination calls fn with every k of the indexes 0 to n-1, always increasing.
+// fn must not keep the slice, it's reused for the next combination.
+func forEachCombination(n, k int, fn func(indexes []int)) {
+	if k < 0 || k > n {
+		return
+	}
+	indexes := make([]int, k)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	for {
+		fn(indexes)
+
+		// move to the next combination: find the last index that can still grow
+		i := k - 1
+		for i >= 0 && indexes[i] == n-k+i {
+			i--
+		}
+		if i < 0 {
+			return
+		}
+		indexes[i]++
+		for j := i + 1; j < k; j++ {
+			indexes[j] = indexes[j-1] + 1
+		}
+	}
+}
+
+// parseHandCategory finds a category by its name, e.g. "full house". Case doesn't matter.
+func parseHandCategory(name string) (handCategory, error) {
+	for i, n := range handCategoryNames {
+		if strings.EqualFold(n, strings.TrimSpace(name)) {
+			return handCategory(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown hand category %q, expected one of %s", name, strings.Join(handCategoryNames, ", "))
+}
+
+// percent formats a chance with its margin when it has one, e.g. "12.34%" or "12.30% ± 0.20%".
+func percent(p, margin float64) string {
+	if margin == 0 {
+		return fmt.Sprintf("%.4f%%", 100*p)
+	}
+	return fmt.Sprintf("%.4f%% ± %.4f%%", 100*p, 100*margin)
+}
+
+// runOdds is the "cards odds" command: the chance of drawing matching cards.
+func runOdds(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "odds")
+	matchFlag := fs.String("match", "", `the cards to count: ranks, suits or cards, e.g. "Ace,King" or "Hearts"`)
+	draws := fs.Int("draw", 5, "number of cards drawn")
+	set := fs.String("set", "standard", "card set of the deck")
+	sim := fs.Int("sim", 0, "also simulate this many draws, as a check")
+	seed := fs.Int64("seed", 1, "seed of the simulation")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *matchFlag == "" {
+		return usagef(`-match is needed, e.g. cards odds -match Ace -draw 5`)
+	}
+	match, err := parseCardMatch(*matchFlag)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+	cs, err := lookupCardSet(*set)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+	d := cs.newDeck()
+	exact, err := calculateDrawOdds(d, match, *draws)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+	var simulated drawOdds
+	if *sim > 0 {
+		if simulated, err = simulateDrawOdds(d, match, *draws, *sim, *seed); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(env.stdout, "%s in %d cards from %d ( %d of them ):\n", *matchFlag, *draws, exact.Population, exact.Matching)
+	tw := tabwriter.NewWriter(env.stdout, 0, 0, 2, ' ', 0)
+	row := func(label string, exactP, simP float64) {
+		fmt.Fprintf(tw, "  %s\t%s", label, percent(exactP, 0))
+		if *sim > 0 {
+			fmt.Fprintf(tw, "\tsimulated %s", percent(simP, simulated.margin(simP)))
+		}
+		fmt.Fprintln(tw)
+	}
+	for k, p := range exact.Exactly {
+		simP := 0.0
+		if *sim > 0 {
+			simP = simulated.Exactly[k]
+		}
+		row(fmt.Sprintf("exactly %d", k), p, simP)
+	}
+	row("at least 1", exact.atLeast(1), simulated.atLeast(1))
+	return tw.Flush()
+}
+
+// runOuts is the "cards outs" command: the outs of a hold'em hand on the flop or the turn.
+func runOuts(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "outs")
+	handFlag := fs.String("hand", "", "the 2 hole cards, comma separated")
+	boardFlag := fs.String("board", "", "the 3 or 4 board cards, comma separated")
+	targetFlag := fs.String("target", "", "the hand category to reach, the next one up when not set")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	hand, err := parseCardList(*handFlag)
+	if err != nil {
+		return usagef("hand: %v", err)
+	}
+	board, err := parseCardList(*boardFlag)
+	if err != nil {
+		return usagef("board: %v", err)
+	}
+	current, err := evaluateHand(slices.Concat(hand, board))
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+	target := min(current.Category+1, royalFlush)
+	if *targetFlag != "" {
+		if target, err = parseHandCategory(*targetFlag); err != nil {
+			return usageError{msg: err.Error()}
+		}
+	}
+	o, err := findOuts(hand, board, target)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+
+	fmt.Fprintf(env.stdout, "Now: %v\n", o.Current)
+	fmt.Fprintf(env.stdout, "%d outs to %v or better: %s\n", len(o.Outs), o.Target, o.Outs.toString())
+	fmt.Fprintf(env.stdout, "Next card: %s\n", percent(o.nextCard(), 0))
+	if o.ToCome > 1 {
+		fmt.Fprintf(env.stdout, "By the river: %s\n", percent(o.byRiver(), 0))
+	}
+	return nil
+}
+
+// runCategories is the "cards categories" command: how often every poker hand comes.
+func runCategories(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "categories")
+	size := fs.Int("size", 5, "cards in the hand, 5 to 7")
+	knownFlag := fs.String("known", "", "cards already in the hand, comma separated")
+	sim := fs.Int("sim", 0, "also simulate this many hands, as a check")
+	seed := fs.Int64("seed", 1, "seed of the simulation")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	known, err := parseCardList(*knownFlag)
+	if err != nil {
+		return usagef("known: %v", err)
+	}
+	cfg := categoryConfig{Known: known, Size: *size}
+	c, err := calculateCategoryOdds(cfg)
+	if err != nil {
+		if errors.Is(err, ErrUnknownCard) || errors.Is(err, ErrDuplicateCard) {
+			return usageError{msg: err.Error()}
+		}
+		return err
+	}
+	var simulated categoryOdds
+	if *sim > 0 {
+		cfg.Trials, cfg.Seed, cfg.ExactLimit = *sim, *seed, -1
+		if simulated, err = calculateCategoryOdds(cfg); err != nil {
+			return err
+		}
+	}
+
+	how := "every possible hand"
+	if !c.Exact {
+		how = "random hands"
+	}
+	fmt.Fprintf(env.stdout, "%d card hands ( %d %s ):\n", *size, c.Hands, how)
+	tw := tabwriter.NewWriter(env.stdout, 0, 0, 2, ' ', 0)
+	for cat := royalFlush; cat >= highCard; cat-- {
+		fmt.Fprintf(tw, "  %v\t%d\t%s", cat, c.Counts[cat], percent(c.probability(cat), c.margin(cat)))
+		if *sim > 0 {
+			fmt.Fprintf(tw, "\tsimulated %s", percent(simulated.probability(cat), simulated.margin(cat)))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
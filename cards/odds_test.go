@@ -0,0 +1,182 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestHypergeometric(t *testing.T) {
+	tests := []struct {
+		population, successes, draws, k int
+		want                            float64
+	}{
+		{52, 4, 5, 0, 0.658842},       // no Ace in a poker hand
+		{52, 4, 5, 4, 48.0 / 2598960}, // all four
+		{52, 13, 13, 13, 1.5747e-12},
+		{47, 9, 1, 1, 9.0 / 47},
+		{52, 4, 5, 5, 0},
+		{5, 2, 6, 1, 0},
+	}
+	for _, tt := range tests {
+		got := hypergeometric(tt.population, tt.successes, tt.draws, tt.k)
+		if math.Abs(got-tt.want) > tt.want*1e-3+1e-12 {
+			t.Errorf("Expected %v for %+v, got %v", tt.want, tt, got)
+		}
+	}
+}
+
+func TestDrawOddsMatchSimulation(t *testing.T) {
+	pinochle, _ := lookupCardSet("pinochle")
+	for _, tt := range []struct {
+		d     deck
+		match string
+		draws int
+	}{
+		{newDeck(), "Ace", 5},
+		{newDeck(), "Hearts,Queen of Spades", 13},
+		{pinochle.newDeck(), "Ace", 12},
+	} {
+		match, err := parseCardMatch(tt.match)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exact, err := calculateDrawOdds(tt.d, match, tt.draws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum := exact.atLeast(0); math.Abs(sum-1) > 1e-9 {
+			t.Errorf("%s: expected the chances to add up to 1, got %v", tt.match, sum)
+		}
+		sim, err := simulateDrawOdds(tt.d, match, tt.draws, 20000, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, p := range exact.Exactly {
+			// 4 margins wide, so that a few dozen checks don't fail by chance
+			if got := sim.Exactly[k]; math.Abs(got-p) > 4*sim.margin(p)+1e-9 {
+				t.Errorf("%s: expected about %.4f for exactly %d, simulated %.4f", tt.match, p, k, got)
+			}
+		}
+	}
+
+	if _, err := calculateDrawOdds(newDeck(), func(Card) bool { return true }, 53); err == nil {
+		t.Errorf("Expected an error drawing 53 cards of 52")
+	}
+	if _, err := parseCardMatch("Ace,Trumps"); err == nil {
+		t.Errorf("Expected an error for an unknown suit")
+	}
+}
+
+func TestFindOuts(t *testing.T) {
+	tests := []struct {
+		hand, board string
+		target      handCategory
+		outs        int
+		next, river float64
+	}{
+		// the nut flush draw on the flop, the textbook 9 outs
+		{"Ah Kh", "2h 7h 9c", flush, 9, 9.0 / 47, 0.3497},
+		// an open ended straight draw on the turn
+		{"8c 9d", "Ts Jh 2c 3d", straight, 8, 8.0 / 46, 8.0 / 46},
+		// a set improves to a full house or quads: 3 pairs of the board + the last Seven
+		{"7c 7d", "7h Ks 2d", fullHouse, 7, 7.0 / 47, 1 - 780.0/1081},
+	}
+	for _, tt := range tests {
+		o, err := findOuts(cardsFromCodes(t, tt.hand), cardsFromCodes(t, tt.board), tt.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(o.Outs) != tt.outs {
+			t.Errorf("%s %s: expected %d outs, got %v", tt.hand, tt.board, tt.outs, o.Outs.toString())
+		}
+		if math.Abs(o.nextCard()-tt.next) > 1e-4 || math.Abs(o.byRiver()-tt.river) > 1e-4 {
+			t.Errorf("%s %s: expected %.4f and %.4f, got %.4f and %.4f", tt.hand, tt.board, tt.next, tt.river, o.nextCard(), o.byRiver())
+		}
+	}
+
+	if _, err := findOuts(cardsFromCodes(t, "Ah Kh"), cardsFromCodes(t, "2h 7h 9c Tc Jc"), flush); err == nil {
+		t.Errorf("Expected no outs on the river")
+	}
+}
+
+// The textbook counts of the 2,598,960 five card hands.
+func TestCategoryOddsFiveCards(t *testing.T) {
+	if testing.Short() {
+		t.Skip("counts every poker hand")
+	}
+	c, err := calculateCategoryOdds(categoryConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [royalFlush + 1]int{1302540, 1098240, 123552, 54912, 10200, 5108, 3744, 624, 36, 4}
+	if !c.Exact || c.Hands != 2598960 || c.Counts != want {
+		t.Errorf("Expected %v, got %v of %v hands ( exact %v )", want, c.Counts, c.Hands, c.Exact)
+	}
+}
+
+func TestCategoryOddsSimulation(t *testing.T) {
+	cfg := categoryConfig{Known: cardsFromCodes(t, "As Ah Kd Qc"), Size: 5}
+	exact, err := calculateCategoryOdds(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 48 cards can come: 2 Aces for trips, 3 Kings and 3 Queens for two pair
+	if !exact.Exact || exact.Hands != 48 || exact.Counts[threeOfAKind] != 2 || exact.Counts[twoPair] != 6 || exact.Counts[onePair] != 40 {
+		t.Fatalf("Expected 48 exact hands, got %+v", exact)
+	}
+
+	cfg.ExactLimit, cfg.Trials, cfg.Seed = -1, 20000, 7
+	sim, err := calculateCategoryOdds(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.Exact || sim.Hands != 20000 {
+		t.Fatalf("Expected 20000 random hands, got %v exact %v", sim.Hands, sim.Exact)
+	}
+	for cat := range sim.Counts {
+		p := exact.probability(handCategory(cat))
+		if math.Abs(sim.probability(handCategory(cat))-p) > 4*binomialMargin(p, sim.Hands)+1e-9 {
+			t.Errorf("Expected about %.4f for %v, simulated %.4f", p, handCategory(cat), sim.probability(handCategory(cat)))
+		}
+	}
+
+	for _, bad := range []categoryConfig{
+		{Size: 8},
+		{Known: cardsFromCodes(t, "As As")},
+		{Known: deck{{Rank: BlackJoker, Suit: noSuit}}},
+		{Known: cardsFromCodes(t, "2c 3c 4c 5c 6c 7c"), Size: 5},
+	} {
+		if _, err := calculateCategoryOdds(bad); err == nil {
+			t.Errorf("Expected an error for %+v", bad)
+		}
+	}
+}
+
+func TestOddsCommands(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "odds", "-match", "Ace", "-draw", "5", "-sim", "1000")
+	if code != exitOK || !strings.Contains(stdout, "at least 1  34.1158%") || !strings.Contains(stdout, "simulated") {
+		t.Errorf("Expected the odds of an Ace, got %v ( %s%s )", code, stdout, stderr)
+	}
+	code, stdout, stderr = runCLI(t, "", "outs", "-hand", "Ace of Hearts,King of Hearts", "-board", "Two of Hearts,Seven of Hearts,Nine of Clubs", "-target", "flush")
+	if code != exitOK || !strings.Contains(stdout, "9 outs to Flush") {
+		t.Errorf("Expected 9 outs to a flush, got %v ( %s%s )", code, stdout, stderr)
+	}
+	code, stdout, _ = runCLI(t, "", "categories", "-known", "Ace of Spades,Ace of Hearts,King of Diamonds,Queen of Clubs")
+	if code != exitOK || !strings.Contains(stdout, "48 every possible hand") {
+		t.Errorf("Expected 48 hands, got %v ( %s )", code, stdout)
+	}
+
+	for _, args := range [][]string{
+		{"odds"},
+		{"odds", "-match", "Trumps"},
+		{"odds", "-match", "Ace", "-draw", "60"},
+		{"outs", "-hand", "Ace of Hearts", "-board", "Two of Hearts,Seven of Hearts,Nine of Clubs"},
+		{"outs", "-hand", "Ace of Hearts,King of Hearts", "-board", "Two of Hearts,Seven of Hearts,Nine of Clubs", "-target", "Big Hand"},
+		{"categories", "-known", "Red Joker"},
+	} {
+		if code, _, _ := runCLI(t, "", args...); code != exitUsage {
+			t.Errorf("Expected a usage error for %q, got %v", args, code)
+		}
+	}
+}
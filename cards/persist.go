@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Deck files can be written in 5 formats.
+// The format is picked from the file extension and, when loading a file with an unknown
+// extension, from the first bytes of the file ( the header ).
+//
+//	legacy - "Ace of Spades,Two of Spades,..." - what saveToFile has always written
+//	json   - a versioned document with the cards and metadata ( creation time, seed )
+//	csv    - one card per row with a "rank,suit" header
+//	binary - a compact encoding, 2 bytes per card
+//	signed - the JSON document with an HMAC of it, so a changed file fails to load ( see integrity.go )
+type deckFormat int
+
+const (
+	formatLegacy deckFormat = iota
+	formatJSON
+	formatCSV
+	formatBinary
+	formatSigned
+)
+
+func (f deckFormat) String() string {
+	switch f {
+	case formatLegacy:
+		return "legacy"
+	case formatJSON:
+		return "json"
+	case formatCSV:
+		return "csv"
+	case formatBinary:
+		return "binary"
+	case formatSigned:
+		return "signed"
+	}
+	return fmt.Sprintf("deckFormat(%d)", int(f))
+}
+
+// deckFileVersion is written in the JSON and binary files, so older programs can refuse newer files.
+const deckFileVersion = 1
+
+// binaryMagic opens every binary deck file.
+var binaryMagic = []byte("DECK")
+
+const csvHeader = "rank,suit"
+
+// The errors returned for a file that doesn't hold a valid deck ( together with ErrUnknownCard ).
+// Check for them with errors.Is - the returned error also names the offending card.
+var (
+	ErrDuplicateCard = errors.New("duplicate card")
+	ErrBadDeckFile   = errors.New("bad deck file")
+)
+
+// deckInfo is the metadata stored next to the cards.
+// The legacy and CSV formats have no room for it, so it's lost when saving in those formats.
+type deckInfo struct {
+	Created time.Time
+	// Seed is the seed the deck was shuffled with, nil when unknown.
+	Seed *int64
+	// Set is the name of the card set the deck is validated against ( see cardset.go ).
+	// Empty means any known cards without duplicates. Only the JSON format stores it.
+	Set string
+}
+
+// deckDocument is the layout of the JSON deck file.
+type deckDocument struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Seed    *int64    `json:"seed,omitempty"`
+	Set     string    `json:"set,omitempty"`
+	Cards   []string  `json:"cards"`
+}
+
+// SaveDeck writes the deck to filename in the format matching the file extension.
+func SaveDeck(filename string, d deck) error {
+	return SaveDeckInfo(filename, d, deckInfo{Created: time.Now()})
+}
+
+// SaveDeckInfo is SaveDeck with the metadata passed in by the caller.
+func SaveDeckInfo(filename string, d deck, info deckInfo) error {
+	format, ok := formatFromExtension(filename)
+	if !ok {
+		format = formatLegacy
+	}
+	bs, err := encodeDeck(format, d, info)
+	if err != nil {
+		return fmt.Errorf("saving %s: %w", filename, err)
+	}
+	return os.WriteFile(filename, bs, 0666)
+}
+
+// LoadDeck reads a deck file written by SaveDeck or saveToFile.
+// Unlike newDeckFromFile it understands every format, not only the legacy one.
+func LoadDeck(filename string) (deck, error) {
+	d, _, err := LoadDeckInfo(filename)
+	return d, err
+}
+
+// LoadDeckInfo is LoadDeck that also returns the metadata found in the file.
+func LoadDeckInfo(filename string) (deck, deckInfo, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, deckInfo{}, err
+	}
+	d, info, err := decodeDeck(detectFormat(filename, bs), bs)
+	if err != nil {
+		return nil, deckInfo{}, fmt.Errorf("loading %s: %w", filename, err)
+	}
+	return d, info, nil
+}
+
+// LoadDeckAs is LoadDeckInfo for a deck of the named card set. Files that don't store the set
+// ( legacy, CSV, binary ) are validated against it - that's how a Pinochle deck with its
+// two copies of every card is loaded. A JSON file of another set is refused.
+func LoadDeckAs(filename, set string) (deck, deckInfo, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, deckInfo{}, err
+	}
+	d, info, err := decodeDeckAs(detectFormat(filename, bs), bs, set)
+	if err != nil {
+		return nil, deckInfo{}, fmt.Errorf("loading %s: %w", filename, err)
+	}
+	return d, info, nil
+}
+
+// formatFromExtension maps the file extension to a format.
+func formatFromExtension(filename string) (deckFormat, bool) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return formatJSON, true
+	case ".csv":
+		return formatCSV, true
+	case ".bin", ".deck":
+		return formatBinary, true
+	case ".txt":
+		return formatLegacy, true
+	case ".sdeck":
+		return formatSigned, true
+	}
+	return formatLegacy, false
+}
+
+// detectFormat uses the extension when it's known, otherwise it looks at the start of the file.
+func detectFormat(filename string, bs []byte) deckFormat {
+	if format, ok := formatFromExtension(filename); ok {
+		return format
+	}
+	return sniffFormat(bs)
+}
+
+func sniffFormat(bs []byte) deckFormat {
+	switch {
+	// before binary, both start with DECK
+	case bytes.HasPrefix(bs, []byte(signedMagic)):
+		return formatSigned
+	case bytes.HasPrefix(bs, binaryMagic):
+		return formatBinary
+	case bytes.HasPrefix(bytes.TrimSpace(bs), []byte("{")):
+		return formatJSON
+	case bytes.HasPrefix(bs, []byte(csvHeader+"\n")), bytes.HasPrefix(bs, []byte(csvHeader+"\r\n")):
+		return formatCSV
+	}
+	return formatLegacy
+}
+
+// encodeDeck turns the deck into the bytes of a file in the given format.
+func encodeDeck(format deckFormat, d deck, info deckInfo) ([]byte, error) {
+	if err := validateFor(d, info.Set); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case formatLegacy:
+		return []byte(d.toString()), nil
+
+	case formatJSON:
+		doc := deckDocument{Version: deckFileVersion, Created: info.Created, Seed: info.Seed, Set: info.Set, Cards: make([]string, len(d))}
+		for i, card := range d {
+			doc.Cards[i] = card.String()
+		}
+		return json.MarshalIndent(doc, "", "  ")
+
+	case formatCSV:
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(strings.Split(csvHeader, ","))
+		for _, card := range d {
+			suit := ""
+			if card.Suit != noSuit {
+				suit = card.Suit.String()
+			}
+			w.Write([]string{card.Rank.String(), suit})
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+
+	case formatBinary:
+		return encodeBinaryDeck(d, info)
+
+	case formatSigned:
+		return nil, fmt.Errorf("a %v deck file needs a key, see SaveSignedDeck", format)
+	}
+	return nil, fmt.Errorf("unknown format %v", format)
+}
+
+// decodeDeck is the opposite of encodeDeck. The decoded deck is validated before it's returned.
+func decodeDeck(format deckFormat, bs []byte) (deck, deckInfo, error) {
+	return decodeDeckAs(format, bs, "")
+}
+
+// decodeDeckAs is decodeDeck for a deck of the given set, "" when the set isn't known.
+func decodeDeckAs(format deckFormat, bs []byte, set string) (deck, deckInfo, error) {
+	d, info, err := parseDeckFile(format, bs, nil)
+	if err != nil {
+		return nil, deckInfo{}, err
+	}
+	switch {
+	case info.Set == "":
+		info.Set = set
+	case set != "" && info.Set != set:
+		return nil, deckInfo{}, fmt.Errorf("%w: a %s deck, not %s", ErrBadDeckFile, info.Set, set)
+	}
+	if err := validateFor(d, info.Set); err != nil {
+		return nil, deckInfo{}, err
+	}
+	return d, info, nil
+}
+
+// parseDeckFile reads the cards and the metadata of a file without validating them.
+// When unknown isn't nil the names that aren't cards are added to it instead of failing,
+// that's how checkDeckFile reports all of them.
+func parseDeckFile(format deckFormat, bs []byte, unknown *[]string) (deck, deckInfo, error) {
+	var (
+		d    deck
+		info deckInfo
+		err  error
+	)
+
+	switch format {
+	case formatLegacy:
+		d, err = decodeLegacyDeck(bs, unknown)
+
+	case formatJSON:
+		var doc deckDocument
+		if err := json.Unmarshal(bs, &doc); err != nil {
+			return nil, info, fmt.Errorf("%w: %v", ErrBadDeckFile, err)
+		}
+		if doc.Version < 1 || doc.Version > deckFileVersion {
+			return nil, info, fmt.Errorf("%w: unsupported version %d", ErrBadDeckFile, doc.Version)
+		}
+		info = deckInfo{Created: doc.Created, Seed: doc.Seed, Set: doc.Set}
+		d, err = parseCardsSkipping(doc.Cards, unknown)
+
+	case formatCSV:
+		d, err = decodeCSVDeck(bs, unknown)
+
+	case formatBinary:
+		d, info, err = decodeBinaryDeck(bs)
+
+	case formatSigned:
+		err = fmt.Errorf("%w: the file is signed, it can only be loaded with its key", ErrBadSignature)
+
+	default:
+		err = fmt.Errorf("unknown format %v", format)
+	}
+
+	if err != nil {
+		return nil, deckInfo{}, err
+	}
+	return d, info, nil
+}
+
+func decodeLegacyDeck(bs []byte, unknown *[]string) (deck, error) {
+	s := strings.TrimSpace(string(bs))
+	if s == "" {
+		return deck{}, nil
+	}
+	return parseCardsSkipping(strings.Split(s, ","), unknown)
+}
+
+func decodeCSVDeck(bs []byte, unknown *[]string) (deck, error) {
+	r := csv.NewReader(bytes.NewReader(bs))
+	r.FieldsPerRecord = 2
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadDeckFile, err)
+	}
+	if len(rows) == 0 || strings.Join(rows[0], ",") != csvHeader {
+		return nil, fmt.Errorf("%w: missing %q header", ErrBadDeckFile, csvHeader)
+	}
+
+	names := make([]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		if row[1] == "" {
+			names = append(names, row[0])
+		} else {
+			names = append(names, row[0]+" of "+row[1])
+		}
+	}
+	return parseCardsSkipping(names, unknown)
+}
+
+// The binary layout ( all numbers big endian ):
+//
+//	"DECK" | version uint8 | flags uint8 | created unix nano int64 | [seed int64] | count uint16 | count * (rank uint8, suit uint8)
+//
+// flags bit 0 tells if the seed is present. Cards without a suit are stored with suit 0xFF.
+const binaryFlagSeed = 1
+
+func encodeBinaryDeck(d deck, info deckInfo) ([]byte, error) {
+	if len(d) > 0xFFFF {
+		return nil, fmt.Errorf("deck too big for the binary format: %d cards", len(d))
+	}
+
+	var buf bytes.Buffer
+	buf.Write(binaryMagic)
+	buf.WriteByte(deckFileVersion)
+
+	var flags byte
+	if info.Seed != nil {
+		flags |= binaryFlagSeed
+	}
+	buf.WriteByte(flags)
+
+	var created int64
+	if !info.Created.IsZero() {
+		created = info.Created.UnixNano()
+	}
+	binary.Write(&buf, binary.BigEndian, created)
+	if info.Seed != nil {
+		binary.Write(&buf, binary.BigEndian, *info.Seed)
+	}
+
+	binary.Write(&buf, binary.BigEndian, uint16(len(d)))
+	for _, card := range d {
+		buf.WriteByte(byte(card.Rank))
+		buf.WriteByte(byte(card.Suit))
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeBinaryDeck(bs []byte) (deck, deckInfo, error) {
+	var info deckInfo
+	r := bytes.NewReader(bs)
+
+	magic := make([]byte, len(binaryMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, binaryMagic) {
+		return nil, info, fmt.Errorf("%w: missing binary header", ErrBadDeckFile)
+	}
+
+	var header struct {
+		Version uint8
+		Flags   uint8
+		Created int64
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, info, fmt.Errorf("%w: %v", ErrBadDeckFile, err)
+	}
+	if header.Version < 1 || header.Version > deckFileVersion {
+		return nil, info, fmt.Errorf("%w: unsupported version %d", ErrBadDeckFile, header.Version)
+	}
+	if header.Created != 0 {
+		info.Created = time.Unix(0, header.Created)
+	}
+	if header.Flags&binaryFlagSeed != 0 {
+		var seed int64
+		if err := binary.Read(r, binary.BigEndian, &seed); err != nil {
+			return nil, info, fmt.Errorf("%w: %v", ErrBadDeckFile, err)
+		}
+		info.Seed = &seed
+	}
+
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, info, fmt.Errorf("%w: %v", ErrBadDeckFile, err)
+	}
+	raw := make([]byte, 2*int(count))
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, info, fmt.Errorf("%w: expected %d cards: %v", ErrBadDeckFile, count, err)
+	}
+	if r.Len() != 0 {
+		return nil, info, fmt.Errorf("%w: %d unexpected bytes after the cards", ErrBadDeckFile, r.Len())
+	}
+
+	d := make(deck, count)
+	for i := range d {
+		d[i] = Card{Rank: Rank(raw[2*i]), Suit: Suit(int8(raw[2*i+1]))}
+	}
+	return d, info, nil
+}
+
+// parseCards parses every name into a card.
+func parseCards(names []string) (deck, error) {
+	return parseCardsSkipping(names, nil)
+}
+
+// parseCardsSkipping is parseCards that, when unknown isn't nil, skips the names that aren't
+// cards and adds them to unknown.
+func parseCardsSkipping(names []string, unknown *[]string) (deck, error) {
+	d := make(deck, 0, len(names))
+	for _, name := range names {
+		card, err := parseCard(name)
+		if err != nil {
+			if unknown == nil {
+				return nil, err
+			}
+			*unknown = append(*unknown, strings.TrimSpace(name))
+			continue
+		}
+		d = append(d, card)
+	}
+	return d, nil
+}
+
+// validateDeck rejects cards that don't exist and cards that show up more than once.
+// Decks with copies of a card ( Pinochle, Uno ) are validated against their set instead.
+func validateDeck(d deck) error {
+	seen := make(map[Card]bool, len(d))
+	for i, card := range d {
+		if !card.known() {
+			return fmt.Errorf("%w at position %d: %v", ErrUnknownCard, i, card)
+		}
+		if seen[card] {
+			return fmt.Errorf("%w at position %d: %v", ErrDuplicateCard, i, card)
+		}
+		seen[card] = true
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Every format has to give back the same deck ( and the metadata when the format can hold it ).
+func TestSaveDeckAndLoadDeck(t *testing.T) {
+	dir := t.TempDir()
+	seed := int64(1234)
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	d := newDeckWithJokers()
+	d.shuffleSeed(seed)
+
+	tests := []struct {
+		filename string
+		format   deckFormat
+		keepInfo bool
+	}{
+		{"deck.txt", formatLegacy, false},
+		{"deck.json", formatJSON, true},
+		{"deck.csv", formatCSV, false},
+		{"deck.bin", formatBinary, true},
+	}
+
+	for _, tt := range tests {
+		filename := filepath.Join(dir, tt.filename)
+		if err := SaveDeckInfo(filename, d, deckInfo{Created: created, Seed: &seed}); err != nil {
+			t.Fatalf("%s: save failed: %v", tt.filename, err)
+		}
+
+		loaded, info, err := LoadDeckInfo(filename)
+		if err != nil {
+			t.Fatalf("%s: load failed: %v", tt.filename, err)
+		}
+		if loaded.toString() != d.toString() {
+			t.Errorf("%s: expected the same cards after the round trip", tt.filename)
+		}
+		if tt.keepInfo {
+			if info.Seed == nil || *info.Seed != seed {
+				t.Errorf("%s: expected seed %v, got %v", tt.filename, seed, info.Seed)
+			}
+			if !info.Created.Equal(created) {
+				t.Errorf("%s: expected created %v, got %v", tt.filename, created, info.Created)
+			}
+		}
+
+		// without a known extension the format has to be found from the header
+		bs, _ := os.ReadFile(filename)
+		if got := sniffFormat(bs); got != tt.format {
+			t.Errorf("%s: expected the header to be detected as %v, got %v", tt.filename, tt.format, got)
+		}
+	}
+}
+
+// A file written by saveToFile is still readable with LoadDeck.
+func TestLoadDeckReadsLegacyFiles(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "_decktesting")
+	newDeck().saveToFile(filename)
+
+	d, err := LoadDeck(filename)
+	if err != nil {
+		t.Fatalf("Expected to load the legacy file, got %v", err)
+	}
+	if len(d) != 52 {
+		t.Errorf("Expected 52 cards, got %v", len(d))
+	}
+}
+
+func TestLoadDeckRejectsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		want     error
+	}{
+		{"duplicate", "dup.txt", "Ace of Spades,Two of Spades,Ace of Spades", ErrDuplicateCard},
+		{"unknown", "unknown.txt", "Ace of Spades,Eleven of Spades", ErrUnknownCard},
+		{"unknown csv", "unknown.csv", "rank,suit\nAce,Stars\n", ErrUnknownCard},
+		{"duplicate json", "dup.json", `{"version":1,"cards":["Red Joker","Red Joker"]}`, ErrDuplicateCard},
+		{"future json", "future.json", `{"version":99,"cards":[]}`, ErrBadDeckFile},
+		{"csv header", "noheader.csv", "Ace,Spades\n", ErrBadDeckFile},
+		{"binary truncated", "short.bin", "DECK\x01\x00", ErrBadDeckFile},
+		{"binary unknown card", "bad.bin", "DECK\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x63\x00", ErrUnknownCard},
+	}
+
+	for _, tt := range tests {
+		filename := filepath.Join(dir, tt.filename)
+		os.WriteFile(filename, []byte(tt.content), 0666)
+		if _, err := LoadDeck(filename); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	if _, err := LoadDeck(filepath.Join(dir, "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a not exist error for a missing file, got %v", err)
+	}
+}
+
+func TestSaveDeckRejectsDuplicates(t *testing.T) {
+	d := deck{{Rank: Ace, Suit: Spades}, {Rank: Ace, Suit: Spades}}
+	if err := SaveDeck(filepath.Join(t.TempDir(), "dup.json"), d); !errors.Is(err, ErrDuplicateCard) {
+		t.Errorf("Expected %v, got %v", ErrDuplicateCard, err)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+)
+
+// Deck operations. The top of a deck is index 0, the same as deal and the Shoe draw from.
+
+// cut moves the top at cards to the bottom, like cutting the deck by hand.
+func (d deck) cut(at int) error {
+	if at < 0 || at > len(d) {
+		return fmt.Errorf("can't cut a deck of %d cards at %d", len(d), at)
+	}
+	copy(d, slices.Concat(d[at:], d[:at]))
+	return nil
+}
+
+// riffle is one riffle shuffle the way people do it ( the Gilbert-Shannon-Reeds model ):
+// the deck is cut near the middle - the size of the top half is binomial - and the cards
+// fall from the two halves with a chance that follows how many cards each half has left.
+// A single riffle is far from random, it takes about 7 of them to mix 52 cards.
+func (d deck) riffle(source rand.Source) {
+	r := rand.New(source)
+	half := 0
+	for range d {
+		half += r.Intn(2)
+	}
+	left := append(deck{}, d[:half]...)
+	right := append(deck{}, d[half:]...)
+	for i := range d {
+		if r.Intn(len(left)+len(right)) < len(left) {
+			d[i], left = left[0], left[1:]
+		} else {
+			d[i], right = right[0], right[1:]
+		}
+	}
+}
+
+// overhandBreak is the chance that a packet of an overhand shuffle ends after a card.
+// It makes packets of about 4 cards.
+const overhandBreak = 0.25
+
+// overhand is one overhand shuffle: small packets are slid off the top into the other hand,
+// so the order of the packets is reversed and the cards inside a packet stay together.
+// It mixes much worse than a riffle - thousands of them are needed for 52 cards.
+func (d deck) overhand(source rand.Source) {
+	r := rand.New(source)
+	var packets []deck
+	start := 0
+	for i := 1; i <= len(d); i++ {
+		if i == len(d) || r.Float64() < overhandBreak {
+			packets = append(packets, d[start:i])
+			start = i
+		}
+	}
+	mixed := make(deck, 0, len(d))
+	for i := len(packets) - 1; i >= 0; i-- {
+		mixed = append(mixed, packets[i]...)
+	}
+	copy(d, mixed)
+}
+
+// peek returns a copy of the top n cards without taking them.
+func (d deck) peek(n int) (deck, error) {
+	if err := d.canTake(n); err != nil {
+		return nil, err
+	}
+	return slices.Clone(d[:n]), nil
+}
+
+// peekBottom returns a copy of the bottom n cards, the bottom card last.
+func (d deck) peekBottom(n int) (deck, error) {
+	if err := d.canTake(n); err != nil {
+		return nil, err
+	}
+	return slices.Clone(d[len(d)-n:]), nil
+}
+
+// canTake tells if n cards can be taken from the deck.
+func (d deck) canTake(n int) error {
+	if n < 0 {
+		return fmt.Errorf("can't take %d cards", n)
+	}
+	if n > len(d) {
+		return &InsufficientCardsError{Need: n, Have: len(d)}
+	}
+	return nil
+}
+
+// drawTop takes the top n cards off the deck.
+func (d *deck) drawTop(n int) (deck, error) {
+	cards, err := d.peek(n)
+	if err != nil {
+		return nil, err
+	}
+	*d = slices.Clone((*d)[n:])
+	return cards, nil
+}
+
+// drawBottom takes the bottom n cards off the deck, the bottom card last.
+func (d *deck) drawBottom(n int) (deck, error) {
+	cards, err := d.peekBottom(n)
+	if err != nil {
+		return nil, err
+	}
+	*d = slices.Clone((*d)[:len(*d)-n])
+	return cards, nil
+}
+
+// insert puts the cards in the deck so the first of them ends at position at
+// ( 0 is the top, len(d) is the bottom ).
+func (d *deck) insert(at int, cards ...Card) error {
+	if at < 0 || at > len(*d) {
+		return fmt.Errorf("can't insert at %d in a deck of %d cards", at, len(*d))
+	}
+	*d = slices.Insert(slices.Clone(*d), at, cards...)
+	return nil
+}
+
+// sortBy sorts the deck in place, cards that compare equal keep their order.
+func (d deck) sortBy(order func(a, b Card) int) {
+	slices.SortStableFunc(d, order)
+}
+
+// bySuitThenRank is the order of a new deck: Spades, Diamonds, Hearts, Clubs and Ace to King in each.
+func bySuitThenRank(a, b Card) int {
+	return a.compare(b)
+}
+
+// byRankThenSuit groups the cards by rank, Aces high, like a poker player sorts a hand.
+func byRankThenSuit(a, b Card) int {
+	if c := a.compareRank(b); c != 0 {
+		return c
+	}
+	return a.compare(b)
+}
+
+// customOrder sorts by the suits in the given order, then by the ranks in the given order.
+// Suits and ranks missing from the lists go after the listed ones, in their usual order.
+func customOrder(suits []Suit, ranks []Rank) func(a, b Card) int {
+	position := func(i int, v int) int {
+		if i < 0 {
+			return 1000 + v
+		}
+		return i
+	}
+	return func(a, b Card) int {
+		if c := cmp.Compare(position(slices.Index(suits, a.Suit), int(a.Suit)), position(slices.Index(suits, b.Suit), int(b.Suit))); c != 0 {
+			return c
+		}
+		return cmp.Compare(position(slices.Index(ranks, a.Rank), int(a.Rank)), position(slices.Index(ranks, b.Rank), int(b.Rank)))
+	}
+}
+
+var (
+	// ErrNoPile is returned for a pile name that isn't on the table.
+	ErrNoPile = errors.New("no such pile")
+	// ErrPileExists is returned when a pile is added twice.
+	ErrPileExists = errors.New("pile already exists")
+	// ErrCardNotInPile is returned when a card is moved from a pile that doesn't hold it.
+	ErrCardNotInPile = errors.New("card is not in the pile")
+)
+
+// pileSet is a table of named piles - draw, discard, tableau, ... - sharing one set of cards.
+// Cards only move from one pile to another, so none is ever duplicated or lost:
+// verify checks that against the cards the table started with.
+type pileSet struct {
+	piles map[string]deck
+	// names keeps the order the piles were added in.
+	names []string
+	start map[Card]int
+}
+
+// newPileSet puts all the cards on a pile called "draw".
+func newPileSet(cards deck) *pileSet {
+	p := &pileSet{piles: map[string]deck{}, start: map[Card]int{}}
+	for _, card := range cards {
+		p.start[card]++
+	}
+	p.piles["draw"] = slices.Clone(cards)
+	p.names = []string{"draw"}
+	return p
+}
+
+// add puts a new empty pile on the table.
+func (p *pileSet) add(name string) error {
+	if _, ok := p.piles[name]; ok {
+		return fmt.Errorf("%w: %q", ErrPileExists, name)
+	}
+	p.piles[name] = deck{}
+	p.names = append(p.names, name)
+	return nil
+}
+
+// pileNames lists the piles in the order they were added, "draw" first.
+func (p *pileSet) pileNames() []string {
+	return slices.Clone(p.names)
+}
+
+// pile returns a copy of the cards on the pile, top first.
+func (p *pileSet) pile(name string) (deck, error) {
+	cards, ok := p.piles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNoPile, name)
+	}
+	return slices.Clone(cards), nil
+}
+
+// move takes the top n cards of one pile and puts them on top of the other as one packet,
+// so their order doesn't change.
+func (p *pileSet) move(from, to string, n int) error {
+	src, dst, err := p.pair(from, to)
+	if err != nil {
+		return err
+	}
+	cards, err := src.drawTop(n)
+	if err != nil {
+		return fmt.Errorf("pile %q: %w", from, err)
+	}
+	p.piles[from] = *src
+	p.piles[to] = slices.Concat(cards, *dst)
+	return nil
+}
+
+// dealTo moves the top n cards of one pile to the other one at a time, the way cards are dealt,
+// so they end up in reverse order.
+func (p *pileSet) dealTo(from, to string, n int) error {
+	if err := p.move(from, to, n); err != nil {
+		return err
+	}
+	slices.Reverse(p.piles[to][:n])
+	return nil
+}
+
+// moveCard takes one given card out of a pile - wherever it is - and puts it on top of the other.
+func (p *pileSet) moveCard(from, to string, card Card) error {
+	src, dst, err := p.pair(from, to)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(*src, card)
+	if i < 0 {
+		return fmt.Errorf("%w: %v in %q", ErrCardNotInPile, card, from)
+	}
+	p.piles[from] = slices.Delete(slices.Clone(*src), i, i+1)
+	p.piles[to] = slices.Concat(deck{card}, *dst)
+	return nil
+}
+
+// pair looks up the two piles of a move. Moving cards onto the pile they come from isn't allowed.
+func (p *pileSet) pair(from, to string) (*deck, *deck, error) {
+	if from == to {
+		return nil, nil, fmt.Errorf("can't move cards from %q onto itself", from)
+	}
+	src, ok := p.piles[from]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q", ErrNoPile, from)
+	}
+	dst, ok := p.piles[to]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q", ErrNoPile, to)
+	}
+	return &src, &dst, nil
+}
+
+// total is the number of cards on all the piles.
+func (p *pileSet) total() int {
+	n := 0
+	for _, cards := range p.piles {
+		n += len(cards)
+	}
+	return n
+}
+
+// verify checks that the piles hold exactly the cards the table started with.
+func (p *pileSet) verify() error {
+	counts := map[Card]int{}
+	for _, cards := range p.piles {
+		for _, card := range cards {
+			counts[card]++
+		}
+	}
+	for card, n := range p.start {
+		if counts[card] != n {
+			return fmt.Errorf("%v: expected %d on the table, found %d", card, n, counts[card])
+		}
+	}
+	for card, n := range counts {
+		if _, ok := p.start[card]; !ok {
+			return fmt.Errorf("%v: expected 0 on the table, found %d", card, n)
+		}
+	}
+	return nil
+}
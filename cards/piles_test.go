@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestCut(t *testing.T) {
+	d := newDeck()
+	if err := d.cut(10); err != nil {
+		t.Fatal(err)
+	}
+	if d[0] != newDeck()[10] || d[42] != newDeck()[0] {
+		t.Errorf("Expected the 11th card on top and the old top card at 42, got %v and %v", d[0], d[42])
+	}
+	if err := d.cut(53); err == nil {
+		t.Errorf("Expected an error for a cut past the bottom")
+	}
+}
+
+// sameCards tells if the two decks hold the same cards, in any order.
+func sameCards(a, b deck) bool {
+	counts := map[Card]int{}
+	for _, card := range a {
+		counts[card]++
+	}
+	for _, card := range b {
+		counts[card]--
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// risingSequences counts the runs of consecutive cards of the original order in d.
+// A riffle of a new deck leaves at most 2 of them.
+func risingSequences(d deck) int {
+	position := map[Card]int{}
+	for i, card := range d {
+		position[card] = i
+	}
+	sequences := 1
+	original := newDeck()
+	for i := 1; i < len(original); i++ {
+		if position[original[i]] < position[original[i-1]] {
+			sequences++
+		}
+	}
+	return sequences
+}
+
+func TestRiffle(t *testing.T) {
+	source := rand.NewSource(3)
+	for range 100 {
+		d := newDeck()
+		d.riffle(source)
+		if !sameCards(d, newDeck()) {
+			t.Fatalf("Expected the riffle to keep all the cards, got %v", d.toString())
+		}
+		if n := risingSequences(d); n > 2 {
+			t.Fatalf("Expected at most 2 rising sequences after one riffle, got %v", n)
+		}
+	}
+
+	a, b := newDeck(), newDeck()
+	a.riffle(rand.NewSource(9))
+	b.riffle(rand.NewSource(9))
+	if a.toString() != b.toString() {
+		t.Errorf("Expected the same seed to riffle the same way")
+	}
+}
+
+func TestOverhand(t *testing.T) {
+	d := newDeck()
+	d.overhand(rand.NewSource(5))
+	if !sameCards(d, newDeck()) {
+		t.Fatalf("Expected the overhand shuffle to keep all the cards, got %v", d.toString())
+	}
+	// the top packet goes to the bottom, so the old top card is in the last packet
+	if d[0] == newDeck()[0] {
+		t.Errorf("Expected the top card to move")
+	}
+	// packets keep their order: most neighbours are still neighbours
+	kept := 0
+	for i := 1; i < len(d); i++ {
+		if d[i-1].Suit == d[i].Suit && d[i-1].Rank+1 == d[i].Rank {
+			kept++
+		}
+	}
+	if kept < 25 {
+		t.Errorf("Expected most cards to stay next to their neighbour, only %v did", kept)
+	}
+}
+
+func TestPeekDrawInsert(t *testing.T) {
+	d := newDeck()
+	top, err := d.peek(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top.toString() != "Ace of Spades,Two of Spades" || len(d) != 52 {
+		t.Errorf("Expected to peek the first 2 cards without taking them, got %v", top.toString())
+	}
+
+	bottom, err := d.drawBottom(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bottom[0] != (Card{King, Clubs}) || len(d) != 51 {
+		t.Errorf("Expected to draw the King of Clubs from the bottom, got %v", bottom)
+	}
+	drawn, err := d.drawTop(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drawn) != 3 || len(d) != 48 || d[0] != (Card{Four, Spades}) {
+		t.Errorf("Expected the Four of Spades on top after drawing 3, got %v", d[0])
+	}
+
+	if err := d.insert(1, bottom...); err != nil {
+		t.Fatal(err)
+	}
+	if d[1] != (Card{King, Clubs}) || len(d) != 49 {
+		t.Errorf("Expected the King of Clubs second, got %v", d[1])
+	}
+	if err := d.insert(50, drawn...); err == nil {
+		t.Errorf("Expected an error inserting past the bottom")
+	}
+
+	var insufficient *InsufficientCardsError
+	if _, err := d.drawTop(50); !errors.As(err, &insufficient) {
+		t.Errorf("Expected an InsufficientCardsError, got %v", err)
+	}
+}
+
+func TestSortOrders(t *testing.T) {
+	d := newDeckWithJokers()
+	d.shuffleSeed(1)
+	d.sortBy(bySuitThenRank)
+	if d.toString() != newDeckWithJokers().toString() {
+		t.Errorf("Expected suit then rank to give the new deck order")
+	}
+
+	d.sortBy(byRankThenSuit)
+	if d[0] != (Card{Two, Spades}) || d[47] != (Card{King, Clubs}) || d[51] != (Card{Ace, Clubs}) {
+		t.Errorf("Expected Twos first and Aces last, got %v, %v, %v", d[0], d[47], d[51])
+	}
+
+	// bridge order: Clubs, Diamonds, Hearts, Spades, Aces high
+	high := []Rank{Two, Three, Four, Five, Six, Seven, Eight, Nine, Ten, Jack, Queen, King, Ace}
+	d.sortBy(customOrder([]Suit{Clubs, Diamonds, Hearts, Spades}, high))
+	if d[0] != (Card{Two, Clubs}) || d[12] != (Card{Ace, Clubs}) || d[51] != (Card{Ace, Spades}) {
+		t.Errorf("Expected Two of Clubs to Ace of Spades, got %v, %v, %v", d[0], d[12], d[51])
+	}
+	if !d[52].isJoker() || !d[53].isJoker() {
+		t.Errorf("Expected the unlisted jokers last")
+	}
+}
+
+func TestPileSet(t *testing.T) {
+	d := newDeck()
+	d.shuffleSeed(4)
+	p := newPileSet(d)
+	for _, name := range []string{"discard", "tableau"} {
+		if err := p.add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.add("discard"); !errors.Is(err, ErrPileExists) {
+		t.Errorf("Expected ErrPileExists, got %v", err)
+	}
+
+	if err := p.move("draw", "tableau", 5); err != nil {
+		t.Fatal(err)
+	}
+	tableau, _ := p.pile("tableau")
+	if tableau.toString() != d[:5].toString() {
+		t.Errorf("Expected a moved packet to keep its order")
+	}
+	if err := p.dealTo("draw", "discard", 3); err != nil {
+		t.Fatal(err)
+	}
+	discard, _ := p.pile("discard")
+	if discard[0] != d[7] || discard[2] != d[5] {
+		t.Errorf("Expected dealt cards in reverse order, got %v", discard.toString())
+	}
+	if err := p.moveCard("tableau", "discard", d[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.moveCard("tableau", "discard", d[2]); !errors.Is(err, ErrCardNotInPile) {
+		t.Errorf("Expected ErrCardNotInPile, got %v", err)
+	}
+
+	// failed moves change nothing
+	if err := p.move("draw", "nowhere", 1); !errors.Is(err, ErrNoPile) {
+		t.Errorf("Expected ErrNoPile, got %v", err)
+	}
+	if err := p.move("discard", "discard", 1); err == nil {
+		t.Errorf("Expected an error moving a pile onto itself")
+	}
+	if err := p.move("tableau", "draw", 10); err == nil {
+		t.Errorf("Expected an error moving more cards than the pile has")
+	}
+
+	if names := p.pileNames(); len(names) != 3 || names[0] != "draw" || names[2] != "tableau" {
+		t.Errorf("Expected the piles in the order they were added, got %v", names)
+	}
+	if p.total() != 52 {
+		t.Errorf("Expected 52 cards on the table, got %v", p.total())
+	}
+	if err := p.verify(); err != nil {
+		t.Errorf("Expected no card lost or duplicated, got %v", err)
+	}
+
+	// the check catches a card that appears out of nowhere
+	p.piles["tableau"] = append(p.piles["tableau"], d[0])
+	if err := p.verify(); err == nil {
+		t.Errorf("Expected verify to find the duplicated %v", d[0])
+	}
+}
+
+func TestPileSetRandomMoves(t *testing.T) {
+	p := newPileSet(newDeckWithJokers())
+	names := []string{"draw", "discard", "a", "b", "c"}
+	for _, name := range names[1:] {
+		p.add(name)
+	}
+	r := rand.New(rand.NewSource(8))
+	for range 2000 {
+		from, to := names[r.Intn(len(names))], names[r.Intn(len(names))]
+		n := r.Intn(5)
+		switch r.Intn(3) {
+		case 0:
+			p.move(from, to, n)
+		case 1:
+			p.dealTo(from, to, n)
+		default:
+			p.moveCard(from, to, newDeckWithJokers()[r.Intn(54)])
+		}
+	}
+	if err := p.verify(); err != nil {
+		t.Errorf("Expected no card lost or duplicated after random moves, got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// handCategory is the kind of poker hand, from the weakest to the strongest.
+type handCategory int
+
+const (
+	highCard handCategory = iota
+	onePair
+	twoPair
+	threeOfAKind
+	straight
+	flush
+	fullHouse
+	fourOfAKind
+	straightFlush
+	royalFlush
+)
+
+var handCategoryNames = []string{"High Card", "One Pair", "Two Pair", "Three of a Kind", "Straight", "Flush",
+	"Full House", "Four of a Kind", "Straight Flush", "Royal Flush"}
+
+func (c handCategory) String() string {
+	if c < 0 || int(c) >= len(handCategoryNames) {
+		return fmt.Sprintf("handCategory(%d)", int(c))
+	}
+	return handCategoryNames[c]
+}
+
+// handValue is the score of the best five cards of a hand.
+// Two hands are compared by category first and then by the tie-break ranks,
+// e.g. a pair of Kings with Ace, Nine, Four kickers has Ranks [13 14 9 4 0].
+type handValue struct {
+	Category handCategory
+	// Ranks are the Ace high values ( see Rank.high ) that break ties, the most important one first.
+	Ranks [5]int
+	// Cards are the five cards that make the hand.
+	Cards deck
+}
+
+// compare returns -1, 0 or +1 when v is weaker, as strong as or stronger than o.
+func (v handValue) compare(o handValue) int {
+	if c := cmp.Compare(v.Category, o.Category); c != 0 {
+		return c
+	}
+	for i := range v.Ranks {
+		if c := cmp.Compare(v.Ranks[i], o.Ranks[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func (v handValue) String() string {
+	return fmt.Sprintf("%v (%v)", v.Category, v.Cards.toString())
+}
+
+// evaluateHand scores a hand of 5 to 7 cards, e.g. 2 hole cards plus the board.
+// With more than 5 cards every 5 card combination is tried and the best one is kept.
+func evaluateHand(cards deck) (handValue, error) {
+	if len(cards) < 5 || len(cards) > 7 {
+		return handValue{}, fmt.Errorf("a poker hand needs 5 to 7 cards, got %d", len(cards))
+	}
+	seen := map[Card]bool{}
+	for _, card := range cards {
+		if !card.valid() || card.isJoker() {
+			return handValue{}, fmt.Errorf("%w in poker hand: %v", ErrUnknownCard, card)
+		}
+		if seen[card] {
+			return handValue{}, fmt.Errorf("%w in poker hand: %v", ErrDuplicateCard, card)
+		}
+		seen[card] = true
+	}
+	return bestFive(cards), nil
+}
+
+// bestFive is evaluateHand without the checks, for callers that already know the cards are fine.
+func bestFive(cards deck) handValue {
+	var best handValue
+	first := true
+	var five [5]Card
+	// pick 5 of the n cards with 5 nested indexes a < b < c < d < e
+	n := len(cards)
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n; b++ {
+			for c := b + 1; c < n; c++ {
+				for d := c + 1; d < n; d++ {
+					for e := d + 1; e < n; e++ {
+						five = [5]Card{cards[a], cards[b], cards[c], cards[d], cards[e]}
+						v := evaluateFive(five)
+						if first || v.compare(best) > 0 {
+							best = v
+							first = false
+						}
+					}
+				}
+			}
+		}
+	}
+	best.Cards = slices.Clone(best.Cards)
+	return best
+}
+
+// evaluateFive scores exactly five cards. The cards must be valid and different.
+func evaluateFive(five [5]Card) handValue {
+	// sort the cards from the highest to the lowest rank
+	slices.SortFunc(five[:], func(a, b Card) int { return b.compareRank(a) })
+
+	isFlush := true
+	for _, card := range five[1:] {
+		if card.Suit != five[0].Suit {
+			isFlush = false
+		}
+	}
+
+	// group the cards by rank: the biggest group first, the higher rank first for groups of the same size
+	type group struct{ rank, count int }
+	var groups []group
+	for _, card := range five {
+		r := card.Rank.high()
+		if len(groups) > 0 && groups[len(groups)-1].rank == r {
+			groups[len(groups)-1].count++
+		} else {
+			groups = append(groups, group{rank: r, count: 1})
+		}
+	}
+	slices.SortStableFunc(groups, func(a, b group) int { return cmp.Compare(b.count, a.count) })
+
+	v := handValue{Cards: deck(five[:])}
+	for i, g := range groups {
+		v.Ranks[i] = g.rank
+	}
+
+	// a straight has 5 different ranks in a row - the Ace can also play low ( A 2 3 4 5, the "wheel" )
+	isStraight := false
+	if len(groups) == 5 {
+		if v.Ranks[0]-v.Ranks[4] == 4 {
+			isStraight = true
+		} else if v.Ranks == [5]int{14, 5, 4, 3, 2} {
+			isStraight = true
+			v.Ranks = [5]int{5, 4, 3, 2, 1}
+			// the Ace goes to the end of the cards as well
+			v.Cards = append(deck{five[1], five[2], five[3], five[4]}, five[0])
+		}
+	}
+
+	switch {
+	case isStraight && isFlush && v.Ranks[0] == 14:
+		v.Category = royalFlush
+	case isStraight && isFlush:
+		v.Category = straightFlush
+	case groups[0].count == 4:
+		v.Category = fourOfAKind
+	case groups[0].count == 3 && groups[1].count == 2:
+		v.Category = fullHouse
+	case isFlush:
+		v.Category = flush
+	case isStraight:
+		v.Category = straight
+	case groups[0].count == 3:
+		v.Category = threeOfAKind
+	case groups[0].count == 2 && groups[1].count == 2:
+		v.Category = twoPair
+	case groups[0].count == 2:
+		v.Category = onePair
+	default:
+		v.Category = highCard
+	}
+
+	// keep the cards in the order of the groups, e.g. K K 9 9 4 for two pairs
+	if v.Category != straight && v.Category != straightFlush && v.Category != royalFlush {
+		ordered := make(deck, 0, 5)
+		for _, g := range groups {
+			for _, card := range five {
+				if card.Rank.high() == g.rank {
+					ordered = append(ordered, card)
+				}
+			}
+		}
+		v.Cards = ordered
+	}
+	return v
+}
+
+// handResult is the outcome of one player at the showdown.
+type handResult struct {
+	Player int
+	Value  handValue
+	// Place is 1 for the winners, 2 for the next best hands and so on. Equal hands share the place.
+	Place int
+}
+
+// showdown is the outcome of comparing the hands of all the players.
+type showdown struct {
+	// Results are sorted from the best hand to the worst one.
+	Results []handResult
+	// Winners are the players with the best hand. More than one winner means the pot is split.
+	Winners []int
+}
+
+func (s showdown) split() bool {
+	return len(s.Winners) > 1
+}
+
+// compareHands evaluates the hand of every player together with the shared board cards
+// ( the board can be empty for games without community cards ) and ranks them.
+// The players are numbered by their position in hands, the same as dealer.dealHands.
+func compareHands(hands []deck, board deck) (showdown, error) {
+	if len(hands) == 0 {
+		return showdown{}, fmt.Errorf("no hands to compare")
+	}
+
+	results := make([]handResult, len(hands))
+	for player, hand := range hands {
+		cards := append(slices.Clone(hand), board...)
+		v, err := evaluateHand(cards)
+		if err != nil {
+			return showdown{}, fmt.Errorf("player %d: %w", player, err)
+		}
+		results[player] = handResult{Player: player, Value: v}
+	}
+
+	// the best hand first, the lower player number first for equal hands
+	slices.SortStableFunc(results, func(a, b handResult) int { return b.Value.compare(a.Value) })
+
+	var s showdown
+	for i := range results {
+		switch {
+		case i == 0:
+			results[i].Place = 1
+		case results[i].Value.compare(results[i-1].Value) == 0:
+			results[i].Place = results[i-1].Place
+		default:
+			results[i].Place = results[i-1].Place + 1
+		}
+		if results[i].Place == 1 {
+			s.Winners = append(s.Winners, results[i].Player)
+		}
+	}
+	s.Results = results
+	return s, nil
+}
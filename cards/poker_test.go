@@ -0,0 +1,212 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// cardsFromCodes turns short codes like "As Kd Th" into cards to keep the tables readable.
+func cardsFromCodes(t testing.TB, codes string) deck {
+	t.Helper()
+	ranks := map[byte]Rank{'A': Ace, '2': Two, '3': Three, '4': Four, '5': Five, '6': Six, '7': Seven,
+		'8': Eight, '9': Nine, 'T': Ten, 'J': Jack, 'Q': Queen, 'K': King}
+	suits := map[byte]Suit{'s': Spades, 'd': Diamonds, 'h': Hearts, 'c': Clubs}
+
+	d := deck{}
+	for _, code := range strings.Fields(codes) {
+		r, okRank := ranks[code[0]]
+		s, okSuit := suits[code[1]]
+		if len(code) != 2 || !okRank || !okSuit {
+			t.Fatalf("bad card code %q", code)
+		}
+		d = append(d, Card{Rank: r, Suit: s})
+	}
+	return d
+}
+
+func TestEvaluateHand(t *testing.T) {
+	tests := []struct {
+		cards    string
+		category handCategory
+		ranks    [5]int
+	}{
+		{"As Ks Qs Js Ts", royalFlush, [5]int{14, 13, 12, 11, 10}},
+		{"9h Kh Qh Jh Th", straightFlush, [5]int{13, 12, 11, 10, 9}},
+		{"Ad 2d 3d 4d 5d", straightFlush, [5]int{5, 4, 3, 2, 1}},
+		{"7s 7d 7h 7c 2d", fourOfAKind, [5]int{7, 2}},
+		{"3s 3d 3h Kc Kd", fullHouse, [5]int{3, 13}},
+		{"2c 9c Jc 4c Kc", flush, [5]int{13, 11, 9, 4, 2}},
+		{"6s 7d 8h 9c Td", straight, [5]int{10, 9, 8, 7, 6}},
+		{"As Kd Qh Jc Td", straight, [5]int{14, 13, 12, 11, 10}},
+		{"As 2d 3h 4c 5d", straight, [5]int{5, 4, 3, 2, 1}},
+		{"Qs Kd Ah 2c 3d", highCard, [5]int{14, 13, 12, 3, 2}},
+		{"Qs Qd Qh 2c 9d", threeOfAKind, [5]int{12, 9, 2}},
+		{"Js Jd 4h 4c Ad", twoPair, [5]int{11, 4, 14}},
+		{"Ts Td 8h 4c Ad", onePair, [5]int{10, 14, 8, 4}},
+		{"2s 5d 8h Jc Kd", highCard, [5]int{13, 11, 8, 5, 2}},
+		// seven cards - the best five are picked
+		{"As Ks 2d 3c Qs Js Ts", royalFlush, [5]int{14, 13, 12, 11, 10}},
+		{"7s 7d 7h 2c 2d 9h 9s", fullHouse, [5]int{7, 9}},
+		{"7s 7d 7h 7c 9d 9h 9s", fourOfAKind, [5]int{7, 9}},
+		{"2h 3h 4h 5h 9h 6s Ad", flush, [5]int{9, 5, 4, 3, 2}},
+		{"2h 3h 4h 5h 9s 6s Ah", straightFlush, [5]int{5, 4, 3, 2, 1}},
+		{"2h 3h 4h 5h 6h 7h Ah", straightFlush, [5]int{7, 6, 5, 4, 3}},
+		{"Ts Td 8h 8c 4d 4h Ad", twoPair, [5]int{10, 8, 14}},
+		{"2s 3s 9s Jc Ks Kd Qs", flush, [5]int{13, 12, 9, 3, 2}},
+		// six cards
+		{"4c 5d 6h 7s 8c 9d", straight, [5]int{9, 8, 7, 6, 5}},
+	}
+
+	for _, tt := range tests {
+		v, err := evaluateHand(cardsFromCodes(t, tt.cards))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.cards, err)
+		}
+		if v.Category != tt.category || v.Ranks != tt.ranks {
+			t.Errorf("%s: expected %v %v, got %v %v", tt.cards, tt.category, tt.ranks, v.Category, v.Ranks)
+		}
+		if len(v.Cards) != 5 {
+			t.Errorf("%s: expected the best 5 cards, got %v", tt.cards, v.Cards)
+		}
+	}
+}
+
+func TestEvaluateHandErrors(t *testing.T) {
+	for _, d := range []deck{
+		cardsFromCodes(t, "As Ks Qs Js"),
+		cardsFromCodes(t, "As Ks Qs Js Ts 9s 8s 7s"),
+		cardsFromCodes(t, "As As Qs Js Ts"),
+		append(cardsFromCodes(t, "As Ks Qs Js"), Card{Rank: RedJoker, Suit: noSuit}),
+	} {
+		if _, err := evaluateHand(d); err == nil {
+			t.Errorf("Expected an error for %v", d)
+		}
+	}
+}
+
+// Kickers decide between hands of the same category.
+func TestHandValueCompare(t *testing.T) {
+	tests := []struct {
+		stronger, weaker string
+	}{
+		{"Ts Td Ah 4c 3d", "Ts Td Kh 4c 3d"},
+		{"Ts Td Ah 5c 3d", "Th Tc Ad 4c 3s"},
+		{"Js Jd 4h 4c 3d", "Ts Td 9h 9c Ad"},
+		{"Js Jd 5h 5c 2d", "Jh Jc 4s 4c Ad"},
+		{"Js Jd 5h 5c 3d", "Jh Jc 5s 5d 2d"},
+		{"6s 7d 8h 9c Td", "As 2d 3h 4c 5d"},
+		{"2c 9c Jc 4c Kc", "2d 9d Jd 3d Kd"},
+		{"3s 3d 3h 2c 2d", "2s 2h 2c Ac Ad"},
+		{"As Kd Qh Jc 9d", "Ks Qd Jh Tc 8d"},
+		{"2s 3d 4h 5c 6d", "As 2d 3h 4c 5d"},
+		{"As 2d 3h 4c 5d", "As Kd Qh Jc 9d"},
+	}
+
+	for _, tt := range tests {
+		a, _ := evaluateHand(cardsFromCodes(t, tt.stronger))
+		b, _ := evaluateHand(cardsFromCodes(t, tt.weaker))
+		if a.compare(b) <= 0 || b.compare(a) >= 0 {
+			t.Errorf("Expected %s to beat %s", tt.stronger, tt.weaker)
+		}
+	}
+
+	a, _ := evaluateHand(cardsFromCodes(t, "As Kd Qh Jc 9d"))
+	b, _ := evaluateHand(cardsFromCodes(t, "Ac Kh Qd Js 9s"))
+	if a.compare(b) != 0 {
+		t.Errorf("Expected the same ranks in different suits to tie")
+	}
+}
+
+func TestCompareHands(t *testing.T) {
+	board := cardsFromCodes(t, "Ah Kh 7d 7c 2s")
+	hands := []deck{
+		cardsFromCodes(t, "Qs Js"), // pair of sevens, A K Q
+		cardsFromCodes(t, "As 3d"), // two pairs, aces and sevens
+		cardsFromCodes(t, "Ad 4d"), // two pairs, aces and sevens - the same hand as player 1
+		cardsFromCodes(t, "7s 2h"), // full house
+	}
+
+	s, err := compareHands(hands, board)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Winners) != 1 || s.Winners[0] != 3 || s.split() {
+		t.Errorf("Expected player 3 to win alone, got %v", s.Winners)
+	}
+	places := map[int]int{}
+	for _, r := range s.Results {
+		places[r.Player] = r.Place
+	}
+	if places[3] != 1 || places[1] != 2 || places[2] != 2 || places[0] != 3 {
+		t.Errorf("Unexpected places %v", places)
+	}
+
+	// the board plays for everybody - split pot
+	board = cardsFromCodes(t, "Ts Js Qs Ks As")
+	s, err = compareHands([]deck{cardsFromCodes(t, "2d 3d"), cardsFromCodes(t, "4c 5c")}, board)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.split() || len(s.Winners) != 2 {
+		t.Errorf("Expected a split pot, got %v", s.Winners)
+	}
+}
+
+// The hands produced by the dealer can be evaluated straight away.
+func TestCompareDealtHands(t *testing.T) {
+	dl := newSeededDealer(5)
+	hands, err := dl.dealHands(6, 2, dealRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl.flop()
+	dl.turn()
+	dl.river()
+
+	s, err := compareHands(hands, dl.board)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Results) != 6 || len(s.Winners) == 0 {
+		t.Errorf("Expected 6 results and a winner, got %+v", s)
+	}
+}
+
+// Count the categories of all 2,598,960 five card hands against the well known numbers.
+func TestEvaluateFiveAllHands(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the exhaustive count in short mode")
+	}
+	expected := map[handCategory]int{
+		royalFlush:    4,
+		straightFlush: 36,
+		fourOfAKind:   624,
+		fullHouse:     3744,
+		flush:         5108,
+		straight:      10200,
+		threeOfAKind:  54912,
+		twoPair:       123552,
+		onePair:       1098240,
+		highCard:      1302540,
+	}
+
+	d := newDeck()
+	counts := map[handCategory]int{}
+	for a := 0; a < 52; a++ {
+		for b := a + 1; b < 52; b++ {
+			for c := b + 1; c < 52; c++ {
+				for e := c + 1; e < 52; e++ {
+					for f := e + 1; f < 52; f++ {
+						counts[evaluateFive([5]Card{d[a], d[b], d[c], d[e], d[f]}).Category]++
+					}
+				}
+			}
+		}
+	}
+
+	for category, want := range expected {
+		if counts[category] != want {
+			t.Errorf("%v: expected %v hands, got %v", category, want, counts[category])
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// War ( war.go ) and Go Fish ( gofish.go ) are played the way a concurrent program is built:
+// every player is a goroutine that owns its cards and nobody else touches them. The referee,
+// the goroutine running the game, sends every player messages on its own channel and waits for
+// the answers on a reply channel. The referee keeps a ledger of every card it gave out, so a
+// player that answers with cards it doesn't hold is caught, and a player that doesn't answer in
+// time forfeits instead of blocking the game.
+
+// defaultMoveTimeout is how long the referee waits for a player.
+const defaultMoveTimeout = time.Second
+
+// awaitCards waits for the cards a player answers with, false when they didn't come in time.
+// The reply channels have room for one answer, so a player that answers late doesn't block forever.
+func awaitCards(reply <-chan deck, timeout time.Duration) (deck, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case cards := <-reply:
+		return cards, true
+	case <-timer.C:
+		return nil, false
+	}
+}
+
+// sameHand tells if the two decks hold the same cards, in any order.
+func sameHand(a, b deck) bool {
+	counts := map[Card]int{}
+	for _, card := range a {
+		counts[card]++
+	}
+	for _, card := range b {
+		counts[card]--
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// refereeResult is the end of one game.
+type refereeResult struct {
+	// Winners are the players with the best result, more than one for a tie and none for a game
+	// stopped after too many turns.
+	Winners []int
+	// Turns is the length of the game: battles for War, questions for Go Fish.
+	Turns int
+	// Forfeits are the players that didn't answer in time or answered against the rules,
+	// Reason says what the last of them did.
+	Forfeits []int
+	Reason   string
+}
+
+// gameStats sums up many games of the same kind.
+type gameStats struct {
+	Games   int
+	Players int
+	// Wins counts the games won by every player, a tie counts for all its winners.
+	Wins     []int
+	Draws    int
+	Forfeits int
+	// The length of the games, in turns. Margin is the half width of the 95% confidence interval of the mean.
+	Mean, StdDev, Margin float64
+	Min, Median, Max     int
+}
+
+// simulateGames plays the games with the seeds seed, seed+1, ... and sums them up.
+// Every game starts its own goroutines, so they are played one after the other.
+func simulateGames(games, players int, seed int64, play func(seed int64) (refereeResult, error)) (gameStats, error) {
+	s := gameStats{Games: games, Players: players, Wins: make([]int, players)}
+	lengths := make([]int, 0, games)
+	for i := range games {
+		res, err := play(seed + int64(i))
+		if err != nil {
+			return s, fmt.Errorf("game %d: %w", i+1, err)
+		}
+		lengths = append(lengths, res.Turns)
+		if len(res.Winners) != 1 {
+			s.Draws++
+		}
+		for _, p := range res.Winners {
+			s.Wins[p]++
+		}
+		s.Forfeits += len(res.Forfeits)
+	}
+	if games == 0 {
+		return s, nil
+	}
+
+	slices.Sort(lengths)
+	s.Min, s.Max, s.Median = lengths[0], lengths[len(lengths)-1], lengths[len(lengths)/2]
+	sum := 0.0
+	for _, n := range lengths {
+		sum += float64(n)
+	}
+	s.Mean = sum / float64(games)
+	if games > 1 {
+		squares := 0.0
+		for _, n := range lengths {
+			squares += (float64(n) - s.Mean) * (float64(n) - s.Mean)
+		}
+		s.StdDev = math.Sqrt(squares / float64(games-1))
+		s.Margin = 1.96 * s.StdDev / math.Sqrt(float64(games))
+	}
+	return s, nil
+}
+
+func (s gameStats) print(w io.Writer, name, turns string) {
+	fmt.Fprintf(w, "%d games of %s, %d players\n", s.Games, name, s.Players)
+	fmt.Fprintf(w, "Length: %.1f ± %.1f %s ( standard deviation %.1f ), median %d, shortest %d, longest %d\n",
+		s.Mean, s.Margin, turns, s.StdDev, s.Median, s.Min, s.Max)
+	for p, wins := range s.Wins {
+		rate := float64(wins) / float64(s.Games)
+		fmt.Fprintf(w, "Player %d: %d wins, %s\n", p+1, wins, percent(rate, binomialMargin(rate, s.Games)))
+	}
+	fmt.Fprintf(w, "Draws: %d, forfeits: %d\n", s.Draws, s.Forfeits)
+}
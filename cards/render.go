@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The renderer draws cards for the terminal, side by side and wrapped at the terminal width:
+//
+//	compact  AS 2D 3H              - the short codes, parseCompact reads them back
+//	glyph    🂡 🃂 🂳                - the Unicode playing card characters
+//	box      ┌─────┐ ┌─────┐       - a drawing of every card
+//	         │A    │ │2    │
+//	         │  ♠  │ │  ♦  │
+//	         │    A│ │    2│
+//	         └─────┘ └─────┘
+//	ascii    the same boxes with +-| borders and S D H C, for terminals without Unicode
+//
+// The red suits are colored with ANSI codes, but only when the output is a terminal.
+
+type renderStyle string
+
+const (
+	renderCompact renderStyle = "compact"
+	renderGlyph   renderStyle = "glyph"
+	renderBox     renderStyle = "box"
+	renderASCII   renderStyle = "ascii"
+)
+
+var renderStyles = []renderStyle{renderCompact, renderGlyph, renderBox, renderASCII}
+
+// defaultRenderWidth is the width used when the terminal doesn't tell ( $COLUMNS isn't set ).
+const defaultRenderWidth = 80
+
+const (
+	ansiRed   = "\x1b[31m"
+	ansiReset = "\x1b[0m"
+)
+
+// renderer draws cards in one style. color turns the ANSI codes on, width is where the lines wrap.
+type renderer struct {
+	style renderStyle
+	color bool
+	width int
+}
+
+// newRenderer makes a renderer for w: colors only when w is a terminal and NO_COLOR isn't set
+// ( CLICOLOR_FORCE turns them on anyway, e.g. for less -R ), the width comes from $COLUMNS.
+func newRenderer(w io.Writer, style renderStyle) renderer {
+	color := isTerminal(w) && os.Getenv("NO_COLOR") == ""
+	if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		color = true
+	}
+	width := defaultRenderWidth
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		width = n
+	}
+	return renderer{style: style, color: color, width: width}
+}
+
+// isTerminal reports whether w is a terminal ( a character device ), not a file or a pipe.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func parseRenderStyle(name string) (renderStyle, bool) {
+	for _, s := range renderStyles {
+		if string(s) == name {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// render writes the cards of d side by side, as many on a line as fit in the width.
+func (r renderer) render(w io.Writer, d deck) error {
+	if len(d) == 0 {
+		return nil
+	}
+	if r.style == renderBox || r.style == renderASCII {
+		return r.renderBoxes(w, d)
+	}
+
+	var line strings.Builder
+	used := 0
+	for _, card := range d {
+		text, cells := card.code(), 2
+		if r.style == renderGlyph {
+			// most terminals draw the glyphs one cell wide, some two - leave room for both
+			text = card.glyph()
+		}
+		if used > 0 && used+1+cells > r.width {
+			fmt.Fprintln(&line)
+			used = 0
+		}
+		if used > 0 {
+			line.WriteByte(' ')
+			used++
+		}
+		line.WriteString(r.paint(text, card.red()))
+		used += cells
+	}
+	line.WriteByte('\n')
+	_, err := io.WriteString(w, line.String())
+	return err
+}
+
+// boxWidth is the width of a drawn card, the borders included.
+const boxWidth = 7
+
+func (r renderer) renderBoxes(w io.Writer, d deck) error {
+	perLine := max(1, (r.width+1)/(boxWidth+1))
+	var out strings.Builder
+	for start := 0; start < len(d); start += perLine {
+		row := d[start:min(start+perLine, len(d))]
+		boxes := make([][]string, len(row))
+		for i, card := range row {
+			boxes[i] = r.box(card)
+		}
+		for line := range boxes[0] {
+			for i, box := range boxes {
+				if i > 0 {
+					out.WriteByte(' ')
+				}
+				out.WriteString(r.paint(box[line], row[i].red()))
+			}
+			out.WriteByte('\n')
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// box draws one card, 5 lines of boxWidth cells.
+func (r renderer) box(c Card) []string {
+	label := c.label()
+	symbol := c.Suit.symbol(r.style == renderASCII)
+	if c.Suit == noSuit {
+		symbol = "*"
+	}
+	top, bottom, side := "┌─────┐", "└─────┘", "│"
+	if r.style == renderASCII {
+		top, bottom, side = "+-----+", "+-----+", "|"
+	}
+	inner := boxWidth - 2
+	pad := func(s string, left int) string {
+		n := utf8.RuneCountInString(s)
+		return side + strings.Repeat(" ", left) + s + strings.Repeat(" ", max(inner-left-n, 0)) + side
+	}
+	center := (inner - utf8.RuneCountInString(symbol)) / 2
+	return []string{
+		top,
+		pad(label, 0),
+		pad(symbol, center),
+		pad(label, max(inner-utf8.RuneCountInString(label), 0)),
+		bottom,
+	}
+}
+
+func (r renderer) paint(s string, red bool) string {
+	if !r.color || !red {
+		return s
+	}
+	return ansiRed + s + ansiReset
+}
+
+// red reports whether the card is printed in red: the Diamonds, the Hearts and the Red Joker.
+func (c Card) red() bool {
+	if c.Suit == noSuit {
+		return c.Rank == RedJoker
+	}
+	return c.Suit.red()
+}
+
+// symbol is the suit sign, ♠ ♦ ♥ ♣ or S D H C in ASCII. The suits of other sets ( Uno's
+// colors ) use their first letter.
+func (s Suit) symbol(ascii bool) string {
+	if s >= Spades && s <= Clubs {
+		if ascii {
+			return string("SDHC"[s])
+		}
+		return []string{"♠", "♦", "♥", "♣"}[s]
+	}
+	if s == noSuit {
+		return ""
+	}
+	name, _ := utf8.DecodeRuneInString(s.String())
+	return string(name)
+}
+
+// label is the short rank written in the corners of a box: "A", "10", "K", "JK" for the jokers.
+// The ranks of other sets are shortened from their name, "Kn" for the Knight, "T21" for Trump 21.
+func (c Card) label() string {
+	switch {
+	case c.Rank >= Ace && c.Rank <= King:
+		return []string{"A", "2", "3", "4", "5", "6", "7", "8", "9", "10", "J", "Q", "K"}[c.Rank-1]
+	case c.isJoker():
+		return "JK"
+	}
+	words := strings.Fields(c.Rank.String())
+	if len(words) == 1 {
+		r := []rune(words[0])
+		return string(r[:min(2, len(r))])
+	}
+	label := ""
+	for _, word := range words {
+		if _, err := strconv.Atoi(word); err == nil {
+			label += word
+		} else {
+			first, _ := utf8.DecodeRuneInString(word)
+			label += string(first)
+		}
+	}
+	return label
+}
+
+// glyph is the Unicode playing card character of the card. The block has a Knight between the
+// Jack and the Queen, which the French deck skips. Cards outside the block print as their label.
+func (c Card) glyph() string {
+	switch {
+	case c.Rank == BlackJoker:
+		return "\U0001F0CF"
+	case c.Rank == RedJoker:
+		return "\U0001F0BF"
+	case !c.valid():
+		return c.label()
+	}
+	base := []rune{0x1F0A0, 0x1F0C0, 0x1F0B0, 0x1F0D0}[c.Suit]
+	offset := rune(c.Rank)
+	if c.Rank >= Queen {
+		offset++
+	}
+	return string(base + offset)
+}
+
+// formatCompact writes the cards in the compact notation, e.g. "AS 2D 3H".
+func formatCompact(d deck) string {
+	codes := make([]string, len(d))
+	for i, card := range d {
+		codes[i] = card.code()
+	}
+	return strings.Join(codes, " ")
+}
+
+// parseCompact reads the compact notation back: codes separated by spaces, the rank
+// ( A 2-9 T J Q K, 10 works too ) and then the suit ( S D H C ), in any case. BJ and RJ are the jokers.
+func parseCompact(s string) (deck, error) {
+	d := deck{}
+	for _, code := range strings.Fields(s) {
+		card, err := parseCode(code)
+		if err != nil {
+			return nil, err
+		}
+		d = append(d, card)
+	}
+	return d, nil
+}
+
+func parseCode(code string) (Card, error) {
+	upper := strings.ToUpper(code)
+	switch upper {
+	case "BJ":
+		return Card{Rank: BlackJoker, Suit: noSuit}, nil
+	case "RJ":
+		return Card{Rank: RedJoker, Suit: noSuit}, nil
+	}
+	if len(upper) < 2 {
+		return Card{}, fmt.Errorf("%w %q", ErrUnknownCard, code)
+	}
+	rank, suit := upper[:len(upper)-1], upper[len(upper)-1]
+	if rank == "10" {
+		rank = "T"
+	}
+	r := strings.Index("A23456789TJQK", rank)
+	st := strings.IndexByte("SDHC", suit)
+	if len(rank) != 1 || r < 0 || st < 0 {
+		return Card{}, fmt.Errorf("%w %q", ErrUnknownCard, code)
+	}
+	return Card{Rank: Rank(r + 1), Suit: Suit(st)}, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompactRoundTrip(t *testing.T) {
+	d := newDeckWithJokers()
+	text := formatCompact(d)
+	if !strings.HasPrefix(text, "AS 2S 3S") || !strings.HasSuffix(text, "KC BJ RJ") {
+		t.Errorf("Expected the compact codes of the deck, got %q", text)
+	}
+	back, err := parseCompact(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameCards(back, d) || back[10] != d[10] {
+		t.Errorf("Expected the same deck back, got %v", back.toString())
+	}
+}
+
+func TestParseCompact(t *testing.T) {
+	tests := []struct {
+		s    string
+		want deck
+	}{
+		{"AS 2D 3H", deck{{Ace, Spades}, {Two, Diamonds}, {Three, Hearts}}},
+		{"  ah\tkd ", deck{{Ace, Hearts}, {King, Diamonds}}},
+		{"10C Tc", deck{{Ten, Clubs}, {Ten, Clubs}}},
+		{"rj", deck{{RedJoker, noSuit}}},
+		{"", deck{}},
+	}
+	for _, tt := range tests {
+		got, err := parseCompact(tt.s)
+		if err != nil || got.toString() != tt.want.toString() {
+			t.Errorf("Expected %v for %q, got %v ( %v )", tt.want, tt.s, got, err)
+		}
+	}
+	for _, bad := range []string{"A", "1S", "AX", "ASS", "??", "Ace of Spades"} {
+		if _, err := parseCompact(bad); !errors.Is(err, ErrUnknownCard) {
+			t.Errorf("Expected an unknown card for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestGlyph(t *testing.T) {
+	tests := []struct {
+		card Card
+		want string
+	}{
+		{Card{Ace, Spades}, "🂡"},
+		{Card{Jack, Hearts}, "🂻"},
+		// the Knight 🂼 is skipped
+		{Card{Queen, Hearts}, "🂽"},
+		{Card{King, Diamonds}, "🃎"},
+		{Card{Ten, Clubs}, "🃚"},
+		{Card{BlackJoker, noSuit}, "🃏"},
+	}
+	for _, tt := range tests {
+		if got := tt.card.glyph(); got != tt.want {
+			t.Errorf("Expected %s for %v, got %s", tt.want, tt.card, got)
+		}
+	}
+}
+
+func TestRenderBoxes(t *testing.T) {
+	hand := deck{{Ten, Hearts}, {Ace, Spades}, {RedJoker, noSuit}}
+
+	var buf bytes.Buffer
+	r := renderer{style: renderASCII, width: 16}
+	if err := r.render(&buf, hand); err != nil {
+		t.Fatal(err)
+	}
+	want := `+-----+ +-----+
+|10   | |A    |
+|  H  | |  S  |
+|   10| |    A|
++-----+ +-----+
++-----+
+|JK   |
+|  *  |
+|   JK|
++-----+
+`
+	if buf.String() != want {
+		t.Errorf("Expected 2 cards on the first line and the joker wrapped, got\n%s", buf.String())
+	}
+
+	buf.Reset()
+	r = renderer{style: renderBox, color: true, width: 80}
+	r.render(&buf, hand)
+	lines := strings.Split(buf.String(), "\n")
+	if lines[2] != ansiRed+"│  ♥  │"+ansiReset+" │  ♠  │ "+ansiRed+"│  *  │"+ansiReset {
+		t.Errorf("Expected the Hearts and the Red Joker in red, got %q", lines[2])
+	}
+}
+
+func TestRenderCompactWraps(t *testing.T) {
+	var buf bytes.Buffer
+	r := renderer{style: renderCompact, width: 9, color: true}
+	r.render(&buf, deck{{Ace, Spades}, {Two, Diamonds}, {Three, Hearts}, {Four, Clubs}})
+	want := "AS " + ansiRed + "2D" + ansiReset + " " + ansiRed + "3H" + ansiReset + "\n4C\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestRenderNoColorWithoutTerminal(t *testing.T) {
+	t.Setenv("CLICOLOR_FORCE", "")
+	t.Setenv("COLUMNS", "20")
+	var buf bytes.Buffer
+	if r := newRenderer(&buf, renderBox); r.color || r.width != 20 {
+		t.Errorf("Expected no color and a width of 20 for a buffer, got %+v", r)
+	}
+	t.Setenv("CLICOLOR_FORCE", "1")
+	if r := newRenderer(&buf, renderBox); !r.color {
+		t.Errorf("Expected CLICOLOR_FORCE to turn the colors on")
+	}
+}
+
+func TestRenderFormats(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "deal", "-seed", "3", "-hands", "2", "-size", "3", "-format", "compact")
+	if code != exitOK || !strings.Contains(stdout, "Hand 1:\n") || strings.Contains(stdout, "\x1b") {
+		t.Fatalf("Expected plain compact hands, got %v ( %s%s )", code, stdout, stderr)
+	}
+	first := strings.Split(stdout, "\n")[1]
+	hand, err := parseCompact(first)
+	if err != nil || len(hand) != 3 {
+		t.Errorf("Expected to read the hand %q back, got %v ( %v )", first, hand, err)
+	}
+
+	// evaluate takes the compact codes too
+	code, stdout, _ = runCLI(t, "", "evaluate", "AS KS QS JS TS")
+	if code != exitOK || !strings.HasPrefix(stdout, "Royal Flush") {
+		t.Errorf("Expected a royal flush, got %v ( %s )", code, stdout)
+	}
+	if code, _, _ := runCLI(t, "", "new", "-format", "poster"); code != exitUsage {
+		t.Errorf("Expected a usage error for an unknown format, got %v", code)
+	}
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// The HTTP deck service keeps decks in memory and hands out cards over JSON:
+//
+//	POST   /decks                     create a deck     {"decks": 1, "jokers": false, "shuffle": true, "seed": 42}
+//	GET    /decks/{id}                remaining counts and piles
+//	DELETE /decks/{id}                forget the deck
+//	POST   /decks/{id}/draw?count=N   draw N cards from the top
+//	POST   /decks/{id}/deal           deal hands        {"hands": 4, "size": 5}
+//	POST   /decks/{id}/return         put drawn cards back at the bottom  {"cards": ["Ace of Spades"]}
+//	POST   /decks/{id}/piles/{pile}   put drawn cards on a named pile     {"cards": ["Ace of Spades"]}
+//	GET    /decks/{id}/piles/{pile}   list a pile
+//
+// A deck that isn't used for the store's ttl is removed. The store holds at most maxDecks decks,
+// creating one more answers 503 until decks are deleted or expire.
+
+// storedDeck is one deck of the service. It's only touched with the store's lock held.
+type storedDeck struct {
+	id    string
+	cards deck
+	// out counts the cards that were drawn and not given back yet - only those can be returned or piled.
+	out      map[Card]int
+	piles    map[string]deck
+	seed     *int64
+	lastUsed time.Time
+}
+
+// deckStore holds the decks of the service, keyed by id. It's safe for concurrent use.
+type deckStore struct {
+	mu    sync.Mutex
+	decks map[string]*storedDeck
+	ttl   time.Duration
+	// maxDecks limits the decks stored at once, 0 for no limit.
+	maxDecks int
+	// now is time.Now, replaced in the tests to expire decks without waiting.
+	now func() time.Time
+}
+
+func newDeckStore(ttl time.Duration, maxDecks int) *deckStore {
+	return &deckStore{decks: map[string]*storedDeck{}, ttl: ttl, maxDecks: maxDecks, now: time.Now}
+}
+
+var (
+	// errDeckNotFound is returned for an unknown or expired deck id.
+	errDeckNotFound = errors.New("deck not found")
+	// errStoreFull is returned for a new deck when the store holds maxDecks decks.
+	errStoreFull = &httpError{status: http.StatusServiceUnavailable, msg: "too many decks, try again later"}
+)
+
+// create stores a new deck and returns its state.
+func (s *deckStore) create(cards deck, seed *int64) (deckState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxDecks > 0 && len(s.decks) >= s.maxDecks {
+		// the janitor may not have run yet, make room with the decks that already expired
+		for id, d := range s.decks {
+			if s.expired(d) {
+				delete(s.decks, id)
+			}
+		}
+		if len(s.decks) >= s.maxDecks {
+			return deckState{}, errStoreFull
+		}
+	}
+	d := &storedDeck{
+		id:       randomID(),
+		cards:    cards,
+		out:      map[Card]int{},
+		piles:    map[string]deck{},
+		seed:     seed,
+		lastUsed: s.now(),
+	}
+	s.decks[d.id] = d
+	return d.state(), nil
+}
+
+// with runs fn on the deck with the store locked, so fn sees and changes the deck on its own.
+// fn returns the answer to the request: it's written after the lock is released, so it must
+// not share anything with the deck - a slow client would otherwise hold up every other request.
+func (s *deckStore) with(id string, fn func(d *storedDeck) (any, error)) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.decks[id]
+	if !ok || s.expired(d) {
+		delete(s.decks, id)
+		return nil, errDeckNotFound
+	}
+	d.lastUsed = s.now()
+	return fn(d)
+}
+
+func (s *deckStore) remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.decks[id]; !ok {
+		return errDeckNotFound
+	}
+	delete(s.decks, id)
+	return nil
+}
+
+func (s *deckStore) expired(d *storedDeck) bool {
+	return s.ttl > 0 && s.now().Sub(d.lastUsed) > s.ttl
+}
+
+// expire removes every deck that wasn't used for the ttl and returns how many were removed.
+func (s *deckStore) expire() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for id, d := range s.decks {
+		if s.expired(d) {
+			delete(s.decks, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// janitor calls expire every interval until the context is cancelled.
+func (s *deckStore) janitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.expire()
+		}
+	}
+}
+
+// randomID is a random hex string for ids and tokens that must not be guessed.
+func randomID() string {
+	b := make([]byte, 8)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// take moves n cards from the top of the deck to the cards that are out.
+func (d *storedDeck) take(n int) (deck, error) {
+	if n < 0 {
+		return nil, badRequest("count can't be negative")
+	}
+	if n > len(d.cards) {
+		return nil, &InsufficientCardsError{Need: n, Have: len(d.cards)}
+	}
+	cards := append(deck{}, d.cards[:n]...)
+	d.cards = d.cards[n:]
+	for _, card := range cards {
+		d.out[card]++
+	}
+	return cards, nil
+}
+
+// giveBack checks that every card is out and takes them off the out counts.
+// Nothing changes when one of the cards isn't out.
+func (d *storedDeck) giveBack(cards deck) error {
+	need := map[Card]int{}
+	for _, card := range cards {
+		need[card]++
+		if need[card] > d.out[card] {
+			return badRequest(fmt.Sprintf("%v was not drawn from this deck", card))
+		}
+	}
+	for card, n := range need {
+		d.out[card] -= n
+		if d.out[card] == 0 {
+			delete(d.out, card)
+		}
+	}
+	return nil
+}
+
+// deckState is the JSON answer describing a deck.
+type deckState struct {
+	ID        string         `json:"id"`
+	Remaining int            `json:"remaining"`
+	Out       int            `json:"out"`
+	Seed      *int64         `json:"seed,omitempty"`
+	Piles     map[string]int `json:"piles"`
+	Ranks     map[string]int `json:"ranks"`
+	Suits     map[string]int `json:"suits"`
+}
+
+func (d *storedDeck) state() deckState {
+	st := deckState{ID: d.id, Remaining: len(d.cards), Seed: d.seed, Piles: map[string]int{}, Ranks: map[string]int{}, Suits: map[string]int{}}
+	for _, n := range d.out {
+		st.Out += n
+	}
+	for name, pile := range d.piles {
+		st.Piles[name] = len(pile)
+	}
+	for _, card := range d.cards {
+		st.Ranks[card.Rank.String()]++
+		if card.Suit != noSuit {
+			st.Suits[card.Suit.String()]++
+		}
+	}
+	return st
+}
+
+// httpError carries the status code that goes with an error.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func badRequest(msg string) error {
+	return &httpError{status: http.StatusBadRequest, msg: msg}
+}
+
+// deckServer is the HTTP handler of the deck service.
+type deckServer struct {
+	store *deckStore
+	mux   *http.ServeMux
+}
+
+func newDeckServer(store *deckStore) *deckServer {
+	s := &deckServer{store: store, mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /decks", s.handleCreate)
+	s.mux.HandleFunc("GET /decks/{id}", s.handleState)
+	s.mux.HandleFunc("DELETE /decks/{id}", s.handleDelete)
+	s.mux.HandleFunc("POST /decks/{id}/draw", s.handleDraw)
+	s.mux.HandleFunc("POST /decks/{id}/deal", s.handleDeal)
+	s.mux.HandleFunc("POST /decks/{id}/return", s.handleReturn)
+	s.mux.HandleFunc("POST /decks/{id}/piles/{pile}", s.handleAddToPile)
+	s.mux.HandleFunc("GET /decks/{id}/piles/{pile}", s.handlePile)
+	return s
+}
+
+func (s *deckServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError picks the status code from the error: 404 for unknown decks, 409 when the deck
+// runs out of cards, 400 for bad requests and 500 for anything else.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var httpErr *httpError
+	var insufficient *InsufficientCardsError
+	switch {
+	case errors.Is(err, errDeckNotFound):
+		status = http.StatusNotFound
+	case errors.As(err, &insufficient):
+		status = http.StatusConflict
+	case errors.As(err, &httpErr):
+		status = httpErr.status
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// readJSON decodes the request body into v. An empty body leaves v as it is.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	if r.ContentLength == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return badRequest("bad JSON body: " + err.Error())
+	}
+	return nil
+}
+
+type createRequest struct {
+	Decks   int    `json:"decks"`
+	Jokers  bool   `json:"jokers"`
+	Shuffle bool   `json:"shuffle"`
+	Seed    *int64 `json:"seed"`
+}
+
+// maxServedDecks keeps a single request from filling the memory.
+const maxServedDecks = 100
+
+func (s *deckServer) handleCreate(w http.ResponseWriter, r *http.Request) {
+	req := createRequest{Decks: 1}
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Decks < 1 || req.Decks > maxServedDecks {
+		writeError(w, badRequest(fmt.Sprintf("decks must be between 1 and %d", maxServedDecks)))
+		return
+	}
+
+	cards := deck{}
+	for range req.Decks {
+		if req.Jokers {
+			cards = append(cards, newDeckWithJokers()...)
+		} else {
+			cards = append(cards, newDeck()...)
+		}
+	}
+	// a seed means shuffle, even without "shuffle": true
+	if req.Seed != nil {
+		cards.shuffleSeed(*req.Seed)
+	} else if req.Shuffle {
+		cards.cryptoShuffle()
+	}
+
+	st, err := s.store.create(cards, req.Seed)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, st)
+}
+
+func (s *deckServer) handleState(w http.ResponseWriter, r *http.Request) {
+	v, err := s.store.with(r.PathValue("id"), func(d *storedDeck) (any, error) {
+		return d.state(), nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *deckServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if err := s.store.remove(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *deckServer) handleDraw(w http.ResponseWriter, r *http.Request) {
+	count := 1
+	if c := r.URL.Query().Get("count"); c != "" {
+		if _, err := fmt.Sscan(c, &count); err != nil {
+			writeError(w, badRequest("count must be a number"))
+			return
+		}
+	}
+	v, err := s.store.with(r.PathValue("id"), func(d *storedDeck) (any, error) {
+		cards, err := d.take(count)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"cards": cards, "remaining": len(d.cards)}, nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+type dealRequest struct {
+	Hands int `json:"hands"`
+	Size  int `json:"size"`
+}
+
+func (s *deckServer) handleDeal(w http.ResponseWriter, r *http.Request) {
+	var req dealRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Hands < 1 || req.Size < 1 {
+		writeError(w, badRequest("hands and size must be at least 1"))
+		return
+	}
+	v, err := s.store.with(r.PathValue("id"), func(d *storedDeck) (any, error) {
+		// bound both numbers by the deck first, so their product can't overflow
+		if n := len(d.cards); req.Hands > n || req.Size > n {
+			return nil, badRequest(fmt.Sprintf("can't deal %d hands of %d cards from %d cards", req.Hands, req.Size, n))
+		}
+		if need := req.Hands * req.Size; need > len(d.cards) {
+			return nil, &InsufficientCardsError{Need: need, Have: len(d.cards)}
+		}
+		// the dealer works on a copy, the cards it dealt are then taken off the stored deck
+		dl := newDealer(d.cards)
+		hands, err := dl.dealHands(req.Hands, req.Size, dealRoundRobin)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := d.take(req.Hands * req.Size); err != nil {
+			return nil, err
+		}
+		return map[string]any{"hands": hands, "remaining": len(d.cards)}, nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+type cardsRequest struct {
+	Cards deck `json:"cards"`
+}
+
+func (s *deckServer) handleReturn(w http.ResponseWriter, r *http.Request) {
+	var req cardsRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := s.store.with(r.PathValue("id"), func(d *storedDeck) (any, error) {
+		if err := d.giveBack(req.Cards); err != nil {
+			return nil, err
+		}
+		d.cards = append(d.cards, req.Cards...)
+		return d.state(), nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *deckServer) handleAddToPile(w http.ResponseWriter, r *http.Request) {
+	var req cardsRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	pile := r.PathValue("pile")
+	v, err := s.store.with(r.PathValue("id"), func(d *storedDeck) (any, error) {
+		if err := d.giveBack(req.Cards); err != nil {
+			return nil, err
+		}
+		d.piles[pile] = append(d.piles[pile], req.Cards...)
+		return map[string]any{"pile": pile, "cards": slices.Clone(d.piles[pile])}, nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *deckServer) handlePile(w http.ResponseWriter, r *http.Request) {
+	pile := r.PathValue("pile")
+	v, err := s.store.with(r.PathValue("id"), func(d *storedDeck) (any, error) {
+		cards, ok := d.piles[pile]
+		if !ok {
+			return nil, &httpError{status: http.StatusNotFound, msg: fmt.Sprintf("no pile %q", pile)}
+		}
+		return map[string]any{"pile": pile, "cards": slices.Clone(cards)}, nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// runServe is the "cards serve" command.
+func runServe(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "serve")
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	ttl := fs.Duration("ttl", 30*time.Minute, "remove decks that are not used for this long")
+	maxDecks := fs.Int("max-decks", 10000, "the most decks kept at once, 0 for no limit")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *maxDecks < 0 {
+		return usagef("-max-decks can't be negative")
+	}
+
+	store := newDeckStore(*ttl, *maxDecks)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.janitor(ctx, max(*ttl/10, time.Second))
+
+	fmt.Fprintf(env.stdout, "Serving decks on http://%s\n", *addr)
+	return http.ListenAndServe(*addr, newDeckServer(store))
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// call sends a JSON request to the test server and decodes the answer into out ( when it's not nil ).
+func call(t *testing.T, srv *httptest.Server, method, path string, body any, out any) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, srv.URL+path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *deckStore) {
+	store := newDeckStore(time.Minute, 0)
+	srv := httptest.NewServer(newDeckServer(store))
+	t.Cleanup(srv.Close)
+	return srv, store
+}
+
+type drawAnswer struct {
+	Cards     deck `json:"cards"`
+	Remaining int  `json:"remaining"`
+}
+
+func TestServerCreateAndDraw(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	var st deckState
+	if code := call(t, srv, "POST", "/decks", map[string]any{"decks": 2, "jokers": true, "seed": 7}, &st); code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %v", code)
+	}
+	if st.Remaining != 108 {
+		t.Errorf("Expected 108 cards in 2 decks with jokers, got %v", st.Remaining)
+	}
+	if st.Ranks["Black Joker"] != 2 || st.Suits["Spades"] != 26 {
+		t.Errorf("Expected 2 Black Jokers and 26 Spades, got %v and %v", st.Ranks["Black Joker"], st.Suits["Spades"])
+	}
+
+	var drawn drawAnswer
+	if code := call(t, srv, "POST", "/decks/"+st.ID+"/draw?count=5", nil, &drawn); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %v", code)
+	}
+	if len(drawn.Cards) != 5 || drawn.Remaining != 103 {
+		t.Errorf("Expected 5 cards and 103 left, got %v and %v", len(drawn.Cards), drawn.Remaining)
+	}
+
+	// the same seed deals the same cards
+	expected := deck{}
+	for range 2 {
+		expected = append(expected, newDeckWithJokers()...)
+	}
+	expected.shuffleSeed(7)
+	if expected[:5].toString() != drawn.Cards.toString() {
+		t.Errorf("Expected %v, got %v", expected[:5].toString(), drawn.Cards.toString())
+	}
+
+	if code := call(t, srv, "POST", "/decks/"+st.ID+"/draw?count=200", nil, nil); code != http.StatusConflict {
+		t.Errorf("Expected 409 when drawing more than is left, got %v", code)
+	}
+}
+
+func TestServerDeal(t *testing.T) {
+	srv, _ := newTestServer(t)
+	var st deckState
+	call(t, srv, "POST", "/decks", map[string]any{"seed": 1}, &st)
+
+	var dealt struct {
+		Hands     []deck `json:"hands"`
+		Remaining int    `json:"remaining"`
+	}
+	if code := call(t, srv, "POST", "/decks/"+st.ID+"/deal", dealRequest{Hands: 4, Size: 5}, &dealt); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %v", code)
+	}
+	if len(dealt.Hands) != 4 || dealt.Remaining != 32 {
+		t.Fatalf("Expected 4 hands and 32 cards left, got %v and %v", len(dealt.Hands), dealt.Remaining)
+	}
+	// round robin: the first card of the deck goes to player 1, the second to player 2
+	d := newDeck()
+	d.shuffleSeed(1)
+	if dealt.Hands[0][0] != d[0] || dealt.Hands[1][0] != d[1] {
+		t.Errorf("Expected the hands to be dealt round robin")
+	}
+
+	if code := call(t, srv, "POST", "/decks/"+st.ID+"/deal", dealRequest{Hands: 10, Size: 5}, nil); code != http.StatusConflict {
+		t.Errorf("Expected 409 when dealing more than is left, got %v", code)
+	}
+	if code := call(t, srv, "POST", "/decks/"+st.ID+"/deal", dealRequest{}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for 0 hands, got %v", code)
+	}
+	// 2^32 * 2^32 overflows to 0, it must not get as far as the dealer
+	for _, body := range []map[string]any{
+		{"hands": 4294967296, "size": 4294967296},
+		{"hands": 4294967296, "size": 1},
+		{"hands": 1, "size": 1 << 40},
+	} {
+		if code := call(t, srv, "POST", "/decks/"+st.ID+"/deal", body, nil); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %v, got %v", body, code)
+		}
+	}
+}
+
+func TestServerMaxDecks(t *testing.T) {
+	store := newDeckStore(time.Minute, 2)
+	srv := httptest.NewServer(newDeckServer(store))
+	t.Cleanup(srv.Close)
+
+	var first deckState
+	for i := range 2 {
+		if code := call(t, srv, "POST", "/decks", nil, &first); code != http.StatusCreated {
+			t.Fatalf("deck %d: Expected 201, got %v", i+1, code)
+		}
+	}
+	if code := call(t, srv, "POST", "/decks", nil, nil); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when the store is full, got %v", code)
+	}
+	call(t, srv, "DELETE", "/decks/"+first.ID, nil, nil)
+	if code := call(t, srv, "POST", "/decks", nil, nil); code != http.StatusCreated {
+		t.Errorf("Expected 201 after a deck was deleted, got %v", code)
+	}
+
+	// expired decks make room even before the janitor runs
+	now := time.Now()
+	store.now = func() time.Time { return now.Add(2 * time.Minute) }
+	if code := call(t, srv, "POST", "/decks", nil, nil); code != http.StatusCreated {
+		t.Errorf("Expected 201 once the decks expired, got %v", code)
+	}
+}
+
+func TestServerReturnAndPiles(t *testing.T) {
+	srv, _ := newTestServer(t)
+	var st deckState
+	call(t, srv, "POST", "/decks", nil, &st)
+	base := "/decks/" + st.ID
+
+	var drawn drawAnswer
+	call(t, srv, "POST", base+"/draw?count=3", nil, &drawn)
+
+	// cards that weren't drawn can't be given back
+	if code := call(t, srv, "POST", base+"/return", cardsRequest{Cards: deck{newDeck()[10]}}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for returning a card that is still in the deck, got %v", code)
+	}
+	if code := call(t, srv, "POST", base+"/piles/discard", map[string]any{"cards": []string{"Foo of Bars"}}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown card, got %v", code)
+	}
+
+	if code := call(t, srv, "POST", base+"/piles/discard", cardsRequest{Cards: drawn.Cards[:2]}, nil); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %v", code)
+	}
+	// the same card can't go to a pile twice
+	if code := call(t, srv, "POST", base+"/piles/discard", cardsRequest{Cards: drawn.Cards[:1]}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for piling a card twice, got %v", code)
+	}
+	if code := call(t, srv, "POST", base+"/return", cardsRequest{Cards: drawn.Cards[2:]}, &st); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %v", code)
+	}
+	if st.Remaining != 50 || st.Out != 0 || st.Piles["discard"] != 2 {
+		t.Errorf("Expected 50 left, 0 out and 2 discarded, got %v, %v and %v", st.Remaining, st.Out, st.Piles["discard"])
+	}
+
+	var pile struct {
+		Cards deck `json:"cards"`
+	}
+	call(t, srv, "GET", base+"/piles/discard", nil, &pile)
+	if pile.Cards.toString() != drawn.Cards[:2].toString() {
+		t.Errorf("Expected %v on the pile, got %v", drawn.Cards[:2].toString(), pile.Cards.toString())
+	}
+	if code := call(t, srv, "GET", base+"/piles/nope", nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown pile, got %v", code)
+	}
+
+	// the returned card went to the bottom
+	var all drawAnswer
+	call(t, srv, "POST", base+"/draw?count=50", nil, &all)
+	if all.Cards[49] != drawn.Cards[2] {
+		t.Errorf("Expected %v at the bottom, got %v", drawn.Cards[2], all.Cards[49])
+	}
+}
+
+func TestServerUnknownAndDeletedDecks(t *testing.T) {
+	srv, _ := newTestServer(t)
+	if code := call(t, srv, "GET", "/decks/nope", nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown deck, got %v", code)
+	}
+	if code := call(t, srv, "POST", "/decks", map[string]any{"decks": 0}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for 0 decks, got %v", code)
+	}
+	if code := call(t, srv, "POST", "/decks", map[string]any{"colour": "red"}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown field, got %v", code)
+	}
+
+	var st deckState
+	call(t, srv, "POST", "/decks", nil, &st)
+	if code := call(t, srv, "DELETE", "/decks/"+st.ID, nil, nil); code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %v", code)
+	}
+	if code := call(t, srv, "GET", "/decks/"+st.ID, nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted deck, got %v", code)
+	}
+}
+
+func TestServerExpiry(t *testing.T) {
+	srv, store := newTestServer(t)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	var old, used deckState
+	call(t, srv, "POST", "/decks", nil, &old)
+	call(t, srv, "POST", "/decks", nil, &used)
+
+	now = now.Add(50 * time.Second)
+	call(t, srv, "GET", "/decks/"+used.ID, nil, nil)
+	now = now.Add(20 * time.Second)
+
+	if removed := store.expire(); removed != 1 {
+		t.Errorf("Expected 1 deck to expire, got %v", removed)
+	}
+	if code := call(t, srv, "GET", "/decks/"+old.ID, nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an expired deck, got %v", code)
+	}
+	if code := call(t, srv, "GET", "/decks/"+used.ID, nil, nil); code != http.StatusOK {
+		t.Errorf("Expected the deck used 20s ago to stay, got %v", code)
+	}
+
+	// a deck past the ttl is gone even before the janitor runs
+	now = now.Add(2 * time.Minute)
+	if code := call(t, srv, "GET", "/decks/"+used.ID, nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an expired deck, got %v", code)
+	}
+}
+
+func TestServerConcurrentDraws(t *testing.T) {
+	srv, _ := newTestServer(t)
+	var st deckState
+	call(t, srv, "POST", "/decks", map[string]any{"decks": 4, "shuffle": true}, &st)
+
+	var mu sync.Mutex
+	seen := map[Card]int{}
+	var wg sync.WaitGroup
+	for range 26 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var drawn drawAnswer
+			if code := call(t, srv, "POST", fmt.Sprintf("/decks/%s/draw?count=%d", st.ID, 8), nil, &drawn); code != http.StatusOK {
+				t.Errorf("Expected 200, got %v", code)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, card := range drawn.Cards {
+				seen[card]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 26 draws of 8 is the whole 208 card deck, every card 4 times
+	if len(seen) != 52 {
+		t.Errorf("Expected all 52 cards to be drawn, got %v", len(seen))
+	}
+	for card, n := range seen {
+		if n != 4 {
+			t.Errorf("Expected %v 4 times, got %v", card, n)
+		}
+	}
+}
+
+// stalledWriter is a client that doesn't read its answer: Write blocks until release is closed.
+type stalledWriter struct {
+	header  http.Header
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (w *stalledWriter) Header() http.Header { return w.header }
+func (w *stalledWriter) WriteHeader(int)     {}
+func (w *stalledWriter) Write(b []byte) (int, error) {
+	close(w.writing)
+	<-w.release
+	return len(b), nil
+}
+
+func TestServerStalledClient(t *testing.T) {
+	srv, store := newTestServer(t)
+	var slow, fast deckState
+	call(t, srv, "POST", "/decks", nil, &slow)
+	call(t, srv, "POST", "/decks", nil, &fast)
+
+	w := &stalledWriter{header: http.Header{}, writing: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		newDeckServer(store).ServeHTTP(w, httptest.NewRequest("GET", "/decks/"+slow.ID, nil))
+	}()
+	<-w.writing
+
+	answered := make(chan int, 1)
+	go func() { answered <- call(t, srv, "POST", "/decks/"+fast.ID+"/draw", nil, nil) }()
+	select {
+	case code := <-answered:
+		if code != http.StatusOK {
+			t.Errorf("Expected 200, got %v", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected a stalled client not to hold up other requests")
+	}
+	close(w.release)
+	<-done
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// reshufflePolicy decides when a Shoe puts the discards back and shuffles.
+type reshufflePolicy int
+
+const (
+	// reshuffleAtPenetration shuffles after the hand in which the cut card came out.
+	reshuffleAtPenetration reshufflePolicy = iota
+	// reshuffleEachHand shuffles all the cards after every hand.
+	reshuffleEachHand
+	// reshuffleContinuous is a continuous shuffling machine: the discards of every hand
+	// go straight back into the shoe and are mixed with the cards left in it.
+	reshuffleContinuous
+)
+
+var reshufflePolicyNames = []string{"penetration", "each-hand", "continuous"}
+
+func (p reshufflePolicy) String() string {
+	if p < 0 || int(p) >= len(reshufflePolicyNames) {
+		return fmt.Sprintf("reshufflePolicy(%d)", int(p))
+	}
+	return reshufflePolicyNames[p]
+}
+
+func parseReshufflePolicy(name string) (reshufflePolicy, error) {
+	for i, n := range reshufflePolicyNames {
+		if n == name {
+			return reshufflePolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown reshuffle policy %q", name)
+}
+
+// ErrShoeEmpty is returned when there is no card left to draw, not even in the discard pile.
+var ErrShoeEmpty = errors.New("shoe is empty")
+
+// Shoe holds several decks shuffled together, like the shoe on a casino table.
+// Cards are drawn from the top, go to the players and come back with discard.
+// Every card is always in exactly one place: the shoe, the discard pile or in play.
+type Shoe struct {
+	decks       int
+	cards       deck
+	discards    deck
+	policy      reshufflePolicy
+	penetration float64
+	source      rand.Source
+	// dealt counts the cards drawn since the last full shuffle, to find the cut card.
+	dealt int
+}
+
+// newShoe puts decks new decks in the shoe and shuffles them with the source.
+// penetration is the part of the shoe dealt before the cut card, it's only used by reshuffleAtPenetration.
+func newShoe(decks int, policy reshufflePolicy, penetration float64, source rand.Source) (*Shoe, error) {
+	if decks < 1 {
+		return nil, fmt.Errorf("need at least 1 deck, got %d", decks)
+	}
+	if policy == reshuffleAtPenetration && (penetration <= 0 || penetration > 1) {
+		return nil, fmt.Errorf("penetration must be between 0 and 1, got %v", penetration)
+	}
+	s := &Shoe{decks: decks, policy: policy, penetration: penetration, source: source}
+	for range decks {
+		s.cards = append(s.cards, newDeck()...)
+	}
+	s.cards.shuffleWith(s.source)
+	return s, nil
+}
+
+// size is the number of cards the shoe holds when all of them are back.
+func (s *Shoe) size() int {
+	return s.decks * 52
+}
+
+// remaining is the number of cards left to draw before the next shuffle.
+func (s *Shoe) remaining() int {
+	return len(s.cards)
+}
+
+// discarded is the number of cards in the discard pile.
+func (s *Shoe) discarded() int {
+	return len(s.discards)
+}
+
+// draw takes the top card. An empty shoe is refilled from the discard pile first, like a dealer would.
+func (s *Shoe) draw() (Card, error) {
+	if len(s.cards) == 0 {
+		if len(s.discards) == 0 {
+			return Card{}, ErrShoeEmpty
+		}
+		s.reshuffle()
+	}
+	card := s.cards[0]
+	s.cards = s.cards[1:]
+	s.dealt++
+	return card, nil
+}
+
+// discard puts cards that were in play on the discard pile.
+func (s *Shoe) discard(cards ...Card) {
+	s.discards = append(s.discards, cards...)
+}
+
+// cutCardOut tells if the cards dealt since the shuffle reached the cut card.
+func (s *Shoe) cutCardOut() bool {
+	return float64(s.dealt) >= s.penetration*float64(s.size())
+}
+
+// endHand is called once all the cards of a hand are discarded. It applies the reshuffle policy
+// and reports whether the whole shoe was shuffled ( card counters start again from 0 then ).
+func (s *Shoe) endHand() bool {
+	switch s.policy {
+	case reshuffleEachHand:
+		s.reshuffle()
+		return true
+	case reshuffleContinuous:
+		s.cards = append(s.cards, s.discards...)
+		s.discards = s.discards[:0]
+		s.cards.shuffleWith(s.source)
+		return false
+	default:
+		if s.cutCardOut() {
+			s.reshuffle()
+			return true
+		}
+		return false
+	}
+}
+
+// reshuffle puts the discards back in the shoe and shuffles everything.
+// Cards still in play are not part of it - they come back with the next discard.
+func (s *Shoe) reshuffle() {
+	s.cards = append(s.cards, s.discards...)
+	s.discards = deck{}
+	s.cards.shuffleWith(s.source)
+	s.dealt = 0
+}
+
+// rankCounts is how many cards of every rank are left to draw, indexed by Rank ( index 0 is not used ).
+func (s *Shoe) rankCounts() []int {
+	counts := make([]int, King+1)
+	for _, card := range s.cards {
+		counts[card.Rank]++
+	}
+	return counts
+}
+
+// cardCounts is how many copies of every card are left to draw.
+func (s *Shoe) cardCounts() map[Card]int {
+	counts := map[Card]int{}
+	for _, card := range s.cards {
+		counts[card]++
+	}
+	return counts
+}
+
+// decksRemaining is the number of decks left to draw, the divisor of the true count.
+func (s *Shoe) decksRemaining() float64 {
+	return float64(len(s.cards)) / 52
+}
+
+// countSystem gives every rank a tag for card counting.
+type countSystem map[Rank]int
+
+// hiLo is the Hi-Lo count: 2 to 6 are +1, 7 to 9 are 0, tens and Aces are -1.
+var hiLo = countSystem{
+	Two: 1, Three: 1, Four: 1, Five: 1, Six: 1,
+	Ten: -1, Jack: -1, Queen: -1, King: -1, Ace: -1,
+}
+
+// runningCount is the count of every card seen since the last shuffle ( in play or discarded ).
+// It's worked out as the tags of the full shoe minus the tags of the cards still in it,
+// so it doesn't need to watch the table. With reshuffleContinuous the discards go back
+// in after every hand and the count stays near 0 - which is the point of a CSM.
+func (s *Shoe) runningCount(system countSystem) int {
+	count := 0
+	for _, rank := range standardRanks {
+		count += system[rank] * 4 * s.decks
+	}
+	for rank, n := range s.rankCounts() {
+		count -= system[Rank(rank)] * n
+	}
+	return count
+}
+
+// trueCount is the running count per deck left in the shoe.
+func (s *Shoe) trueCount(system countSystem) float64 {
+	decks := s.decksRemaining()
+	if decks == 0 {
+		return 0
+	}
+	return float64(s.runningCount(system)) / decks
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// shoeTotal is every card the shoe knows about: in the shoe plus discarded.
+func shoeTotal(s *Shoe) int {
+	return s.remaining() + s.discarded()
+}
+
+func TestNewShoe(t *testing.T) {
+	s, err := newShoe(6, reshuffleAtPenetration, 0.75, rand.NewSource(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.remaining() != 312 {
+		t.Errorf("Expected 312 cards, got %v", s.remaining())
+	}
+	for card, n := range s.cardCounts() {
+		if n != 6 {
+			t.Errorf("Expected 6 copies of %v, got %v", card, n)
+		}
+	}
+
+	if _, err := newShoe(0, reshuffleEachHand, 0, nil); err == nil {
+		t.Errorf("Expected an error for 0 decks")
+	}
+	if _, err := newShoe(1, reshuffleAtPenetration, 1.5, nil); err == nil {
+		t.Errorf("Expected an error for a penetration over 1")
+	}
+}
+
+// play draws a hand of n cards and discards it.
+func play(t *testing.T, s *Shoe, n int) bool {
+	t.Helper()
+	hand := deck{}
+	for range n {
+		card, err := s.draw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hand = append(hand, card)
+	}
+	s.discard(hand...)
+	return s.endHand()
+}
+
+func TestShoeReshuffleAtPenetration(t *testing.T) {
+	s, _ := newShoe(2, reshuffleAtPenetration, 0.5, rand.NewSource(2))
+	// 52 of 104 cards is the cut card - 5 hands of 10 cards don't reach it, the 6th does
+	for i := range 5 {
+		if play(t, s, 10) {
+			t.Fatalf("Unexpected reshuffle after hand %d", i+1)
+		}
+	}
+	if s.remaining() != 54 || s.discarded() != 50 {
+		t.Errorf("Expected 54 cards left and 50 discarded, got %v and %v", s.remaining(), s.discarded())
+	}
+	if !play(t, s, 10) {
+		t.Fatalf("Expected a reshuffle once the cut card came out")
+	}
+	if s.remaining() != 104 || s.discarded() != 0 {
+		t.Errorf("Expected a full shoe after the reshuffle, got %v and %v", s.remaining(), s.discarded())
+	}
+}
+
+func TestShoeReshuffleEachHandAndContinuous(t *testing.T) {
+	each, _ := newShoe(1, reshuffleEachHand, 0, rand.NewSource(3))
+	if !play(t, each, 5) || each.remaining() != 52 {
+		t.Errorf("Expected the shoe to be full after every hand, got %v", each.remaining())
+	}
+
+	csm, _ := newShoe(1, reshuffleContinuous, 0, rand.NewSource(3))
+	for range 100 {
+		play(t, csm, 7)
+		if csm.remaining() != 52 || csm.discarded() != 0 {
+			t.Fatalf("Expected the discards to go straight back, got %v and %v", csm.remaining(), csm.discarded())
+		}
+	}
+}
+
+// Cards are never lost or duplicated, even when the shoe runs out in the middle of a hand.
+func TestShoeKeepsEveryCard(t *testing.T) {
+	s, _ := newShoe(1, reshuffleAtPenetration, 1, rand.NewSource(4))
+	inPlay := deck{}
+	for range 200 {
+		card, err := s.draw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		inPlay = append(inPlay, card)
+		if len(inPlay) == 3 {
+			s.discard(inPlay...)
+			inPlay = deck{}
+			s.endHand()
+		}
+		if shoeTotal(s)+len(inPlay) != 52 {
+			t.Fatalf("Expected 52 cards in total, got %v", shoeTotal(s)+len(inPlay))
+		}
+	}
+
+	// with every card in play there is nothing left to draw
+	empty, _ := newShoe(1, reshuffleAtPenetration, 1, rand.NewSource(5))
+	for range 52 {
+		empty.draw()
+	}
+	if _, err := empty.draw(); err != ErrShoeEmpty {
+		t.Errorf("Expected %v, got %v", ErrShoeEmpty, err)
+	}
+}
+
+func TestShoeCounts(t *testing.T) {
+	s, _ := newShoe(2, reshuffleAtPenetration, 0.75, rand.NewSource(6))
+	if s.runningCount(hiLo) != 0 || s.trueCount(hiLo) != 0 {
+		t.Errorf("Expected a count of 0 for a fresh shoe")
+	}
+
+	seen := 0
+	for range 52 {
+		card, _ := s.draw()
+		seen += hiLo[card.Rank]
+	}
+	if got := s.runningCount(hiLo); got != seen {
+		t.Errorf("Expected running count %v, got %v", seen, got)
+	}
+	if got, want := s.trueCount(hiLo), float64(seen)/1; got != want {
+		t.Errorf("Expected true count %v with one deck left, got %v", want, got)
+	}
+
+	total := 0
+	for rank, n := range s.rankCounts() {
+		if rank == 0 && n != 0 {
+			t.Errorf("Expected no cards of rank 0")
+		}
+		total += n
+	}
+	if total != 52 {
+		t.Errorf("Expected the rank counts to add up to 52, got %v", total)
+	}
+}
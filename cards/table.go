@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The table server lets several terminals play on one deck over TCP. The protocol is one
+// command per line, the first word is the command and the rest its argument.
+//
+// Client to server:
+//
+//	JOIN <name>        take a seat, answered with WELCOME <seat> <token>
+//	RESUME <token>     come back to the seat after the connection dropped
+//	START [hand size]  deal a new game ( 7 cards when not given )
+//	DRAW               take the top card of the deck - ends the turn
+//	PLAY <card>        play a card of the same suit or rank as the top of the pile - ends the turn
+//	PASS               end the turn - when the deck is empty and everybody passed in a row, the game ends without a winner
+//	HAND               show the hand again
+//	STATE              show the table again
+//	SAY <text>         talk to the table
+//	QUIT               leave, the seat is kept for RESUME
+//
+// Server to client:
+//
+//	OK                 the command was done
+//	ERR <reason>       the command was refused
+//	WELCOME <seat> <token>
+//	HAND <cards>       the private hand, the card names separated by ","
+//	STATE <json>       the public table ( tableState ), sent to everybody after every change
+//	EVENT <text>       something happened at the table
+//
+// The game is a simple shedding game: the first player without cards wins. A player who is away
+// on their turn has awayTimeout to RESUME, after that the turn is skipped as a PASS.
+
+// defaultHandSize is the hand dealt by START without a size.
+const defaultHandSize = 7
+
+// defaultAwayTimeout is how long the table waits for a player who is away on their turn.
+const defaultAwayTimeout = 30 * time.Second
+
+// tableState is what everybody at the table can see.
+type tableState struct {
+	Started bool          `json:"started"`
+	Turn    int           `json:"turn"`
+	Deck    int           `json:"deck"`
+	Pile    *Card         `json:"pile,omitempty"`
+	Players []tablePlayer `json:"players"`
+}
+
+type tablePlayer struct {
+	Seat      int    `json:"seat"`
+	Name      string `json:"name"`
+	Cards     int    `json:"cards"`
+	Connected bool   `json:"connected"`
+}
+
+// tableSeat is a player at the table. conn is nil while the player is away.
+type tableSeat struct {
+	index int
+	name  string
+	token string
+	hand  deck
+	conn  *tableConn
+}
+
+// table owns the deck and the seats. It's safe for concurrent use: every connection
+// runs in its own goroutine and the table is locked while a command is handled.
+type table struct {
+	mu       sync.Mutex
+	source   rand.Source
+	maxSeats int
+	seats    []*tableSeat
+	deck     deck
+	pile     deck
+	started  bool
+	turn     int
+	// passes counts the passes in a row with an empty deck, like sheddingGame.passes.
+	passes int
+	// awayTimeout is how long an away player's turn waits, 0 to wait for ever.
+	awayTimeout time.Duration
+	// moves counts the turns, so the skip timer of a turn that is already over does nothing.
+	moves int
+	skip  *time.Timer
+}
+
+// newTable makes a table for up to maxSeats players. The games are shuffled from the seed.
+func newTable(maxSeats int, seed int64) *table {
+	return &table{source: rand.NewSource(seed), maxSeats: maxSeats, deck: newDeck(), awayTimeout: defaultAwayTimeout}
+}
+
+// tableConn is one client connection. Messages are queued and written by their own goroutine,
+// so a slow client never holds the table lock. A client that falls too far behind is dropped.
+type tableConn struct {
+	c      net.Conn
+	mu     sync.Mutex
+	out    chan string
+	closed bool
+}
+
+func newTableConn(c net.Conn) *tableConn {
+	tc := &tableConn{c: c, out: make(chan string, 64)}
+	go tc.writeLoop()
+	return tc
+}
+
+func (tc *tableConn) writeLoop() {
+	defer tc.c.Close()
+	for line := range tc.out {
+		tc.c.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err := io.WriteString(tc.c, line+"\n"); err != nil {
+			return
+		}
+	}
+}
+
+func (tc *tableConn) send(format string, args ...any) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.closed {
+		return
+	}
+	select {
+	case tc.out <- fmt.Sprintf(format, args...):
+	default:
+		tc.closeLocked()
+	}
+}
+
+// close sends what is queued and then closes the connection.
+func (tc *tableConn) close() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.closeLocked()
+}
+
+func (tc *tableConn) closeLocked() {
+	if !tc.closed {
+		tc.closed = true
+		close(tc.out)
+	}
+}
+
+// serveTable accepts connections until the listener is closed.
+func serveTable(ln net.Listener, t *table) error {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go t.serveConn(c)
+	}
+}
+
+// serveConn reads the commands of one client until it quits or the connection drops.
+func (t *table) serveConn(c net.Conn) {
+	tc := newTableConn(c)
+	defer tc.close()
+
+	var seat *tableSeat
+	sc := bufio.NewScanner(c)
+	for sc.Scan() {
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(sc.Text()), " ")
+		cmd, arg = strings.ToUpper(cmd), strings.TrimSpace(arg)
+		if cmd == "" {
+			continue
+		}
+		if cmd == "QUIT" {
+			break
+		}
+		if seat != nil {
+			t.handle(seat, tc, cmd, arg)
+			continue
+		}
+
+		var err error
+		switch cmd {
+		case "JOIN":
+			seat, err = t.join(arg, tc)
+		case "RESUME":
+			seat, err = t.resume(arg, tc)
+		default:
+			err = errors.New("JOIN or RESUME first")
+		}
+		if err != nil {
+			tc.send("ERR %v", err)
+		}
+	}
+	if seat != nil {
+		t.leave(seat, tc)
+	}
+}
+
+func (t *table) join(name string, tc *tableConn) (*tableSeat, error) {
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return nil, errors.New("JOIN needs a name without spaces")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.started {
+		return nil, errors.New("the game has started")
+	}
+	if len(t.seats) >= t.maxSeats {
+		return nil, fmt.Errorf("the table is full ( %d seats )", t.maxSeats)
+	}
+	for _, s := range t.seats {
+		if s.name == name {
+			return nil, fmt.Errorf("%s is already at the table", name)
+		}
+	}
+	s := &tableSeat{index: len(t.seats), name: name, token: randomID(), conn: tc}
+	t.seats = append(t.seats, s)
+	tc.send("WELCOME %d %s", s.index, s.token)
+	t.broadcast("EVENT %s sits down", name)
+	t.broadcastState()
+	return s, nil
+}
+
+// resume gives the seat with the token to the new connection. An old connection still
+// holding the seat is closed.
+func (t *table) resume(token string, tc *tableConn) (*tableSeat, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.seats {
+		if s.token == token {
+			if s.conn != nil {
+				s.conn.close()
+			}
+			s.conn = tc
+			tc.send("WELCOME %d %s", s.index, s.token)
+			t.sendHand(s)
+			t.broadcast("EVENT %s is back", s.name)
+			t.broadcastState()
+			return s, nil
+		}
+	}
+	return nil, errors.New("unknown token")
+}
+
+// leave marks the player as away. The seat and the hand are kept for RESUME.
+func (t *table) leave(s *tableSeat, tc *tableConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s.conn != tc {
+		// the player is already back on another connection
+		return
+	}
+	s.conn = nil
+	t.broadcast("EVENT %s is away", s.name)
+	t.broadcastState()
+	t.watchTurn()
+}
+
+// handle runs a command of a seated player.
+func (t *table) handle(s *tableSeat, tc *tableConn, cmd, arg string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var err error
+	switch cmd {
+	case "HAND":
+		t.sendHand(s)
+		return
+	case "STATE":
+		tc.send("STATE %s", t.stateJSON())
+		return
+	case "SAY":
+		t.broadcast("EVENT %s says %s", s.name, arg)
+		return
+	case "START":
+		err = t.start(arg)
+	case "DRAW", "PLAY", "PASS":
+		err = t.play(s, cmd, arg)
+	default:
+		err = fmt.Errorf("unknown command %s", cmd)
+	}
+	if err != nil {
+		tc.send("ERR %v", err)
+		return
+	}
+	tc.send("OK")
+	t.broadcastState()
+}
+
+// start gathers all the cards, shuffles and deals a new game. The first card of the deck
+// is turned up on the pile and the first seat plays first.
+func (t *table) start(arg string) error {
+	if t.started {
+		return errors.New("the game has started")
+	}
+	if len(t.seats) < 2 {
+		return errors.New("need at least 2 players")
+	}
+	size := defaultHandSize
+	if arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return fmt.Errorf("bad hand size %q", arg)
+		}
+		size = n
+	}
+
+	cards := slices.Concat(t.deck, t.pile)
+	for _, s := range t.seats {
+		cards = append(cards, s.hand...)
+	}
+	cards.shuffleWith(t.source)
+	dl := newDealer(cards)
+	hands, err := dl.dealHands(len(t.seats), size, dealRoundRobin)
+	if err != nil {
+		return err
+	}
+	pile, err := dl.take(1)
+	if err != nil {
+		return err
+	}
+
+	t.deck, t.pile = dl.cards, pile
+	for i, s := range t.seats {
+		s.hand = hands[i]
+		t.sendHand(s)
+	}
+	t.started, t.turn, t.passes = true, 0, 0
+	t.broadcast("EVENT a new game is dealt, %d cards each", size)
+	t.nextTurn(0)
+	return nil
+}
+
+// play is a turn: DRAW, PLAY or PASS. Only the player whose turn it is can play.
+func (t *table) play(s *tableSeat, cmd, arg string) error {
+	if !t.started {
+		return errors.New("the game hasn't started, START it")
+	}
+	if s.index != t.turn {
+		return fmt.Errorf("not your turn, it's %s's", t.seats[t.turn].name)
+	}
+
+	switch cmd {
+	case "DRAW":
+		if len(t.deck) == 0 {
+			return errors.New("the deck is empty, PASS")
+		}
+		s.hand = append(s.hand, t.deck[0])
+		t.deck = t.deck[1:]
+		t.passes = 0
+		t.sendHand(s)
+		t.broadcast("EVENT %s draws", s.name)
+
+	case "PLAY":
+		card, err := parseCard(arg)
+		if err != nil {
+			return err
+		}
+		i := slices.Index(s.hand, card)
+		if i < 0 {
+			return fmt.Errorf("%v is not in your hand", card)
+		}
+		top := t.pile[len(t.pile)-1]
+		if !canPlayOn(card, top) {
+			return fmt.Errorf("%v doesn't match %v", card, top)
+		}
+		s.hand = slices.Delete(s.hand, i, i+1)
+		t.pile = append(t.pile, card)
+		t.passes = 0
+		t.sendHand(s)
+		t.broadcast("EVENT %s plays %v", s.name, card)
+		if len(s.hand) == 0 {
+			t.started = false
+			t.broadcast("EVENT %s wins", s.name)
+			return nil
+		}
+
+	case "PASS":
+		t.broadcast("EVENT %s passes", s.name)
+		if t.pass() {
+			return nil
+		}
+	}
+	t.nextTurn((t.turn + 1) % len(t.seats))
+	return nil
+}
+
+// pass counts a pass. Once everybody passed in a row on an empty deck nobody can move any more
+// and the game ends without a winner - pass reports whether it did.
+func (t *table) pass() bool {
+	if len(t.deck) > 0 {
+		return false
+	}
+	t.passes++
+	if t.passes < len(t.seats) {
+		return false
+	}
+	t.started = false
+	t.broadcast("EVENT nobody can play, the game ends without a winner")
+	return true
+}
+
+// nextTurn gives the turn to the seat.
+func (t *table) nextTurn(seat int) {
+	t.turn = seat
+	t.moves++
+	t.watchTurn()
+}
+
+// watchTurn starts the skip timer when the player whose turn it is is away. It's called on
+// every new turn and when a player leaves, the timer of the turn before is stopped.
+func (t *table) watchTurn() {
+	if t.skip != nil {
+		t.skip.Stop()
+	}
+	if !t.started || t.awayTimeout <= 0 || t.seats[t.turn].conn != nil {
+		return
+	}
+	moves := t.moves
+	t.skip = time.AfterFunc(t.awayTimeout, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		s := t.seats[t.turn]
+		if !t.started || t.moves != moves || s.conn != nil {
+			return
+		}
+		t.broadcast("EVENT %s is away, the turn is skipped", s.name)
+		if !t.pass() {
+			t.nextTurn((t.turn + 1) % len(t.seats))
+		}
+		t.broadcastState()
+	})
+}
+
+func (t *table) sendHand(s *tableSeat) {
+	if s.conn != nil {
+		s.conn.send("HAND %s", s.hand.toString())
+	}
+}
+
+func (t *table) broadcast(format string, args ...any) {
+	for _, s := range t.seats {
+		if s.conn != nil {
+			s.conn.send(format, args...)
+		}
+	}
+}
+
+func (t *table) broadcastState() {
+	t.broadcast("STATE %s", t.stateJSON())
+}
+
+func (t *table) state() tableState {
+	st := tableState{Started: t.started, Turn: t.turn, Deck: len(t.deck)}
+	if len(t.pile) > 0 {
+		st.Pile = &t.pile[len(t.pile)-1]
+	}
+	for _, s := range t.seats {
+		st.Players = append(st.Players, tablePlayer{Seat: s.index, Name: s.name, Cards: len(s.hand), Connected: s.conn != nil})
+	}
+	return st
+}
+
+func (t *table) stateJSON() string {
+	bs, _ := json.Marshal(t.state())
+	return string(bs)
+}
+
+// runTable is the "cards table" command: the server.
+func runTable(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "table")
+	addr := fs.String("addr", "localhost:7000", "address to listen on")
+	seats := fs.Int("seats", 4, "number of seats at the table")
+	seed := fs.Int64("seed", 0, "seed for shuffling the games, a random one when not set")
+	away := fs.Duration("away", defaultAwayTimeout, "skip the turn of a player who is away for this long, 0 to wait for ever")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *seats < 2 {
+		return usagef("need at least 2 seats")
+	}
+	if *away < 0 {
+		return usagef("-away can't be negative")
+	}
+	if !flagSet(fs, "seed") {
+		*seed = time.Now().UnixNano()
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	fmt.Fprintf(env.stdout, "Table for %d open on %s\n", *seats, ln.Addr())
+	t := newTable(*seats, *seed)
+	t.awayTimeout = *away
+	return serveTable(ln, t)
+}
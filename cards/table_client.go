@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tableClient is the terminal side of the table protocol ( see table.go ). What the player
+// types is sent as it is, what the server sends is printed in a readable way. When the
+// connection drops the client dials again and takes its seat back with RESUME.
+type tableClient struct {
+	addr    string
+	name    string
+	out     io.Writer
+	retries int
+	delay   time.Duration
+
+	mu       sync.Mutex
+	conn     net.Conn
+	token    string
+	seat     int
+	quitting bool
+}
+
+// connect dials the server and joins, or resumes when the client already has a seat.
+func (c *tableClient) connect() (net.Conn, error) {
+	conn, err := net.Dial("tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn = conn
+	if c.token != "" {
+		fmt.Fprintf(conn, "RESUME %s\n", c.token)
+	} else {
+		fmt.Fprintf(conn, "JOIN %s\n", c.name)
+	}
+	return conn, nil
+}
+
+// send writes a command to the server.
+func (c *tableClient) send(line string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if strings.EqualFold(strings.TrimSpace(line), "QUIT") {
+		c.quitting = true
+	}
+	_, err := fmt.Fprintln(c.conn, line)
+	return err
+}
+
+// receive prints the server's lines until the client quits. A dropped connection is dialed
+// again up to retries times.
+func (c *tableClient) receive(conn net.Conn) {
+	for {
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			c.show(sc.Text())
+		}
+		conn.Close()
+
+		c.mu.Lock()
+		quitting := c.quitting
+		c.mu.Unlock()
+		if quitting {
+			return
+		}
+
+		fmt.Fprintln(c.out, "Connection lost, reconnecting...")
+		var err error
+		conn = nil
+		for try := 0; try < c.retries && conn == nil; try++ {
+			time.Sleep(c.delay)
+			conn, err = c.connect()
+		}
+		if conn == nil {
+			fmt.Fprintf(c.out, "Giving up: %v\n", err)
+			return
+		}
+	}
+}
+
+// show prints one line of the server.
+func (c *tableClient) show(line string) {
+	kind, rest, _ := strings.Cut(line, " ")
+	switch kind {
+	case "OK":
+	case "ERR":
+		fmt.Fprintf(c.out, "error: %s\n", rest)
+	case "EVENT":
+		fmt.Fprintln(c.out, rest)
+	case "WELCOME":
+		var seat int
+		var token string
+		fmt.Sscan(rest, &seat, &token)
+		c.mu.Lock()
+		c.seat, c.token = seat, token
+		c.mu.Unlock()
+		fmt.Fprintf(c.out, "You have seat %d.\n", seat+1)
+	case "HAND":
+		if rest == "" {
+			rest = "no cards"
+		}
+		fmt.Fprintf(c.out, "Your hand: %s\n", strings.ReplaceAll(rest, ",", ", "))
+	case "STATE":
+		var st tableState
+		if err := json.Unmarshal([]byte(rest), &st); err != nil {
+			fmt.Fprintln(c.out, line)
+			return
+		}
+		c.showState(st)
+	default:
+		fmt.Fprintln(c.out, line)
+	}
+}
+
+func (c *tableClient) showState(st tableState) {
+	var players []string
+	for _, p := range st.Players {
+		s := fmt.Sprintf("%s %d", p.Name, p.Cards)
+		if !p.Connected {
+			s += " ( away )"
+		}
+		if st.Started && p.Seat == st.Turn {
+			s = "*" + s
+		}
+		players = append(players, s)
+	}
+	fmt.Fprintf(c.out, "Table: %s", strings.Join(players, ", "))
+	if st.Started {
+		fmt.Fprintf(c.out, " | deck %d | pile %v", st.Deck, st.Pile)
+		c.mu.Lock()
+		if st.Turn == c.seat {
+			fmt.Fprint(c.out, " | your turn")
+		}
+		c.mu.Unlock()
+	}
+	fmt.Fprintln(c.out)
+}
+
+// runJoin is the "cards join" command: the terminal client of the table.
+func runJoin(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "join")
+	addr := fs.String("addr", "localhost:7000", "address of the table")
+	name := fs.String("name", "", "your name at the table")
+	retries := fs.Int("retries", 5, "how many times to reconnect after the connection drops")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *name == "" || strings.ContainsAny(*name, " \t") {
+		return usagef("-name is needed, without spaces")
+	}
+
+	c := &tableClient{addr: *addr, name: *name, out: env.stdout, retries: *retries, delay: time.Second}
+	conn, err := c.connect()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(env.stdout, "Commands: START, DRAW, PLAY <card>, PASS, HAND, STATE, SAY <text>, QUIT")
+	done := make(chan struct{})
+	go func() {
+		c.receive(conn)
+		close(done)
+	}()
+
+	in := bufio.NewScanner(env.stdin)
+	for in.Scan() {
+		line := strings.TrimSpace(in.Text())
+		if line == "" {
+			continue
+		}
+		// a failed write means the connection dropped, the receiver is already reconnecting
+		c.send(line)
+		if strings.EqualFold(line, "QUIT") {
+			break
+		}
+	}
+	c.mu.Lock()
+	quitting := c.quitting
+	c.mu.Unlock()
+	if !quitting {
+		c.send("QUIT")
+	}
+	<-done
+	return nil
+}
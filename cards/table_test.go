@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTable opens a table on a free localhost port and returns its address.
+func startTable(t *testing.T, seats int, seed int64) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go serveTable(ln, newTable(seats, seed))
+	return ln.Addr().String()
+}
+
+// tablePlayerConn is a raw protocol connection for the tests.
+type tablePlayerConn struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialTable(t *testing.T, addr string) *tablePlayerConn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &tablePlayerConn{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (p *tablePlayerConn) send(line string) {
+	p.t.Helper()
+	if _, err := fmt.Fprintln(p.conn, line); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+// expect reads lines until one starts with prefix and returns the rest of it.
+// The lines skipped on the way are returned too, for the checks on what wasn't sent.
+func (p *tablePlayerConn) expect(prefix string) (string, []string) {
+	p.t.Helper()
+	p.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var skipped []string
+	for {
+		line, err := p.r.ReadString('\n')
+		if err != nil {
+			p.t.Fatalf("Expected a %s line, got %v after %q", prefix, err, skipped)
+		}
+		line = strings.TrimRight(line, "\n")
+		if rest, ok := strings.CutPrefix(line, prefix); ok {
+			return strings.TrimSpace(rest), skipped
+		}
+		skipped = append(skipped, line)
+	}
+}
+
+func (p *tablePlayerConn) state() tableState {
+	p.t.Helper()
+	rest, _ := p.expect("STATE ")
+	var st tableState
+	if err := json.Unmarshal([]byte(rest), &st); err != nil {
+		p.t.Fatal(err)
+	}
+	return st
+}
+
+// join sits a player down and returns the token.
+func (p *tablePlayerConn) join(name string) string {
+	p.t.Helper()
+	p.send("JOIN " + name)
+	rest, _ := p.expect("WELCOME ")
+	_, token, _ := strings.Cut(rest, " ")
+	return token
+}
+
+func TestTableJoinAndDeal(t *testing.T) {
+	addr := startTable(t, 2, 1)
+	ann, bob := dialTable(t, addr), dialTable(t, addr)
+
+	ann.send("START")
+	if reason, _ := ann.expect("ERR "); !strings.Contains(reason, "JOIN") {
+		t.Errorf("Expected to be asked to join first, got %q", reason)
+	}
+	ann.join("ann")
+	bob.send("JOIN ann")
+	if reason, _ := bob.expect("ERR "); !strings.Contains(reason, "already") {
+		t.Errorf("Expected the name to be taken, got %q", reason)
+	}
+	bob.join("bob")
+	if reason, _ := dialTableJoin(t, addr, "cat"); !strings.Contains(reason, "full") {
+		t.Errorf("Expected the table to be full, got %q", reason)
+	}
+
+	ann.send("START 5")
+	annHand, _ := ann.expect("HAND ")
+	bobHand, skipped := bob.expect("HAND ")
+	if len(strings.Split(annHand, ",")) != 5 || len(strings.Split(bobHand, ",")) != 5 {
+		t.Errorf("Expected 5 cards each, got %q and %q", annHand, bobHand)
+	}
+	// bob never sees ann's hand
+	for _, line := range skipped {
+		if strings.Contains(line, annHand) {
+			t.Errorf("Expected ann's hand to stay private, bob got %q", line)
+		}
+	}
+
+	st := bob.state()
+	for !st.Started {
+		st = bob.state()
+	}
+	if st.Deck != 52-11 || st.Pile == nil || len(st.Players) != 2 || st.Players[0].Cards != 5 {
+		t.Errorf("Expected 41 cards in the deck, a card on the pile and 5 cards each, got %+v", st)
+	}
+}
+
+// dialTableJoin tries to join with a new connection and returns the refusal.
+func dialTableJoin(t *testing.T, addr, name string) (string, []string) {
+	p := dialTable(t, addr)
+	p.send("JOIN " + name)
+	return p.expect("ERR ")
+}
+
+func TestTableTurns(t *testing.T) {
+	addr := startTable(t, 3, 2)
+	ann, bob := dialTable(t, addr), dialTable(t, addr)
+	ann.join("ann")
+	bob.join("bob")
+
+	bob.send("DRAW")
+	if reason, _ := bob.expect("ERR "); !strings.Contains(reason, "hasn't started") {
+		t.Errorf("Expected the game not to be started, got %q", reason)
+	}
+	bob.send("START 3")
+	bob.expect("OK")
+	ann.expect("HAND ")
+
+	bob.send("DRAW")
+	if reason, _ := bob.expect("ERR "); !strings.Contains(reason, "not your turn") {
+		t.Errorf("Expected bob to wait for ann, got %q", reason)
+	}
+	ann.send("DRAW")
+	if hand, _ := ann.expect("HAND "); len(strings.Split(hand, ",")) != 4 {
+		t.Errorf("Expected ann to have 4 cards after drawing, got %q", hand)
+	}
+	ann.expect("OK")
+	if event, _ := bob.expect("EVENT ann draws"); event != "" {
+		t.Errorf("Expected bob to see ann draw")
+	}
+
+	bob.send("PLAY Foo of Bars")
+	if reason, _ := bob.expect("ERR "); !strings.Contains(reason, "unknown card") {
+		t.Errorf("Expected an unknown card, got %q", reason)
+	}
+	bob.send("PLAY Ace of Spades")
+	bob.expect("ERR ")
+	bob.send("PASS")
+	bob.expect("OK")
+	bob.send("PASS")
+	if reason, _ := bob.expect("ERR "); !strings.Contains(reason, "ann's") {
+		t.Errorf("Expected it to be ann's turn after bob, got %q", reason)
+	}
+}
+
+func TestTableReconnect(t *testing.T) {
+	addr := startTable(t, 2, 3)
+	ann, bob := dialTable(t, addr), dialTable(t, addr)
+	ann.join("ann")
+	token := bob.join("bob")
+	ann.send("START")
+	ann.expect("OK")
+	hand, _ := bob.expect("HAND ")
+
+	bob.conn.Close()
+	// ann sees bob go away and come back
+	for st := ann.state(); st.Players[1].Connected; st = ann.state() {
+	}
+
+	back := dialTable(t, addr)
+	back.send("RESUME nope")
+	back.expect("ERR ")
+	back.send("RESUME " + token)
+	back.expect("WELCOME 1 ")
+	if again, _ := back.expect("HAND "); again != hand {
+		t.Errorf("Expected bob to get his hand back, got %q for %q", again, hand)
+	}
+	for st := ann.state(); !st.Players[1].Connected; st = ann.state() {
+	}
+}
+
+func TestTableShedding(t *testing.T) {
+	tb := newTable(2, 4)
+	ann := &tableSeat{index: 0, name: "ann"}
+	bob := &tableSeat{index: 1, name: "bob"}
+	tb.seats = []*tableSeat{ann, bob}
+	if err := tb.start("1"); err != nil {
+		t.Fatal(err)
+	}
+	// swap ann's card for one of the deck that matches the pile
+	top := tb.pile[0]
+	i := slices.IndexFunc(tb.deck, func(c Card) bool { return c.Suit == top.Suit })
+	tb.deck[i], ann.hand[0] = ann.hand[0], tb.deck[i]
+	card := ann.hand[0]
+	if err := tb.play(ann, "PLAY", card.String()); err != nil {
+		t.Fatal(err)
+	}
+	if tb.started || len(ann.hand) != 0 {
+		t.Errorf("Expected ann to win with her last card")
+	}
+
+	// a new game gathers every card again
+	if err := tb.start("7"); err != nil {
+		t.Fatal(err)
+	}
+	total := len(tb.deck) + len(tb.pile) + len(ann.hand) + len(bob.hand)
+	if total != 52 {
+		t.Errorf("Expected 52 cards in the new game, got %v", total)
+	}
+	if err := validateDeck(slices.Concat(tb.deck, tb.pile, ann.hand, bob.hand)); err != nil {
+		t.Errorf("Expected no duplicated card, got %v", err)
+	}
+}
+
+// With an empty deck everybody can pass for ever - a full round of passes ends the game.
+func TestTablePassesOnEmptyDeck(t *testing.T) {
+	tb := newTable(2, 5)
+	ann := &tableSeat{index: 0, name: "ann"}
+	bob := &tableSeat{index: 1, name: "bob"}
+	tb.seats = []*tableSeat{ann, bob}
+	tb.awayTimeout = 0
+	if err := tb.start("3"); err != nil {
+		t.Fatal(err)
+	}
+	tb.play(ann, "PASS", "")
+	tb.play(bob, "PASS", "")
+	if !tb.started {
+		t.Fatalf("Expected passes with cards left in the deck to go on")
+	}
+
+	tb.deck = nil
+	tb.play(ann, "PASS", "")
+	if !tb.started {
+		t.Fatalf("Expected the game to go on until everybody passed")
+	}
+	tb.play(bob, "PASS", "")
+	if tb.started {
+		t.Errorf("Expected the game to end after a round of passes on an empty deck")
+	}
+}
+
+// A player who is away on their turn doesn't hold up the table.
+func TestTableSkipsAwayPlayer(t *testing.T) {
+	tb := newTable(2, 6)
+	tb.seats = []*tableSeat{{index: 0, name: "ann"}, {index: 1, name: "bob"}}
+	tb.awayTimeout = time.Millisecond
+	tb.mu.Lock()
+	if err := tb.start("3"); err != nil {
+		t.Fatal(err)
+	}
+	tb.mu.Unlock()
+
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			tb.mu.Lock()
+			ok := done()
+			tb.mu.Unlock()
+			if ok {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %s", what)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor("the turns of the away players to be skipped", func() bool { return tb.moves > 3 })
+	tb.mu.Lock()
+	tb.deck = nil
+	tb.mu.Unlock()
+	waitFor("the game to end once the deck is empty", func() bool { return !tb.started })
+}
+
+func TestJoinClient(t *testing.T) {
+	addr := startTable(t, 2, 5)
+	ann := dialTable(t, addr)
+	ann.join("ann")
+
+	code, stdout, stderr := runCLI(t, "SAY hello\nSTATE\nQUIT\n", "join", "-addr", addr, "-name", "bob")
+	if code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+	for _, want := range []string{"You have seat 2.", "bob says hello", "Table: ann 0, bob 0"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in the output, got\n%s", want, stdout)
+		}
+	}
+	if said, _ := ann.expect("EVENT bob says"); said != "hello" {
+		t.Errorf("Expected ann to hear bob, got %q", said)
+	}
+	if code, _, _ := runCLI(t, "", "join", "-addr", addr); code != exitUsage {
+		t.Errorf("Expected a usage error without -name, got %v", code)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// tournamentConfig describes a tournament of the shedding game ( see bots.go ).
+type tournamentConfig struct {
+	// Bots are the names of the players, one seat each. The same bot can play more than one seat.
+	Bots []string
+	// Games is the number of games. The seats turn one place every game, so every bot starts as often.
+	Games int
+	// HandSize is the cards dealt to every player, 7 when 0.
+	HandSize int
+	// Samples is the number of lookahead games of the Monte Carlo bot, 20 when 0.
+	Samples int
+	// Workers is the number of goroutines playing the games, one per CPU when 0.
+	Workers int
+	// Seed decides every game: game i is shuffled with Seed+i, whatever worker plays it.
+	Seed int64
+}
+
+// botStanding is how one seat of the tournament did.
+type botStanding struct {
+	Name string
+	Wins int
+	// WinRate is the share of the games won and Margin the half width of its 95% confidence interval.
+	WinRate float64
+	Margin  float64
+}
+
+// tournamentResult is the standing of every bot, in the order of tournamentConfig.Bots.
+type tournamentResult struct {
+	Games     int
+	Draws     int
+	Standings []botStanding
+}
+
+// botComparison tells if one bot wins more often than another by more than luck.
+type botComparison struct {
+	A, B int
+	// Diff is the win rate of A minus the win rate of B.
+	Diff float64
+	// Z is the two proportion z score of Diff and P its two sided p-value. The bots play the same
+	// games so their results aren't independent - take P as an estimate.
+	Z, P float64
+}
+
+// significant is the usual 5% level.
+func (c botComparison) significant() bool {
+	return c.P < 0.05
+}
+
+// compare tests the win rates of the bots a and b.
+func (r tournamentResult) compare(a, b int) botComparison {
+	c := botComparison{A: a, B: b}
+	n := float64(r.Games)
+	pa, pb := r.Standings[a].WinRate, r.Standings[b].WinRate
+	c.Diff = pa - pb
+	pooled := (pa + pb) / 2
+	se := math.Sqrt(pooled * (1 - pooled) * 2 / n)
+	if se == 0 {
+		c.P = 1
+		return c
+	}
+	c.Z = c.Diff / se
+	c.P = math.Erfc(math.Abs(c.Z) / math.Sqrt2)
+	return c
+}
+
+// runTournament plays the games on several goroutines. Every game gets its own random generator
+// and new bots, so the result is the same for the same seed whatever the number of workers.
+func runTournament(cfg tournamentConfig) (tournamentResult, error) {
+	n := len(cfg.Bots)
+	if n < 2 {
+		return tournamentResult{}, fmt.Errorf("need at least 2 bots, got %d", n)
+	}
+	for _, name := range cfg.Bots {
+		if _, ok := bots[name]; !ok {
+			return tournamentResult{}, fmt.Errorf("unknown bot %q, expected one of %s", name, strings.Join(botNames(), ", "))
+		}
+	}
+	if cfg.Games < 1 {
+		return tournamentResult{}, fmt.Errorf("need at least 1 game, got %d", cfg.Games)
+	}
+	handSize := cfg.HandSize
+	if handSize == 0 {
+		handSize = defaultHandSize
+	}
+	if handSize < 1 || n*handSize >= 52 {
+		return tournamentResult{}, fmt.Errorf("can't deal %d hands of %d cards and a pile", n, handSize)
+	}
+	samples := cfg.Samples
+	if samples <= 0 {
+		samples = defaultMonteCarloSamples
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = min(workers, cfg.Games)
+
+	games := make(chan int)
+	wins := make([][]int, workers)
+	draws := make([]int, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		wins[w] = make([]int, n)
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := range games {
+				if errs[w] != nil {
+					continue
+				}
+				winner, err := playTournamentGame(cfg.Bots, handSize, samples, cfg.Seed+int64(i), i)
+				switch {
+				case err != nil:
+					errs[w] = fmt.Errorf("game %d: %w", i, err)
+				case winner < 0:
+					draws[w]++
+				default:
+					wins[w][winner]++
+				}
+			}
+		}(w)
+	}
+	for i := range cfg.Games {
+		games <- i
+	}
+	close(games)
+	wg.Wait()
+
+	r := tournamentResult{Games: cfg.Games}
+	for w := range workers {
+		if errs[w] != nil {
+			return tournamentResult{}, errs[w]
+		}
+		r.Draws += draws[w]
+	}
+	for bot, name := range cfg.Bots {
+		s := botStanding{Name: name}
+		for w := range workers {
+			s.Wins += wins[w][bot]
+		}
+		s.WinRate = float64(s.Wins) / float64(cfg.Games)
+		s.Margin = binomialMargin(s.WinRate, cfg.Games)
+		r.Standings = append(r.Standings, s)
+	}
+	return r, nil
+}
+
+// playTournamentGame plays game number i and returns the index in names of the winner, -1 for a draw.
+// The bots sit turned by i places.
+func playTournamentGame(names []string, handSize, samples int, seed int64, i int) (int, error) {
+	r := rand.New(rand.NewSource(seed))
+	g, err := newSheddingGame(len(names), handSize, r)
+	if err != nil {
+		return -1, err
+	}
+	players := make([]Player, len(names))
+	for seat := range players {
+		bot := (seat + i) % len(names)
+		players[seat] = bots[names[bot]](rand.New(rand.NewSource(r.Int63())), samples)
+	}
+	winner, err := playShedding(g, players)
+	if err != nil || winner < 0 {
+		return -1, err
+	}
+	return (winner + i) % len(names), nil
+}
+
+func botNames() []string {
+	names := make([]string, 0, len(bots))
+	for name := range bots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runTournamentCommand is the "cards tournament" command.
+func runTournamentCommand(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "tournament")
+	botsFlag := fs.String("bots", "random,rules,montecarlo", "comma separated bots, one per seat: "+strings.Join(botNames(), ", "))
+	games := fs.Int("games", 1000, "number of games")
+	handSize := fs.Int("hand", defaultHandSize, "cards dealt to every player")
+	samples := fs.Int("samples", defaultMonteCarloSamples, "lookahead games of the montecarlo bot for every action")
+	workers := fs.Int("workers", 0, "goroutines playing the games, one per CPU when 0")
+	seed := fs.Int64("seed", 1, "seed of the first game")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	cfg := tournamentConfig{
+		Bots:     strings.Split(*botsFlag, ","),
+		Games:    *games,
+		HandSize: *handSize,
+		Samples:  *samples,
+		Workers:  *workers,
+		Seed:     *seed,
+	}
+	for i := range cfg.Bots {
+		cfg.Bots[i] = strings.TrimSpace(cfg.Bots[i])
+	}
+	r, err := runTournament(cfg)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+
+	fmt.Fprintf(env.stdout, "%d games of %d players, %d cards each, seed %d ( %d draws )\n", r.Games, len(cfg.Bots), *handSize, *seed, r.Draws)
+	tw := tabwriter.NewWriter(env.stdout, 0, 0, 2, ' ', 0)
+	order := make([]int, len(r.Standings))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return r.Standings[b].Wins - r.Standings[a].Wins })
+	for place, i := range order {
+		s := r.Standings[i]
+		fmt.Fprintf(tw, "%d.\t%s\t%d wins\t%s\n", place+1, s.Name, s.Wins, percent(s.WinRate, s.Margin))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(env.stdout, "Differences:")
+	for a := 0; a < len(order); a++ {
+		for b := a + 1; b < len(order); b++ {
+			c := r.compare(order[a], order[b])
+			verdict := "could be luck"
+			if c.significant() {
+				verdict = "significant"
+			}
+			fmt.Fprintf(tw, "  %s - %s\t%+.2f%%\tz %.2f\tp %.4f\t%s\n",
+				r.Standings[order[a]].Name, r.Standings[order[b]].Name, 100*c.Diff, c.Z, c.P, verdict)
+		}
+	}
+	return tw.Flush()
+}
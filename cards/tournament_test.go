@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTournament(t *testing.T) {
+	cfg := tournamentConfig{Bots: []string{"random", "rules"}, Games: 300, Workers: 4, Seed: 9}
+	r, err := runTournament(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	random, rules := r.Standings[0], r.Standings[1]
+	if random.Wins+rules.Wins+r.Draws != cfg.Games {
+		t.Errorf("Expected every game to be won or drawn, got %+v", r)
+	}
+	c := r.compare(1, 0)
+	if !c.significant() || c.Diff <= 0 {
+		t.Errorf("Expected the rules to beat random, got %+v ( %+v )", c, r.Standings)
+	}
+	if rules.Margin <= 0 || rules.Margin > 0.06 {
+		t.Errorf("Expected a margin under 6%%, got %v", rules.Margin)
+	}
+
+	// the same seed gives the same games, however many workers play them
+	cfg.Workers = 1
+	again, err := runTournament(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Draws != r.Draws || again.Standings[0] != random || again.Standings[1] != rules {
+		t.Errorf("Expected the same result with 1 worker, got %+v and %+v", r, again)
+	}
+}
+
+func TestTournamentSameBots(t *testing.T) {
+	r, err := runTournament(tournamentConfig{Bots: []string{"rules", "rules"}, Games: 500, Seed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the seats turn every game, so the same bot shouldn't win more on one of them
+	if c := r.compare(0, 1); c.P < 0.01 {
+		t.Errorf("Expected no significant difference, got %+v", c)
+	}
+}
+
+func TestTournamentErrors(t *testing.T) {
+	for _, cfg := range []tournamentConfig{
+		{Bots: []string{"rules"}, Games: 10},
+		{Bots: []string{"rules", "cheater"}, Games: 10},
+		{Bots: []string{"rules", "random"}},
+		{Bots: []string{"rules", "random"}, Games: 10, HandSize: 26},
+	} {
+		if _, err := runTournament(cfg); err == nil {
+			t.Errorf("Expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestTournamentCommand(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "tournament", "-bots", "random,rules,montecarlo", "-games", "12", "-samples", "3")
+	if code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+	for _, want := range []string{"12 games of 3 players", "1.  ", "montecarlo", "Differences:"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in the output, got\n%s", want, stdout)
+		}
+	}
+	if code, _, _ := runCLI(t, "", "tournament", "-bots", "rules"); code != exitUsage {
+		t.Errorf("Expected a usage error for one bot, got %v", code)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+// War: the deck is split between two players. Every battle both turn up the top card of their pile
+// and the higher card ( Ace high ) takes both. Equal cards start a war: both put down three cards
+// face down and one face up, and the higher of those takes everything on the table. A player that
+// can't put down the cards of a battle or a war loses. The game has no decisions to make, so it's
+// all about the messages: see referee.go for how the players and the referee talk.
+
+// warMessage is what the referee sends to a player goroutine.
+type warMessage struct {
+	// Won are cards the player took, they go to the bottom of its pile.
+	Won deck
+	// Play asks for that many cards off the top of the pile, sent on Reply.
+	Play  int
+	Reply chan<- deck
+}
+
+// warPlayer runs one player: it gets its pile and reads messages until the channel is closed.
+type warPlayer func(pile deck, in <-chan warMessage)
+
+// honestWarPlayer plays by the rules.
+func honestWarPlayer(pile deck, in <-chan warMessage) {
+	for msg := range in {
+		pile = append(pile, msg.Won...)
+		if msg.Play > 0 {
+			msg.Reply <- slices.Clone(pile[:msg.Play])
+			pile = pile[msg.Play:]
+		}
+	}
+}
+
+// defaultWarBattles stops a game that goes on too long, as a draw.
+const defaultWarBattles = 10000
+
+// warConfig sets up one game of War.
+type warConfig struct {
+	// Seed shuffles the deck and the cards picked up after every battle.
+	Seed int64
+	// Deck is the deck to split, the first half to the first player. A new deck shuffled with Seed when nil.
+	Deck deck
+	// Players are the two players, honestWarPlayer when nil.
+	Players [2]warPlayer
+	// Timeout is how long the referee waits for a player, defaultMoveTimeout when 0.
+	Timeout time.Duration
+	// MaxBattles is the battles before the game is a draw, defaultWarBattles when 0.
+	MaxBattles int
+}
+
+// warGame is the referee's side of the game: the channel of every player and its ledger of the piles.
+type warGame struct {
+	in      [2]chan warMessage
+	piles   [2]deck
+	timeout time.Duration
+}
+
+// playWar deals the shuffled deck in two and referees the game to the end.
+func playWar(cfg warConfig) (refereeResult, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultMoveTimeout
+	}
+	if cfg.MaxBattles <= 0 {
+		cfg.MaxBattles = defaultWarBattles
+	}
+	r := rand.New(rand.NewSource(cfg.Seed))
+	d := cfg.Deck
+	if d == nil {
+		d = newDeck()
+		d.shuffleWith(r)
+	}
+	first, second, err := deal(d, len(d)/2)
+	if err != nil {
+		return refereeResult{}, err
+	}
+
+	g := &warGame{piles: [2]deck{slices.Clone(first), slices.Clone(second)}, timeout: cfg.Timeout}
+	for p, player := range cfg.Players {
+		if player == nil {
+			player = honestWarPlayer
+		}
+		g.in[p] = make(chan warMessage)
+		go player(slices.Clone(g.piles[p]), g.in[p])
+	}
+	defer func() {
+		for _, in := range g.in {
+			close(in)
+		}
+	}()
+
+	var res refereeResult
+	for res.Turns < cfg.MaxBattles {
+		var table deck
+		need := 1
+		for {
+			var up [2]Card
+			switch short0, short1 := len(g.piles[0]) < need, len(g.piles[1]) < need; {
+			case short0 && short1:
+				// both run out in the same war: the one with more cards left wins
+				if n0, n1 := len(g.piles[0]), len(g.piles[1]); n0 != n1 {
+					res.Winners = []int{0}
+					if n1 > n0 {
+						res.Winners = []int{1}
+					}
+				}
+				return res, nil
+			case short0:
+				res.Winners = []int{1}
+				return res, nil
+			case short1:
+				res.Winners = []int{0}
+				return res, nil
+			}
+			if need == 1 {
+				res.Turns++
+			}
+			for p := range g.piles {
+				cards, err := g.play(p, need)
+				if err != nil {
+					res.Winners, res.Forfeits, res.Reason = []int{1 - p}, []int{p}, err.Error()
+					return res, nil
+				}
+				table = append(table, cards...)
+				up[p] = cards[len(cards)-1]
+			}
+			if up[0].Rank.high() == up[1].Rank.high() {
+				// war: three cards face down and one face up
+				need = 4
+				continue
+			}
+			winner := 0
+			if up[1].Rank.high() > up[0].Rank.high() {
+				winner = 1
+			}
+			// the cards are picked up in no particular order, without that a game can go round forever
+			r.Shuffle(len(table), func(i, j int) { table[i], table[j] = table[j], table[i] })
+			if err := g.give(winner, table); err != nil {
+				res.Winners, res.Forfeits, res.Reason = []int{1 - winner}, []int{winner}, err.Error()
+				return res, nil
+			}
+			break
+		}
+	}
+	return res, nil
+}
+
+// play asks the player for n cards and checks them against the ledger.
+func (g *warGame) play(p, n int) (deck, error) {
+	reply := make(chan deck, 1)
+	if !g.send(p, warMessage{Play: n, Reply: reply}) {
+		return nil, fmt.Errorf("player %d doesn't listen", p+1)
+	}
+	cards, ok := awaitCards(reply, g.timeout)
+	if !ok {
+		return nil, fmt.Errorf("player %d didn't answer in %v", p+1, g.timeout)
+	}
+	if !slices.Equal(cards, g.piles[p][:n]) {
+		return nil, fmt.Errorf("player %d played %v instead of the top of its pile", p+1, cards)
+	}
+	g.piles[p] = g.piles[p][n:]
+	return cards, nil
+}
+
+// give puts the cards at the bottom of the player's pile.
+func (g *warGame) give(p int, cards deck) error {
+	if !g.send(p, warMessage{Won: slices.Clone(cards)}) {
+		return fmt.Errorf("player %d doesn't listen", p+1)
+	}
+	g.piles[p] = append(g.piles[p], cards...)
+	return nil
+}
+
+func (g *warGame) send(p int, msg warMessage) bool {
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+	select {
+	case g.in[p] <- msg:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// runWar is the "cards war" command: it plays many games and shows how long they take.
+func runWar(env cliEnv, args []string) error {
+	fs := newFlagSet(env, "war")
+	games := fs.Int("games", 1000, "number of games")
+	seed := fs.Int64("seed", 1, "seed of the first game, the next games use the next seeds")
+	timeout := fs.Duration("timeout", defaultMoveTimeout, "how long the referee waits for a player")
+	battles := fs.Int("battles", defaultWarBattles, "battles before a game is called a draw")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *games < 1 || *timeout <= 0 || *battles < 1 {
+		return usagef("-games, -timeout and -battles must be positive")
+	}
+	stats, err := simulateGames(*games, 2, *seed, func(seed int64) (refereeResult, error) {
+		return playWar(warConfig{Seed: seed, Timeout: *timeout, MaxBattles: *battles})
+	})
+	if err != nil {
+		return err
+	}
+	stats.print(env.stdout, "war", "battles")
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlayWar(t *testing.T) {
+	res, err := playWar(warConfig{Seed: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Winners) != 1 || res.Turns < 1 || len(res.Forfeits) != 0 {
+		t.Errorf("Expected one winner and no forfeits, got %+v", res)
+	}
+	// the same seed plays the same game
+	again, err := playWar(warConfig{Seed: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(again.Winners, res.Winners) || again.Turns != res.Turns {
+		t.Errorf("Expected the same game for the same seed, got %+v and %+v", res, again)
+	}
+}
+
+func TestPlayWarStacked(t *testing.T) {
+	// the first player gets the 26 highest cards and never loses a battle
+	d := newDeck()
+	slices.SortStableFunc(d, func(a, b Card) int { return b.Rank.high() - a.Rank.high() })
+	res, err := playWar(warConfig{Deck: d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Winners, []int{0}) || res.Turns != 26 {
+		t.Errorf("Expected the first player to win in 26 battles, got %+v", res)
+	}
+}
+
+func TestPlayWarMaxBattles(t *testing.T) {
+	res, err := playWar(warConfig{Seed: 5, MaxBattles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Winners) != 0 || res.Turns != 3 {
+		t.Errorf("Expected a draw after 3 battles, got %+v", res)
+	}
+}
+
+func TestPlayWarForfeits(t *testing.T) {
+	silent := func(pile deck, in <-chan warMessage) {
+		for range in {
+		}
+	}
+	cheater := func(pile deck, in <-chan warMessage) {
+		for msg := range in {
+			if msg.Play > 0 {
+				aces := make(deck, msg.Play)
+				for i := range aces {
+					aces[i] = Card{Rank: Ace, Suit: Spades}
+				}
+				msg.Reply <- aces
+			}
+		}
+	}
+	for _, tc := range []struct {
+		name    string
+		players [2]warPlayer
+		reason  string
+	}{
+		{"silent", [2]warPlayer{nil, silent}, "player 2 didn't answer"},
+		{"cheater", [2]warPlayer{cheater, nil}, "player 1 played"},
+	} {
+		res, err := playWar(warConfig{Seed: 1, Players: tc.players, Timeout: 20 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		loser := 1
+		if tc.players[0] != nil {
+			loser = 0
+		}
+		if !slices.Equal(res.Forfeits, []int{loser}) || !slices.Equal(res.Winners, []int{1 - loser}) {
+			t.Errorf("%s: Expected player %d to forfeit, got %+v", tc.name, loser+1, res)
+		}
+		if !strings.Contains(res.Reason, tc.reason) {
+			t.Errorf("%s: Expected the reason %q, got %q", tc.name, tc.reason, res.Reason)
+		}
+	}
+}
+
+func TestSimulateGames(t *testing.T) {
+	stats, err := simulateGames(200, 2, 1, func(seed int64) (refereeResult, error) {
+		return playWar(warConfig{Seed: seed})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Wins[0]+stats.Wins[1]+stats.Draws != 200 {
+		t.Errorf("Expected every game to be won or drawn, got %+v", stats)
+	}
+	if stats.Min > stats.Median || stats.Median > stats.Max || stats.Mean < float64(stats.Min) || stats.Mean > float64(stats.Max) {
+		t.Errorf("Expected min <= median, mean <= max, got %+v", stats)
+	}
+	if stats.Margin <= 0 || stats.Margin >= stats.StdDev {
+		t.Errorf("Expected a margin under the standard deviation, got %+v", stats)
+	}
+}
+
+func TestWarCommand(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "war", "-games", "20")
+	if code != exitOK {
+		t.Fatalf("Expected exit code 0, got %v ( %s )", code, stderr)
+	}
+	for _, want := range []string{"20 games of war, 2 players", "battles", "Player 2:", "Draws:"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in the output, got\n%s", want, stdout)
+		}
+	}
+	if code, _, _ := runCLI(t, "", "war", "-games", "0"); code != exitUsage {
+		t.Errorf("Expected a usage error for 0 games, got %v", code)
+	}
+}
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// target is one link to check and how to check it.
+type target struct {
+	Name     string
+	URL      string
+	Interval time.Duration // the pause between two checks
+	Timeout  time.Duration // how long one check can take before the link counts as down
+	// Expect are the status codes that count as up - any status under 400 when empty.
+	Expect []int
+	Tags   []string
+}
+
+const (
+	defaultInterval = 5 * time.Second
+	defaultTimeout  = 10 * time.Second
+)
+
+/*
+  The config file:
+  - The format follows the extension: .json, .yaml ( or .yml ) and .toml. The same monitor list in the three of them:
+
+    JSON:
+      {"interval": "30s", "targets": [{"name": "go", "url": "https://go.dev", "expect": [200], "tags": ["docs"]}]}
+
+    YAML:
+      interval: 30s
+      targets:
+        - name: go
+          url: https://go.dev
+          expect: [200]
+          tags:
+            - docs
+
+    TOML:
+      interval = "30s"
+
+      [[targets]]
+      name = "go"
+      url = "https://go.dev"
+      expect = [200]
+      tags = ["docs"]
+
+  - interval and timeout at the top are the defaults of every target, a target can set its own.
+    Durations are written like "30s" or "1m30s", a plain number is seconds.
+  - url is the only field a target needs, the name is the url when not set. Names have to be unique,
+    that's how a reload knows which running check a target belongs to.
+  - There is no YAML or TOML package in the standard library, so the two small parsers below read
+    only what a monitor list needs: keys, strings, numbers, lists and a list of targets.
+*/
+
+// loadConfig reads the targets of a config file.
+func loadConfig(filename string) ([]target, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json":
+		err = json.Unmarshal(bs, &doc)
+	case ".yaml", ".yml":
+		doc, err = parseYAML(string(bs))
+	case ".toml":
+		doc, err = parseTOML(string(bs))
+	default:
+		return nil, fmt.Errorf("%s: unknown config format %q, use .json, .yaml or .toml", filename, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	targets, err := decodeTargets(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return targets, nil
+}
+
+// decodeTargets turns the document of any of the formats into targets.
+func decodeTargets(doc map[string]any) ([]target, error) {
+	defaults := target{Interval: defaultInterval, Timeout: defaultTimeout}
+	var items []any
+	// the keys are checked in order so a file with more than one mistake always reports the same one
+	for _, key := range slices.Sorted(maps.Keys(doc)) {
+		v := doc[key]
+		var err error
+		switch key {
+		case "interval":
+			defaults.Interval, err = durationValue(v)
+		case "timeout":
+			defaults.Timeout, err = durationValue(v)
+		case "targets":
+			var ok bool
+			if items, ok = v.([]any); !ok {
+				err = fmt.Errorf("expected a list")
+			}
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+
+	targets := make([]target, 0, len(items))
+	names := map[string]bool{}
+	for i, item := range items {
+		fields, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("target %d: expected the settings of the target", i+1)
+		}
+		t, err := decodeTarget(fields, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("target %d: %v", i+1, err)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("target %d: the name %q is used twice", i+1, t.Name)
+		}
+		names[t.Name] = true
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+func decodeTarget(fields map[string]any, defaults target) (target, error) {
+	t := defaults
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		v := fields[key]
+		var err error
+		switch key {
+		case "name":
+			t.Name, err = stringValue(v)
+		case "url":
+			t.URL, err = stringValue(v)
+		case "interval":
+			t.Interval, err = durationValue(v)
+		case "timeout":
+			t.Timeout, err = durationValue(v)
+		case "expect":
+			for _, code := range listValue(v) {
+				var n int
+				if n, err = intValue(code); err != nil {
+					break
+				}
+				if n < 100 || n > 599 {
+					err = fmt.Errorf("%d is not an HTTP status", n)
+					break
+				}
+				t.Expect = append(t.Expect, n)
+			}
+		case "tags":
+			for _, tag := range listValue(v) {
+				var s string
+				if s, err = stringValue(tag); err != nil {
+					break
+				}
+				t.Tags = append(t.Tags, s)
+			}
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return t, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return t, t.check()
+}
+
+// check fills in the name and makes sure the target can be checked.
+func (t *target) check() error {
+	if t.URL == "" {
+		return fmt.Errorf("the url is missing")
+	}
+	u, err := url.Parse(t.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http or https url", t.URL)
+	}
+	if t.Interval <= 0 || t.Timeout <= 0 {
+		return fmt.Errorf("interval and timeout must be positive")
+	}
+	if t.Name == "" {
+		t.Name = t.URL
+	}
+	return nil
+}
+
+// readTargets reads the links given on stdin, one a line. Empty lines and lines starting with # are skipped.
+func readTargets(r io.Reader, defaults target) ([]target, error) {
+	var targets []target
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		t := defaults
+		t.URL = line
+		if err := t.check(); err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, scanner.Err()
+}
+
+// The values of the three formats: JSON gives float64 for numbers, the YAML and TOML parsers below do the same.
+
+func stringValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("expected a string, got %v", v)
+}
+
+func intValue(v any) (int, error) {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) {
+			return int(v), nil
+		}
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("expected a whole number, got %v", v)
+}
+
+func durationValue(v any) (time.Duration, error) {
+	switch v := v.(type) {
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case string:
+		return time.ParseDuration(v)
+	}
+	return 0, fmt.Errorf("expected a duration like \"30s\", got %v", v)
+}
+
+// listValue accepts a single value as a list of one, so tags: docs works like tags: [docs].
+func listValue(v any) []any {
+	if list, ok := v.([]any); ok {
+		return list
+	}
+	return []any{v}
+}
+
+// configLine is a line of a YAML or TOML file without its comment, with the number for the errors.
+type configLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// splitLines drops the empty lines and the comments.
+func splitLines(s string) []configLine {
+	var lines []configLine
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(stripComment(line), " \t\r")
+		text := strings.TrimLeft(line, " ")
+		if text == "" {
+			continue
+		}
+		lines = append(lines, configLine{num: i + 1, indent: len(line) - len(text), text: text})
+	}
+	return lines
+}
+
+// stripComment cuts the line at a # that isn't inside quotes.
+func stripComment(line string) string {
+	var quote byte
+	escaped := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && opensValue(line, i):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// opensValue tells if a quote at i starts a string, so the apostrophe of "Bob's" doesn't.
+func opensValue(line string, i int) bool {
+	return i == 0 || strings.IndexByte(" \t[,:=", line[i-1]) >= 0
+}
+
+// parseValue reads a string, a number or a list in brackets. bare allows strings without
+// quotes ( YAML ), TOML has to quote them.
+func parseValue(s string, bare bool) (any, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("the list %s isn't closed", s)
+		}
+		list := []any{}
+		for _, item := range splitItems(s[1 : len(s)-1]) {
+			v, err := parseValue(item, bare)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("the string %s isn't closed", s)
+		}
+		// in YAML a quote inside a single quoted string is written twice, TOML has no escapes in them
+		inner := s[1 : len(s)-1]
+		if bare {
+			inner = strings.ReplaceAll(inner, "''", "'")
+		}
+		return inner, nil
+	}
+	if n, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64); err == nil {
+		return n, nil
+	}
+	if !bare {
+		return nil, fmt.Errorf("%s: strings have to be quoted", s)
+	}
+	return s, nil
+}
+
+// splitItems splits the inside of a list at the commas that aren't inside quotes or a nested list.
+func splitItems(s string) []string {
+	var items []string
+	var quote byte
+	escaped := false
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch r := s[i]; {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' && quote == '"' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case (r == '"' || r == '\'') && opensValue(s, i):
+			quote = r
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case r == ',' && depth == 0:
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	// a trailing comma is allowed
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+// parseYAML reads the block style of YAML: "key: value" mappings, "- item" lists nested by
+// indentation, and [a, b] lists on one line. Anchors, multi-line strings and the other parts
+// of YAML aren't supported.
+func parseYAML(s string) (map[string]any, error) {
+	var lines []configLine
+	for _, line := range splitLines(s) {
+		if line.text != "---" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+	p := &yamlParser{lines: lines}
+	v, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	doc, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("line %d: expected key: value", lines[0].num)
+	}
+	return doc, nil
+}
+
+type yamlParser struct {
+	lines []configLine
+	pos   int
+}
+
+// block reads the mapping or the list that starts at the current line, with the given indentation.
+func (p *yamlParser) block(indent int) (any, error) {
+	if isListItem(p.lines[p.pos].text) {
+		return p.list(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) list(indent int) (any, error) {
+	list := []any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isListItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		item := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		switch {
+		case item == "":
+			// the item is the block on the next lines
+			p.pos++
+			if p.pos == len(p.lines) || p.lines[p.pos].indent <= indent {
+				list = append(list, "")
+				continue
+			}
+			v, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		case isMappingLine(item):
+			// "- key: value" opens a mapping, its other keys line up with the first one
+			p.lines[p.pos] = configLine{num: line.num, indent: line.indent + len(line.text) - len(item), text: item}
+			v, err := p.mapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		default:
+			v, err := parseValue(item, true)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line.num, err)
+			}
+			list = append(list, v)
+			p.pos++
+		}
+	}
+	return list, nil
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	m := map[string]any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		if !isMappingLine(line.text) {
+			return nil, fmt.Errorf("line %d: expected key: value", line.num)
+		}
+		key, value, _ := strings.Cut(line.text, ":")
+		key, value = unquoteKey(strings.TrimSpace(key)), strings.TrimSpace(value)
+		if _, ok := m[key]; ok {
+			return nil, fmt.Errorf("line %d: %s is set twice", line.num, key)
+		}
+		p.pos++
+		if value != "" {
+			v, err := parseValue(value, true)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line.num, err)
+			}
+			m[key] = v
+			continue
+		}
+		// the value is the block on the next lines, a list can start at the indentation of the key
+		next := p.pos < len(p.lines) && (p.lines[p.pos].indent > indent ||
+			p.lines[p.pos].indent == indent && isListItem(p.lines[p.pos].text))
+		if !next {
+			m[key] = ""
+			continue
+		}
+		v, err := p.block(p.lines[p.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+func isListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// isMappingLine tells if the text is "key: value" or "key:", with the key outside of quotes.
+func isMappingLine(text string) bool {
+	if strings.HasPrefix(text, "[") {
+		return false
+	}
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return false
+		}
+		text = text[end+2:]
+		return strings.HasPrefix(text, ":")
+	}
+	i := strings.Index(text, ":")
+	return i > 0 && (i == len(text)-1 || text[i+1] == ' ')
+}
+
+func unquoteKey(key string) string {
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
+
+// parseTOML reads "key = value" lines, [table] and [[array of tables]] headers one level deep,
+// and lists that can go over several lines. Dotted keys, dates and inline tables aren't supported.
+func parseTOML(s string) (map[string]any, error) {
+	doc := map[string]any{}
+	current := doc
+	lines := splitLines(s)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		text := line.text
+		switch {
+		case strings.HasPrefix(text, "[["):
+			name := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(text, "[["), "]]"))
+			if !strings.HasSuffix(text, "]]") || name == "" {
+				return nil, fmt.Errorf("line %d: bad header %s", line.num, text)
+			}
+			list, ok := doc[name].([]any)
+			if _, set := doc[name]; set && !ok {
+				return nil, fmt.Errorf("line %d: %s is already set", line.num, name)
+			}
+			current = map[string]any{}
+			doc[name] = append(list, current)
+			continue
+		case strings.HasPrefix(text, "["):
+			name := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(text, "["), "]"))
+			if !strings.HasSuffix(text, "]") || name == "" {
+				return nil, fmt.Errorf("line %d: bad header %s", line.num, text)
+			}
+			if _, set := doc[name]; set {
+				return nil, fmt.Errorf("line %d: %s is already set", line.num, name)
+			}
+			current = map[string]any{}
+			doc[name] = current
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", line.num)
+		}
+		key, value = unquoteKey(strings.TrimSpace(key)), strings.TrimSpace(value)
+		// a list goes on until its brackets are balanced
+		for strings.HasPrefix(value, "[") && strings.Count(value, "[") > strings.Count(value, "]") && i+1 < len(lines) {
+			i++
+			value += " " + lines[i].text
+		}
+		if _, set := current[key]; set {
+			return nil, fmt.Errorf("line %d: %s is set twice", line.num, key)
+		}
+		v, err := parseValue(value, false)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line.num, err)
+		}
+		current[key] = v
+	}
+	return doc, nil
+}
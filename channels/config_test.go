@@ -0,0 +1,173 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The same monitor list in the three formats has to give the same targets.
+func TestLoadConfigFormats(t *testing.T) {
+	files := map[string]string{
+		"monitor.json": `{
+  "interval": "30s",
+  "targets": [
+    {"name": "go", "url": "https://go.dev", "expect": [200, 301], "tags": ["docs", "go"]},
+    {"url": "http://example.com/a#b", "interval": 5, "timeout": "2s"}
+  ]
+}`,
+		"monitor.yaml": `# the links to check
+interval: 30s
+targets:
+  - name: "go"
+    url: https://go.dev
+    expect: [200, 301]
+    tags:
+      - docs
+      - 'go'
+  - url: "http://example.com/a#b"   # a comment after a value
+    interval: 5
+    timeout: 2s
+`,
+		"monitor.toml": `interval = "30s" # the default of every target
+
+[[targets]]
+name = "go"
+url = "https://go.dev"
+expect = [
+  200,
+  301,
+]
+tags = ["docs", 'go']
+
+[[targets]]
+url = "http://example.com/a#b"
+interval = 5
+timeout = "2s"
+`,
+	}
+	want := []target{
+		{Name: "go", URL: "https://go.dev", Interval: 30 * time.Second, Timeout: defaultTimeout, Expect: []int{200, 301}, Tags: []string{"docs", "go"}},
+		{Name: "http://example.com/a#b", URL: "http://example.com/a#b", Interval: 5 * time.Second, Timeout: 2 * time.Second},
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		os.WriteFile(filename, []byte(content), 0666)
+		got, err := loadConfig(filename)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Expected %+v, got %+v", name, want, got)
+		}
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"a.yaml", "targets:\n  - name: x\n", "url is missing"},
+		{"b.yaml", "targets:\n  - url: http://a\n    colour: red\n", "colour: unknown setting"},
+		{"c.yaml", "targets:\n  - url: http://a\n  - url: http://a\n", "used twice"},
+		{"d.yaml", "targets:\n  - url: http://a\n      timeout: 1s\n", "unexpected indentation"},
+		{"e.json", `{"targets": [{"url": "http://a", "expect": [700]}]}`, "700 is not an HTTP status"},
+		{"f.toml", "[[targets]]\nurl = http://a\n", "strings have to be quoted"},
+		{"g.toml", "[[targets]]\nurl = \"http://a\"\ninterval = \"soon\"\n", "interval"},
+		{"h.ini", "url=http://a", "unknown config format"},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		filename := filepath.Join(dir, tt.name)
+		os.WriteFile(filename, []byte(tt.content), 0666)
+		if _, err := loadConfig(filename); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Expected an error with %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+// A file with more than one mistake reports the same one every time, not whichever the map gives first.
+func TestLoadConfigErrorOrder(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{`{"zone": 1, "targets": [], "colour": "red"}`, "colour: unknown setting"},
+		{`{"targets": [{"url": "http://a", "timeout": "x", "size": 2, "colour": "red"}]}`, "colour: unknown setting"},
+	}
+	filename := filepath.Join(t.TempDir(), "targets.json")
+	for _, tt := range tests {
+		os.WriteFile(filename, []byte(tt.content), 0666)
+		for range 20 {
+			if _, err := loadConfig(filename); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("%s: Expected an error with %q, got %v", tt.content, tt.want, err)
+			}
+		}
+	}
+}
+
+func TestArgTargets(t *testing.T) {
+	defaults := target{Interval: time.Second, Timeout: time.Second}
+	stdin := strings.NewReader("# from stdin\nhttp://b\n\nhttp://c\n")
+	got, err := argTargets([]string{"http://a", "-"}, defaults, stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].Name != "http://a" || got[2].URL != "http://c" || got[1].Interval != time.Second {
+		t.Errorf("Expected the targets a, b and c, got %+v", got)
+	}
+	if _, err := argTargets([]string{"go.dev"}, defaults, nil); err == nil {
+		t.Errorf("Expected an error for a link without a scheme")
+	}
+	if err := uniqueNames(append(got, got[0])); err == nil {
+		t.Errorf("Expected an error for a target given twice")
+	}
+}
+
+// A reload starts, updates and stops the watch goroutines by name and leaves the others alone.
+func TestMonitorApply(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	up := target{Name: "up", URL: server.URL, Interval: time.Hour, Timeout: time.Second}
+	missing := target{Name: "missing", URL: server.URL + "/missing", Interval: time.Hour, Timeout: time.Second}
+	m := newMonitor()
+	if started, _, _ := m.apply([]target{up, missing}); started != 2 {
+		t.Fatalf("Expected 2 goroutines, got %d", started)
+	}
+	results := map[string]result{}
+	for range 2 {
+		r := <-m.results
+		results[r.Target.Name] = r
+	}
+	if !results["up"].up() || results["missing"].up() || results["missing"].Status != 404 {
+		t.Errorf("Expected up to be up and missing to be a 404, got %v", results)
+	}
+
+	missing.Expect = []int{404}
+	started, updated, stopped := m.apply([]target{missing})
+	if started != 0 || updated != 1 || stopped != 1 {
+		t.Errorf("Expected 1 change and 1 stop, got %d, %d and %d", started, updated, stopped)
+	}
+	if started, updated, stopped := m.apply([]target{missing}); started+updated+stopped != 0 {
+		t.Errorf("Expected nothing to change for the same targets, got %d, %d and %d", started, updated, stopped)
+	}
+	if len(m.watchers) != 1 || !reflect.DeepEqual(m.watchers["missing"].target, missing) {
+		t.Errorf("Expected the watcher of missing with its new settings, got %+v", m.watchers)
+	}
+	m.apply(nil)
+}
@@ -1,18 +1,62 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"net/http"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 	"time"
 )
 
+// links are checked when no config file or link is given.
+var links = []string{
+	"http://www.google.com",
+	"http://www.facebook.com",
+	"http://www.stackoverflow.com",
+	"http://www.golang.org",
+	"http://www.amazon.com",
+}
+
+// Usage:
+//
+//	channels                                   the links above
+//	channels https://go.dev https://pkg.go.dev  the links given, "-" reads more from stdin, one a line
+//	channels -config monitor.yaml              the targets of the config file ( see config.go ), reloaded
+//	                                           when the file changes or on SIGHUP ( kill -HUP <pid> )
 func main() {
-	links := []string{
-		"http://www.google.com",
-		"http://www.facebook.com",
-		"http://www.stackoverflow.com",
-		"http://www.golang.org",
-		"http://www.amazon.com",
+	configFile := flag.String("config", "", "read the targets from this .json, .yaml or .toml file")
+	interval := flag.Duration("interval", defaultInterval, "the pause between two checks of the links given as arguments")
+	timeout := flag.Duration("timeout", defaultTimeout, "how long a check of the links given as arguments can take")
+	poll := flag.Duration("poll", 2*time.Second, "how often to look for changes of the config file, 0 to reload on SIGHUP only")
+	flag.Parse()
+
+	// the links of the arguments and stdin are read once, only the config file is reloaded
+	fixed, err := argTargets(flag.Args(), target{Interval: *interval, Timeout: *timeout}, os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *configFile == "" && len(fixed) == 0 {
+		fixed, _ = argTargets(links, target{Interval: *interval, Timeout: *timeout}, nil)
+	}
+	load := func() ([]target, error) {
+		targets := slices.Clone(fixed)
+		if *configFile != "" {
+			fromFile, err := loadConfig(*configFile)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, fromFile...)
+		}
+		return targets, uniqueNames(targets)
+	}
+	targets, err := load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
 	// Serial implementation
@@ -72,72 +116,84 @@ func main() {
 	*/
 
 	// Creating a channel
-
-	c := make(chan string) // make() is a built-in func that will create a value out of a given type.
-
-	// Concurrent implementation with a channel which will require the checkLink func to receive a second argument of type chan
-	for _, link := range links {
-		go checkLink(link, c)
+	// the monitor makes the channel of the results with make() and starts a goroutine for every target ( see monitor.go )
+	m := newMonitor()
+	m.apply(targets)
+
+	// signal.Notify sends the signals on a channel too, so a reload is one more case of the select below
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var tick <-chan time.Time
+	lastChange := fileChange(*configFile)
+	if *configFile != "" && *poll > 0 {
+		tick = time.NewTicker(*poll).C
+	}
+	reload := func(why string) {
+		targets, err := load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: keeping the old targets: %v\n", why, err)
+			return
+		}
+		started, updated, stopped := m.apply(targets)
+		fmt.Printf("%s: reloaded %s, %d new, %d changed, %d stopped\n", why, *configFile, started, updated, stopped)
 	}
 
-	// for {  this is how you do an infinite loop in golang
-
-	// to make it more stylish and not use the endless for
-	// we are going to re-write it using <- c == range c
-	for l := range c {
-		// fmt.Println(<-c)
-		// for i := 0; i < len(links); i++ {
-		// We ant to loop the execution endlessly with each link re-trying over and over again
-		// time.Sleep(5 * time.Second) // this is how you pass a 5 second ( using *5, multiplying it) pause
-		// to better implement the wait condition we are going to use a Function literal ( lambda function in Py)
-		/*
-		  Function Literals:
-		  - A function literal is a function without a name. They are also known as anonymous functions or lambdas in other languages.
-		  - They are useful for short, one-off functions or when you want to define a function inline without formally declaring it.
-		  - **Closure**: Function literals are closures. This means they can access and modify variables from the surrounding scope (the scope in which they are defined).
-		  - **Syntax & Example**: You define them using the `func` keyword followed by parameters and a body. You can execute them immediately or assign them to a variable.
-
-		    // Assign to a variable:
-		    add := func(a, b int) int {
-		        return a + b
-		    }
-		    // add(2, 3) would return 5
-
-		    // Execute immediately (often used with `go`):
-		    go func(message string) {
-		        fmt.Println(message)
-		    }("Hello from a goroutine!")
-		*/
+	// a for without a condition is how you do an infinite loop in golang,
+	// select waits on several channels at once and runs the case of the first one that is ready
+	for {
+		select {
+		case r := <-m.results:
+			fmt.Println(r)
+		case <-hup:
+			reload("SIGHUP")
+		case <-tick:
+			if change := fileChange(*configFile); change != lastChange {
+				lastChange = change
+				reload("config changed")
+			}
+		}
+	}
+}
 
-		go func() {
-			time.Sleep(5 * time.Second)
-			checkLink(l, c)
-		}() // we need explicitly to pass () at the end of Function Literals to actually call it/ invoke it.
+// argTargets turns the links of the command line into targets, "-" reads the links from stdin.
+func argTargets(args []string, defaults target, stdin io.Reader) ([]target, error) {
+	var targets []target
+	for _, arg := range args {
+		if arg == "-" {
+			fromStdin, err := readTargets(stdin, defaults)
+			if err != nil {
+				return nil, fmt.Errorf("stdin: %v", err)
+			}
+			targets = append(targets, fromStdin...)
+			continue
+		}
+		t := defaults
+		t.URL = arg
+		if err := t.check(); err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
 
-		// go checkLink(l, c)
-		// We receive a value from the channel, which makes the main routine wait.
-		// However, we are not assigning it to a variable or printing it.
-		// This statement receives the value and immediately discards it, achieving the goal of waiting without producing output.
-		// <-c
+// uniqueNames refuses a target name used twice, a config file target can't have the name of a link of the arguments.
+func uniqueNames(targets []target) error {
+	seen := map[string]bool{}
+	for _, t := range targets {
+		if seen[t.Name] {
+			return fmt.Errorf("the target %q is given twice", t.Name)
+		}
+		seen[t.Name] = true
 	}
-	// fmt.Println(<-c)
+	return nil
 }
 
-// function that will take a link and it will make an http request to it and decide if it responds to it
-// adding an argument of type chan and the type of the actual channel makes the func suitable for a goroutine
-func checkLink(link string, c chan string) {
-	// here we don't care about the response at all (just for the error) therefore -> _
-	_, err := http.Get(link)
+// fileChange is what tells that the config file changed: its modification time and size.
+func fileChange(filename string) string {
+	info, err := os.Stat(filename)
 	if err != nil {
-		fmt.Println(link, "might be down!")
-		// c <- "Error"
-		// if we want to continue endlessly the execution with each element we can return in the channel the link element
-		c <- link
-		return
+		return ""
 	}
-	// receiving a msg from a channel is usually a blocking operation
-	fmt.Println(link, "is up!")
-	// c <- "OK"
-	// if we want to continue endlessly the execution with each element we can return in the channel the link element
-	c <- link
+	return fmt.Sprint(info.ModTime().UnixNano(), info.Size())
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// result is what a check sends back to the main goroutine.
+type result struct {
+	Target target
+	Status int // 0 when there was no response
+	Err    error
+	Took   time.Duration
+}
+
+func (r result) up() bool {
+	if r.Err != nil {
+		return false
+	}
+	if len(r.Target.Expect) == 0 {
+		return r.Status < 400
+	}
+	return slices.Contains(r.Target.Expect, r.Status)
+}
+
+func (r result) String() string {
+	var b strings.Builder
+	b.WriteString(r.Target.Name)
+	if r.Target.Name != r.Target.URL {
+		fmt.Fprintf(&b, " ( %s )", r.Target.URL)
+	}
+	switch {
+	case r.Err != nil:
+		fmt.Fprintf(&b, " might be down! %v", r.Err)
+	case r.up():
+		fmt.Fprintf(&b, " is up! %d in %v", r.Status, r.Took.Round(time.Millisecond))
+	default:
+		fmt.Fprintf(&b, " might be down! %d in %v", r.Status, r.Took.Round(time.Millisecond))
+	}
+	if len(r.Target.Tags) > 0 {
+		fmt.Fprintf(&b, " [%s]", strings.Join(r.Target.Tags, ", "))
+	}
+	return b.String()
+}
+
+// checkLink makes one request to the link of the target and tells how it went.
+func checkLink(t target) result {
+	client := http.Client{Timeout: t.Timeout}
+	start := time.Now()
+	resp, err := client.Get(t.URL)
+	r := result{Target: t, Err: err, Took: time.Since(start)}
+	if err == nil {
+		// here we don't care about the body at all, only about the status
+		resp.Body.Close()
+		r.Status = resp.StatusCode
+	}
+	return r
+}
+
+/*
+  Reloading without restarting:
+  - Every target has its own goroutine ( watch ) that checks the link, sends the result and waits for the interval.
+  - The monitor only talks to a watch goroutine over two channels: update hands it the new settings of its target and closing
+    stop ends it. It never touches a check that is running, so a reload doesn't cut a slow request short.
+  - On a reload the targets are matched by name: a new name starts a goroutine, a missing one is stopped, changed settings are
+    sent on update and the rest is left alone.
+*/
+
+// watcher is the monitor's side of one watch goroutine.
+type watcher struct {
+	target target
+	update chan target
+	stop   chan struct{}
+}
+
+// monitor runs the checks of all the targets and sends every result on results.
+type monitor struct {
+	watchers map[string]*watcher
+	results  chan result
+}
+
+func newMonitor() *monitor {
+	return &monitor{watchers: map[string]*watcher{}, results: make(chan result)}
+}
+
+// apply makes the running checks match the targets and tells what changed.
+func (m *monitor) apply(targets []target) (started, updated, stopped int) {
+	keep := map[string]bool{}
+	for _, t := range targets {
+		keep[t.Name] = true
+		w, ok := m.watchers[t.Name]
+		switch {
+		case !ok:
+			w = &watcher{target: t, update: make(chan target, 1), stop: make(chan struct{})}
+			m.watchers[t.Name] = w
+			go watch(t, w.update, w.stop, m.results)
+			started++
+		case !sameTarget(w.target, t):
+			// update has room for one target: replace a change the goroutine didn't pick up yet
+			select {
+			case <-w.update:
+			default:
+			}
+			w.update <- t
+			w.target = t
+			updated++
+		}
+	}
+	for name, w := range m.watchers {
+		if !keep[name] {
+			close(w.stop)
+			delete(m.watchers, name)
+			stopped++
+		}
+	}
+	return started, updated, stopped
+}
+
+/*
+  Function Literals:
+  - A function literal is a function without a name. They are also known as anonymous functions or lambdas in other languages.
+  - They are useful for short, one-off functions or when you want to define a function inline without formally declaring it.
+  - **Closure**: Function literals are closures. This means they can access and modify variables from the surrounding scope (the scope in which they are defined).
+  - **Syntax & Example**: You define them using the `func` keyword followed by parameters and a body. You can execute them immediately or assign them to a variable.
+
+    // Assign to a variable:
+    add := func(a, b int) int {
+        return a + b
+    }
+    // add(2, 3) would return 5
+
+    // Execute immediately (often used with `go`):
+    go func(message string) {
+        fmt.Println(message)
+    }("Hello from a goroutine!")
+*/
+
+// watch checks the link of the target over and over until stop is closed.
+// New settings from update are used from the next check on.
+// It is the function literal main used to start after every result ( time.Sleep, then checkLink ) turned into a loop.
+func watch(t target, update <-chan target, stop <-chan struct{}, results chan<- result) {
+	for {
+		r := checkLink(t)
+		select {
+		case results <- r:
+		case <-stop:
+			return
+		}
+
+		timer := time.NewTimer(t.Interval)
+	wait:
+		for {
+			select {
+			case <-timer.C:
+				break wait
+			case t = <-update:
+				// wait for the new interval, counted from now
+				timer.Stop()
+				timer = time.NewTimer(t.Interval)
+			case <-stop:
+				timer.Stop()
+				return
+			}
+		}
+	}
+}
+
+func sameTarget(a, b target) bool {
+	return a.Name == b.Name && a.URL == b.URL && a.Interval == b.Interval && a.Timeout == b.Timeout &&
+		slices.Equal(a.Expect, b.Expect) && slices.Equal(a.Tags, b.Tags)
+}